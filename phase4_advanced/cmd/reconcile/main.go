@@ -0,0 +1,78 @@
+// reconcile 按库存流水重新计算每个产品及其在各仓库的库存，报告与 products.stock、warehouse_stocks.stock 不一致的库存
+//
+//	go run ./cmd/reconcile        # 只检查
+//	go run ./cmd/reconcile -fix   # 将不一致的库存修正为流水合计
+//
+// 使用当前目录的 config.yaml 连接数据库，发现不一致且未修正时以非零状态码退出
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"gin-learn/phase4/config"
+	"gin-learn/phase4/internal/repository"
+	"gin-learn/phase4/pkg/logger"
+)
+
+func main() {
+	fix := flag.Bool("fix", false, "将不一致的库存修正为库存流水合计")
+	flag.Parse()
+
+	drifted, err := run(*fix)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "FAIL:", err)
+		os.Exit(1)
+	}
+	if drifted && !*fix {
+		os.Exit(1)
+	}
+}
+
+// run 检查并按需修正库存，返回是否发现不一致
+func run(fix bool) (bool, error) {
+	if err := config.Init(); err != nil {
+		return false, err
+	}
+	logger.Init("error")
+
+	db, err := repository.InitDB()
+	if err != nil {
+		return false, err
+	}
+	repo := repository.NewRepository(db)
+
+	drifts, err := repo.Stock.Reconcile()
+	if err != nil {
+		return false, err
+	}
+	if len(drifts) == 0 {
+		fmt.Println("OK: 所有产品的库存与库存流水一致")
+		return false, nil
+	}
+
+	for _, d := range drifts {
+		where := "合计"
+		if d.VariantID != 0 {
+			where = fmt.Sprintf("规格 %d", d.VariantID)
+		}
+		if d.WarehouseID != 0 {
+			where = fmt.Sprintf("仓库 %d", d.WarehouseID)
+			if d.VariantID != 0 {
+				where += fmt.Sprintf(" 规格 %d", d.VariantID)
+			}
+		}
+		fmt.Printf("产品 %d %s %s: 库存 %d，流水合计 %d，差异 %+d\n",
+			d.ProductID, d.Name, where, d.Stock, d.LedgerStock, d.Stock-d.LedgerStock)
+
+		if fix {
+			if err := repo.Stock.ResetToLedger(d); err != nil {
+				return true, err
+			}
+			fmt.Printf("  已修正为 %d\n", d.LedgerStock)
+		}
+	}
+	fmt.Printf("共 %d 处库存与流水不一致\n", len(drifts))
+	return true, nil
+}
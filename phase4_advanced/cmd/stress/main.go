@@ -0,0 +1,164 @@
+// stress 对 CreateOrder 进行并发压测，校验库存扣减不会超卖
+//
+//	go run ./cmd/stress -orders 500 -stock 100
+//
+// 使用临时 SQLite 数据库，库存平均分布在两个仓库中，部分订单需要拆分到两个仓库发货。
+// 出现超卖、保留数量与成功订单不一致或仓库库存合计与产品库存不一致时以非零状态码退出
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"gin-learn/phase4/config"
+	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/internal/repository"
+	"gin-learn/phase4/pkg/logger"
+	"gin-learn/phase4/pkg/money"
+)
+
+func main() {
+	orders := flag.Int("orders", 500, "并发下单数量")
+	stock := flag.Int("stock", 100, "初始库存")
+	quantity := flag.Int("quantity", 1, "每个订单购买数量")
+	flag.Parse()
+
+	if err := run(*orders, *stock, *quantity); err != nil {
+		fmt.Fprintln(os.Stderr, "FAIL:", err)
+		os.Exit(1)
+	}
+}
+
+func run(orders, stock, quantity int) error {
+	dir, err := os.MkdirTemp("", "stress")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	logger.Init("error")
+	config.C.DB = config.DBConfig{Type: "sqlite", Database: filepath.Join(dir, "stress.db")}
+
+	db, err := repository.InitDB()
+	if err != nil {
+		return err
+	}
+	repo := repository.NewRepository(db)
+
+	user := &model.User{Username: "stress", Email: "stress@example.com", Password: "-", Status: 1}
+	if err := repo.User.Create(user); err != nil {
+		return err
+	}
+	if err := repo.Address.Create(&model.Address{UserID: user.ID, AddressInfo: model.AddressInfo{Receiver: "stress", Province: "广东省", City: "深圳市", Detail: "1号"}}); err != nil {
+		return err
+	}
+	// 两个产品保证每个订单都要按顺序扣减多行库存
+	products := []*model.Product{
+		{Name: "stress-a", Price: money.Amount(100), Stock: stock},
+		{Name: "stress-b", Price: money.Amount(100), Stock: stock},
+	}
+	// 产品的初始库存写入默认仓库
+	first, err := repo.Warehouse.GetByCode("MAIN")
+	if err != nil {
+		return err
+	}
+	second := &model.Warehouse{Code: "STRESS", Name: "stress", Priority: 1}
+	if err := repo.Warehouse.Create(second); err != nil {
+		return err
+	}
+	for _, p := range products {
+		if err := repo.Product.Create(p); err != nil {
+			return err
+		}
+		// 一半库存调拨到第二个仓库
+		err := repo.Warehouse.Transfer(&model.StockTransfer{
+			ProductID: p.ID, FromWarehouseID: first.ID, ToWarehouseID: second.ID, Quantity: stock / 2,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	var (
+		wg         sync.WaitGroup
+		mu         sync.Mutex
+		succeeded  int
+		outOfStock int
+		failures   []error
+	)
+	start := make(chan struct{})
+	for i := 0; i < orders; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+
+			// 交替打乱订单项顺序，验证仓库层的加锁顺序与输入无关
+			items := []repository.OrderItemInput{
+				{ProductID: products[0].ID, Quantity: quantity},
+				{ProductID: products[1].ID, Quantity: quantity},
+			}
+			if i%2 == 1 {
+				items[0], items[1] = items[1], items[0]
+			}
+
+			_, err := repo.Order.CreateOrder(repository.CreateOrderInput{UserID: user.ID, Items: items})
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				succeeded++
+			case errors.Is(err, repository.ErrInsufficientStock):
+				outOfStock++
+			default:
+				failures = append(failures, err)
+			}
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	if len(failures) > 0 {
+		return fmt.Errorf("%d 个订单发生非库存错误，第一个错误: %w", len(failures), failures[0])
+	}
+
+	expected := min(orders, stock/quantity)
+	if succeeded != expected {
+		return fmt.Errorf("成功下单 %d 个，期望 %d 个", succeeded, expected)
+	}
+
+	for _, p := range products {
+		current, err := repo.Product.GetByID(p.ID)
+		if err != nil {
+			return err
+		}
+		// 下单只保留库存，现有库存在支付前保持不变
+		if current.Stock != stock || current.Reserved != succeeded*quantity {
+			return fmt.Errorf("产品 %s 库存 %d、保留 %d，期望 %d、%d", p.Name, current.Stock, current.Reserved, stock, succeeded*quantity)
+		}
+		var stocks, reserved int
+		for _, ws := range current.Stocks {
+			stocks += ws.Stock
+			reserved += ws.Reserved
+		}
+		if stocks != current.Stock || reserved != current.Reserved {
+			return fmt.Errorf("产品 %s 各仓库库存合计 %d、保留合计 %d，与产品库存 %d、保留 %d 不一致", p.Name, stocks, reserved, current.Stock, current.Reserved)
+		}
+	}
+
+	var sold int64
+	if err := db.Model(&model.OrderItem{}).Select("COALESCE(SUM(quantity), 0)").Scan(&sold).Error; err != nil {
+		return err
+	}
+	if int(sold) != succeeded*quantity*len(products) {
+		return fmt.Errorf("订单项合计售出 %d，期望 %d", sold, succeeded*quantity*len(products))
+	}
+
+	fmt.Printf("OK: %d 个并发订单，成功 %d 个，库存不足 %d 个，无超卖\n", orders, succeeded, outOfStock)
+	return nil
+}
@@ -8,11 +8,18 @@ import (
 
 // AppConfig 全局配置
 type Config struct {
-	App    AppConfig    `mapstructure:"app"`
-	Server ServerConfig `mapstructure:"server"`
-	DB     DBConfig     `mapstructure:"database"`
-	Redis  RedisConfig  `mapstructure:"redis"`
-	Log    LogConfig    `mapstructure:"log"`
+	App         AppConfig         `mapstructure:"app"`
+	Server      ServerConfig      `mapstructure:"server"`
+	DB          DBConfig          `mapstructure:"database"`
+	Redis       RedisConfig       `mapstructure:"redis"`
+	Log         LogConfig         `mapstructure:"log"`
+	JWT         JWTConfig         `mapstructure:"jwt"`
+	RBAC        RBACConfig        `mapstructure:"rbac"`
+	Idempotency IdempotencyConfig `mapstructure:"idempotency"`
+	Payment     PaymentConfig     `mapstructure:"payment"`
+	Order       OrderConfig       `mapstructure:"order"`
+	Alert       AlertConfig       `mapstructure:"alert"`
+	PublicID    PublicIDConfig    `mapstructure:"public_id"`
 }
 
 type AppConfig struct {
@@ -55,6 +62,60 @@ type LogConfig struct {
 	MaxAge     int    `mapstructure:"max_age"`
 }
 
+// JWTConfig JWT配置，过期时间单位为秒
+type JWTConfig struct {
+	Secret        string `mapstructure:"secret"`
+	Expire        int    `mapstructure:"expire"`
+	RefreshExpire int    `mapstructure:"refresh_expire"`
+}
+
+// RBACConfig 权限配置
+type RBACConfig struct {
+	// AdminUsername 启动时自动授予 admin 角色的用户名，为空则不处理
+	AdminUsername string `mapstructure:"admin_username"`
+}
+
+// IdempotencyConfig 幂等键配置
+type IdempotencyConfig struct {
+	TTL int `mapstructure:"ttl"` // 幂等键保留时间，单位秒
+}
+
+// PaymentConfig 支付配置
+type PaymentConfig struct {
+	Provider      string            `mapstructure:"provider"`       // 支付渠道，目前内置 mock
+	WebhookSecret string            `mapstructure:"webhook_secret"` // 支付回调 HMAC 签名密钥
+	Mock          MockPaymentConfig `mapstructure:"mock"`
+}
+
+// MockPaymentConfig 模拟支付渠道配置
+type MockPaymentConfig struct {
+	// SimulateEnabled 是否开放模拟支付结果接口，任何能调用该接口的用户都可以不付款完成支付，只能在开发和测试环境开启
+	SimulateEnabled bool `mapstructure:"simulate_enabled"`
+}
+
+// OrderConfig 订单配置，时间单位为秒
+type OrderConfig struct {
+	PendingTTL     int `mapstructure:"pending_ttl"`     // 待支付订单超过该时间自动取消，0 表示不自动取消
+	SweepInterval  int `mapstructure:"sweep_interval"`  // 检查超时订单和过期库存保留的间隔
+	ReservationTTL int `mapstructure:"reservation_ttl"` // 下单时保留库存的时间，过期未支付的订单自动取消，0 表示不过期，-1 表示与 PendingTTL 相同
+	NodeID         int `mapstructure:"node_id"`         // 订单号生成器的节点编号 0-1023，多实例部署时每个实例必须不同，-1 表示根据主机名和进程号生成
+}
+
+// AlertConfig 低库存告警配置
+type AlertConfig struct {
+	Notifiers      []string `mapstructure:"notifiers"`       // 启用的通知方式：log、webhook、file
+	WebhookURL     string   `mapstructure:"webhook_url"`     // webhook 通知地址
+	WebhookSecret  string   `mapstructure:"webhook_secret"`  // webhook 请求体 HMAC 签名密钥，为空时不签名
+	WebhookTimeout int      `mapstructure:"webhook_timeout"` // webhook 请求超时，单位秒
+	EmailTo        string   `mapstructure:"email_to"`        // 告警邮件收件人
+	EmailFile      string   `mapstructure:"email_file"`      // 告警邮件写入的文件，用于代替发送邮件
+}
+
+// PublicIDConfig 对外ID编码配置
+type PublicIDConfig struct {
+	Secret string `mapstructure:"secret"` // 整数ID与对外ID之间置换的密钥，修改后已公开的ID全部失效
+}
+
 var C Config
 
 func Init() error {
@@ -81,6 +142,18 @@ func Init() error {
 		return fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
+	return C.Order.normalize()
+}
+
+// normalize 确定库存保留时间并校验与待支付超时的关系。
+// 待支付订单的超时时间以 PendingTTL 为准，保留过期也会取消订单，因此保留时间不能短于 PendingTTL
+func (o *OrderConfig) normalize() error {
+	if o.ReservationTTL < 0 {
+		o.ReservationTTL = o.PendingTTL
+	}
+	if o.PendingTTL > 0 && o.ReservationTTL > 0 && o.ReservationTTL < o.PendingTTL {
+		return fmt.Errorf("order.reservation_ttl (%d) must not be shorter than order.pending_ttl (%d)", o.ReservationTTL, o.PendingTTL)
+	}
 	return nil
 }
 
@@ -99,4 +172,16 @@ func setDefaults() {
 	viper.SetDefault("log.level", "info")
 	viper.SetDefault("log.format", "json")
 	viper.SetDefault("log.output", "stdout")
+	viper.SetDefault("jwt.expire", 7200)
+	viper.SetDefault("jwt.refresh_expire", 604800)
+	viper.SetDefault("idempotency.ttl", 86400)
+	viper.SetDefault("payment.provider", "mock")
+	viper.SetDefault("payment.mock.simulate_enabled", false)
+	viper.SetDefault("order.pending_ttl", 1800)
+	viper.SetDefault("order.sweep_interval", 60)
+	viper.SetDefault("order.reservation_ttl", -1)
+	viper.SetDefault("order.node_id", -1)
+	viper.SetDefault("alert.notifiers", []string{"log"})
+	viper.SetDefault("alert.webhook_timeout", 5)
+	viper.SetDefault("alert.email_file", "logs/stock_alerts.eml")
 }
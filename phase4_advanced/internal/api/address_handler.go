@@ -0,0 +1,158 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AddressRequest 收货地址请求
+type AddressRequest struct {
+	Receiver   string `json:"receiver" binding:"required,max=50"`
+	Phone      string `json:"phone" binding:"required,max=20"`
+	Province   string `json:"province" binding:"required,max=50"`
+	City       string `json:"city" binding:"required,max=50"`
+	District   string `json:"district" binding:"max=50"`
+	Detail     string `json:"detail" binding:"required,max=255"`
+	PostalCode string `json:"postal_code" binding:"max=10"`
+	IsDefault  bool   `json:"is_default"`
+}
+
+func (r *AddressRequest) info() model.AddressInfo {
+	return model.AddressInfo{
+		Receiver:   r.Receiver,
+		Phone:      r.Phone,
+		Province:   r.Province,
+		City:       r.City,
+		District:   r.District,
+		Detail:     r.Detail,
+		PostalCode: r.PostalCode,
+	}
+}
+
+// ListAddresses 获取当前用户的收货地址
+func (s *Server) ListAddresses(c *gin.Context) {
+	addresses, err := s.service.Address.ListAddresses(currentUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": addresses})
+}
+
+// CreateAddress 新增收货地址
+func (s *Server) CreateAddress(c *gin.Context) {
+	var req AddressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	address, err := s.service.Address.CreateAddress(currentUserID(c), req.info(), req.IsDefault)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, address)
+}
+
+// GetAddress 获取收货地址详情
+func (s *Server) GetAddress(c *gin.Context) {
+	id, ok := addressID(c)
+	if !ok {
+		return
+	}
+
+	address, err := s.service.Address.GetAddress(currentUserID(c), id)
+	if err != nil {
+		c.JSON(addressErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, address)
+}
+
+// UpdateAddress 修改收货地址
+func (s *Server) UpdateAddress(c *gin.Context) {
+	id, ok := addressID(c)
+	if !ok {
+		return
+	}
+
+	var req AddressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := currentUserID(c)
+	address, err := s.service.Address.UpdateAddress(userID, id, req.info())
+	if err != nil {
+		c.JSON(addressErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.IsDefault && !address.IsDefault {
+		if err := s.service.Address.SetDefault(userID, id); err != nil {
+			c.JSON(addressErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		address.IsDefault = true
+	}
+
+	c.JSON(http.StatusOK, address)
+}
+
+// DeleteAddress 删除收货地址
+func (s *Server) DeleteAddress(c *gin.Context) {
+	id, ok := addressID(c)
+	if !ok {
+		return
+	}
+
+	if err := s.service.Address.DeleteAddress(currentUserID(c), id); err != nil {
+		c.JSON(addressErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// SetDefaultAddress 设为默认收货地址
+func (s *Server) SetDefaultAddress(c *gin.Context) {
+	id, ok := addressID(c)
+	if !ok {
+		return
+	}
+
+	if err := s.service.Address.SetDefault(currentUserID(c), id); err != nil {
+		c.JSON(addressErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "已设为默认地址"})
+}
+
+// addressID 解析路径中的地址ID，失败时直接返回400
+func addressID(c *gin.Context) (model.ID[model.Address], bool) {
+	id, err := model.ParseID[model.Address](c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的地址ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// addressErrorStatus 将收货地址服务错误映射为HTTP状态码
+func addressErrorStatus(err error) int {
+	if errors.Is(err, service.ErrAddressNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
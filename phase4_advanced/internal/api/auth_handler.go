@@ -0,0 +1,73 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"gin-learn/phase4/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 认证请求结构体
+type LoginRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// Login 用户登录
+func (s *Server) Login(c *gin.Context) {
+	var req LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := s.service.Auth.Login(req.Username, req.Password)
+	if err != nil {
+		status := http.StatusUnauthorized
+		if errors.Is(err, service.ErrUserDisabled) {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// RefreshToken 刷新Token
+func (s *Server) RefreshToken(c *gin.Context) {
+	var req RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pair, err := s.service.Auth.Refresh(req.RefreshToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, pair)
+}
+
+// Logout 退出登录，吊销当前 Access Token 和对应的 Refresh Token
+func (s *Server) Logout(c *gin.Context) {
+	if err := s.service.Auth.Logout(currentClaims(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "已退出登录"})
+}
+
+// GetProfile 获取当前用户信息
+func (s *Server) GetProfile(c *gin.Context) {
+	c.JSON(http.StatusOK, currentUser(c))
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/internal/repository"
+	"gin-learn/phase4/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 购物车请求结构体
+type AddCartItemRequest struct {
+	ProductID model.ID[model.Product]        `json:"product_id" binding:"required"`
+	VariantID model.ID[model.ProductVariant] `json:"variant_id"` // 有规格的产品必须指定
+	Quantity  int                            `json:"quantity" binding:"required,min=1"`
+}
+
+type UpdateCartItemRequest struct {
+	Quantity int `json:"quantity" binding:"required,min=1"`
+}
+
+type CheckoutRequest struct {
+	ItemIDs    []model.ID[model.CartItem] `json:"item_ids" binding:"required,min=1"`
+	AddressID  model.ID[model.Address]    `json:"address_id"`
+	Currency   string                     `json:"currency"`
+	CouponCode string                     `json:"coupon_code"`
+}
+
+// GetCart 获取当前用户的购物车，可通过 currency 查询参数指定结算货币
+func (s *Server) GetCart(c *gin.Context) {
+	cart, err := s.service.Cart.GetCart(currentUserID(c), c.Query("currency"))
+	if err != nil {
+		c.JSON(currencyErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, cart)
+}
+
+// AddCartItem 加入购物车
+func (s *Server) AddCartItem(c *gin.Context) {
+	var req AddCartItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.service.Cart.AddItem(currentUserID(c), req.ProductID, req.VariantID, req.Quantity); err != nil {
+		c.JSON(cartErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "已加入购物车"})
+}
+
+// UpdateCartItem 修改购物车商品数量
+func (s *Server) UpdateCartItem(c *gin.Context) {
+	id, err := model.ParseID[model.CartItem](c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的购物车商品ID"})
+		return
+	}
+
+	var req UpdateCartItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.service.Cart.UpdateQuantity(currentUserID(c), id, req.Quantity); err != nil {
+		c.JSON(cartErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "修改成功"})
+}
+
+// RemoveCartItem 移除购物车商品
+func (s *Server) RemoveCartItem(c *gin.Context) {
+	id, err := model.ParseID[model.CartItem](c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的购物车商品ID"})
+		return
+	}
+
+	if err := s.service.Cart.RemoveItem(currentUserID(c), id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// ClearCart 清空购物车
+func (s *Server) ClearCart(c *gin.Context) {
+	if err := s.service.Cart.Clear(currentUserID(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "购物车已清空"})
+}
+
+// Checkout 结算选中的购物车商品并创建订单
+func (s *Server) Checkout(c *gin.Context) {
+	var req CheckoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	order, err := s.service.Cart.Checkout(currentUserID(c), req.AddressID, req.Currency, req.CouponCode, req.ItemIDs)
+	if err != nil {
+		c.JSON(cartErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, order)
+}
+
+// cartErrorStatus 将购物车服务错误映射为HTTP状态码
+func cartErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrCartItemNotFound), errors.Is(err, service.ErrProductNotFound),
+		errors.Is(err, repository.ErrVariantNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrStockNotEnough), errors.Is(err, repository.ErrInsufficientStock):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
@@ -2,7 +2,8 @@ package api
 
 import (
 	"net/http"
-	"strconv"
+
+	"gin-learn/phase4/internal/model"
 
 	"github.com/gin-gonic/gin"
 )
@@ -32,13 +33,13 @@ func (s *Server) CreateCategory(c *gin.Context) {
 
 // GetCategory 获取分类详情
 func (s *Server) GetCategory(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	id, err := model.ParseID[model.Category](c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的分类ID"})
 		return
 	}
 
-	category, err := s.service.Category.GetCategory(uint(id))
+	category, err := s.service.Category.GetCategory(id)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "分类不存在"})
 		return
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/internal/service"
+	"gin-learn/phase4/pkg/money"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CouponRequest 优惠券请求，创建和修改共用
+type CouponRequest struct {
+	Code         string                   `json:"code" binding:"required,max=50"`
+	Type         string                   `json:"type" binding:"required,oneof=percent fixed free_shipping"`
+	Percent      int                      `json:"percent"`
+	Amount       money.Amount             `json:"amount"`
+	Currency     string                   `json:"currency"`
+	MinSpend     money.Amount             `json:"min_spend"`
+	CategoryID   model.ID[model.Category] `json:"category_id"`
+	ProductID    model.ID[model.Product]  `json:"product_id"`
+	StartsAt     *time.Time               `json:"starts_at"`
+	EndsAt       *time.Time               `json:"ends_at"`
+	UsageLimit   int                      `json:"usage_limit"`
+	PerUserLimit int                      `json:"per_user_limit"`
+}
+
+func (r *CouponRequest) coupon() model.Coupon {
+	return model.Coupon{
+		Code:         r.Code,
+		Type:         r.Type,
+		Percent:      r.Percent,
+		Amount:       r.Amount,
+		Currency:     r.Currency,
+		MinSpend:     r.MinSpend,
+		CategoryID:   r.CategoryID,
+		ProductID:    r.ProductID,
+		StartsAt:     r.StartsAt,
+		EndsAt:       r.EndsAt,
+		UsageLimit:   r.UsageLimit,
+		PerUserLimit: r.PerUserLimit,
+	}
+}
+
+// ListCoupons 获取全部优惠券
+func (s *Server) ListCoupons(c *gin.Context) {
+	coupons, err := s.service.Coupon.ListCoupons()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": coupons})
+}
+
+// CreateCoupon 创建优惠券
+func (s *Server) CreateCoupon(c *gin.Context) {
+	var req CouponRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	coupon, err := s.service.Coupon.CreateCoupon(req.coupon())
+	if err != nil {
+		c.JSON(couponErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, coupon)
+}
+
+// GetCoupon 获取优惠券详情
+func (s *Server) GetCoupon(c *gin.Context) {
+	id, ok := couponID(c)
+	if !ok {
+		return
+	}
+
+	coupon, err := s.service.Coupon.GetCoupon(id)
+	if err != nil {
+		c.JSON(couponErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, coupon)
+}
+
+// UpdateCoupon 修改优惠券
+func (s *Server) UpdateCoupon(c *gin.Context) {
+	id, ok := couponID(c)
+	if !ok {
+		return
+	}
+
+	var req CouponRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	coupon, err := s.service.Coupon.UpdateCoupon(id, req.coupon())
+	if err != nil {
+		c.JSON(couponErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, coupon)
+}
+
+// DeleteCoupon 删除优惠券，已使用该优惠券的订单不受影响
+func (s *Server) DeleteCoupon(c *gin.Context) {
+	id, ok := couponID(c)
+	if !ok {
+		return
+	}
+
+	if err := s.service.Coupon.DeleteCoupon(id); err != nil {
+		c.JSON(couponErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+func couponID(c *gin.Context) (model.ID[model.Coupon], bool) {
+	id, err := model.ParseID[model.Coupon](c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的优惠券ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// couponErrorStatus 将优惠券服务错误映射为HTTP状态码
+func couponErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrCouponNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrCouponExists):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrInvalidCoupon), errors.Is(err, service.ErrInvalidCurrency):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"gin-learn/phase4/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetExchangeRateRequest 设置汇率请求，1 base = rate quote
+type SetExchangeRateRequest struct {
+	Base  string `json:"base" binding:"required,len=3"`
+	Quote string `json:"quote" binding:"required,len=3"`
+	Rate  string `json:"rate" binding:"required"`
+}
+
+// ListExchangeRates 获取全部汇率
+func (s *Server) ListExchangeRates(c *gin.Context) {
+	rates, err := s.service.Currency.ListRates()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": rates})
+}
+
+// SetExchangeRate 新增或更新汇率
+func (s *Server) SetExchangeRate(c *gin.Context) {
+	var req SetExchangeRateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rate, err := s.service.Currency.SetRate(req.Base, req.Quote, req.Rate)
+	if err != nil {
+		c.JSON(currencyErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rate)
+}
+
+// DeleteExchangeRate 删除汇率
+func (s *Server) DeleteExchangeRate(c *gin.Context) {
+	id, err := model.ParseID[model.ExchangeRate](c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的汇率ID"})
+		return
+	}
+
+	if err := s.service.Currency.DeleteRate(id); err != nil {
+		c.JSON(currencyErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// ImportExchangeRates 从 CSV 导入汇率，支持 multipart 表单的 file 字段或直接以请求体上传
+func (s *Server) ImportExchangeRates(c *gin.Context) {
+	body := c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		file, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		f, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		defer f.Close()
+		body = f
+	}
+
+	count, err := s.service.Currency.ImportCSV(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "导入成功", "count": count})
+}
@@ -1,12 +1,30 @@
 package api
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"strings"
 	"time"
 
+	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/internal/service"
+	"gin-learn/phase4/pkg/token"
+
 	"github.com/gin-gonic/gin"
 )
 
+// Context 中保存认证信息的键
+const (
+	ContextUserKey   = "user"
+	ContextUserIDKey = "userID"
+	ContextClaimsKey = "claims"
+)
+
 // LoggerMiddleware 日志中间件
 func LoggerMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -40,7 +58,7 @@ func CORSMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Idempotency-Key")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -50,3 +68,162 @@ func CORSMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// JWTAuth JWT认证中间件
+// 校验 Authorization 头中的 Bearer Token 及吊销状态，成功后将当前用户存入 Context
+func JWTAuth(auth service.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authorization := c.GetHeader("Authorization")
+		tokenString, ok := strings.CutPrefix(authorization, "Bearer ")
+		if !ok || tokenString == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "缺少认证信息"})
+			return
+		}
+
+		user, claims, err := auth.Authenticate(tokenString)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Set(ContextClaimsKey, claims)
+		c.Set(ContextUserKey, user)
+		c.Set(ContextUserIDKey, user.ID)
+		c.Next()
+	}
+}
+
+// RequirePermission 权限校验中间件，需在 JWTAuth 之后使用
+func RequirePermission(code string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !hasPermission(c, code) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "缺少权限: " + code})
+			return
+		}
+		c.Next()
+	}
+}
+
+// responseRecorder 在写出响应的同时保留一份响应体
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotency 幂等中间件
+// 请求携带 Idempotency-Key 头时，首次请求的状态码和响应体会被保存，
+// 之后相同的请求直接重放保存的响应；同一个键的请求仍在处理中时返回 409。
+// 需要登录的路由应放在 JWTAuth 之后，以便按用户隔离幂等键；匿名请求（如注册）没有用户可以隔离，
+// 改为按幂等键和请求体的哈希隔离，不同客户端碰巧使用相同的键时不会拿到彼此的响应
+func Idempotency(svc service.IdempotencyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > 100 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key 长度不能超过100"})
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256(body)
+
+		userID := currentUserID(c)
+		if userID == 0 {
+			scoped := sha256.Sum256(append([]byte(key+"\n"), body...))
+			key = "anon:" + hex.EncodeToString(scoped[:])
+		}
+
+		record, replay, err := svc.Begin(key, userID, c.Request.Method, c.Request.URL.Path, hex.EncodeToString(sum[:]))
+		switch {
+		case errors.Is(err, service.ErrIdempotencyInProgress):
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, service.ErrIdempotencyMismatch):
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		if replay {
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(record.ResponseCode, "application/json; charset=utf-8", []byte(record.ResponseBody))
+			c.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+
+		// 处理失败（5xx 或 panic）时释放幂等键，允许客户端重试
+		completed := false
+		defer func() {
+			if !completed {
+				_ = svc.Release(record.ID)
+			}
+		}()
+
+		c.Next()
+
+		if recorder.Status() >= http.StatusInternalServerError {
+			return
+		}
+		if err := svc.Complete(record.ID, recorder.Status(), recorder.body.String()); err == nil {
+			completed = true
+		}
+	}
+}
+
+// currentUser 获取当前登录用户，需在 JWTAuth 之后使用
+func currentUser(c *gin.Context) *model.User {
+	user, _ := c.MustGet(ContextUserKey).(*model.User)
+	return user
+}
+
+// currentClaims 获取当前请求的 Token Claims，需在 JWTAuth 之后使用
+func currentClaims(c *gin.Context) *token.Claims {
+	claims, _ := c.MustGet(ContextClaimsKey).(*token.Claims)
+	return claims
+}
+
+// currentUserID 获取当前登录用户ID，需在 JWTAuth 之后使用
+func currentUserID(c *gin.Context) model.ID[model.User] {
+	value, _ := c.Get(ContextUserIDKey)
+	id, _ := value.(model.ID[model.User])
+	return id
+}
+
+// hasPermission 当前登录用户是否拥有指定权限
+func hasPermission(c *gin.Context, code string) bool {
+	user := currentUser(c)
+	return user != nil && user.HasPermission(code)
+}
+
+// queryID 解析可选的 T 实体ID查询参数，未提供时返回 0；格式无效时直接返回400，避免过滤条件被静默忽略
+func queryID[T any](c *gin.Context, name, message string) (model.ID[T], bool) {
+	id, err := model.ParseID[T](c.Query(name))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+		return 0, false
+	}
+	return id, true
+}
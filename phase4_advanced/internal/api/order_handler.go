@@ -1,9 +1,12 @@
 package api
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
+	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/internal/repository"
 	"gin-learn/phase4/internal/service"
 
 	"github.com/gin-gonic/gin"
@@ -11,11 +14,18 @@ import (
 
 // 订单请求结构体
 type CreateOrderRequest struct {
-	UserID uint                     `json:"user_id" binding:"required"`
-	Items  []service.OrderItemInput `json:"items" binding:"required,min=1"`
+	Items      []service.OrderItemInput `json:"items" binding:"required,min=1,dive"`
+	AddressID  model.ID[model.Address]  `json:"address_id"`
+	Currency   string                   `json:"currency"`
+	CouponCode string                   `json:"coupon_code"`
 }
 
-// CreateOrder 创建订单
+// OrderActionRequest 订单状态操作请求，请求体可省略
+type OrderActionRequest struct {
+	Reason string `json:"reason" binding:"max=255"`
+}
+
+// CreateOrder 创建订单，订单归属于当前登录用户
 func (s *Server) CreateOrder(c *gin.Context) {
 	var req CreateOrderRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -23,7 +33,7 @@ func (s *Server) CreateOrder(c *gin.Context) {
 		return
 	}
 
-	order, err := s.service.Order.CreateOrder(req.UserID, req.Items)
+	order, err := s.service.Order.CreateOrder(currentUserID(c), req.AddressID, req.Currency, req.CouponCode, req.Items)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -32,25 +42,66 @@ func (s *Server) CreateOrder(c *gin.Context) {
 	c.JSON(http.StatusCreated, order)
 }
 
+// QuoteOrder 按下单流程计算订单金额明细，不创建订单
+func (s *Server) QuoteOrder(c *gin.Context) {
+	var req CreateOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	quote, err := s.service.Order.QuoteOrder(currentUserID(c), req.AddressID, req.Currency, req.CouponCode, req.Items)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, quote)
+}
+
 // GetOrder 获取订单详情
 func (s *Server) GetOrder(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	id, err := model.ParseID[model.Order](c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的订单ID"})
 		return
 	}
 
-	order, err := s.service.Order.GetOrder(uint(id))
+	order, err := s.service.Order.GetOrder(id, currentUserID(c), hasPermission(c, model.PermOrderManage))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "订单不存在"})
+		c.JSON(orderErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, order)
 }
 
-// ListOrders 获取订单列表
+// GetOrderByNumber 按订单号获取订单详情
+func (s *Server) GetOrderByNumber(c *gin.Context) {
+	order, err := s.service.Order.GetOrderByNumber(c.Param("order_no"), currentUserID(c), hasPermission(c, model.PermOrderManage))
+	if err != nil {
+		c.JSON(orderErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+// ListOrders 获取全部订单列表，可按 user_id 过滤
 func (s *Server) ListOrders(c *gin.Context) {
+	userID, ok := queryID[model.User](c, "user_id", "无效的用户ID")
+	if !ok {
+		return
+	}
+	s.listOrders(c, userID)
+}
+
+// ListMyOrders 获取当前用户的订单列表
+func (s *Server) ListMyOrders(c *gin.Context) {
+	s.listOrders(c, currentUserID(c))
+}
+
+func (s *Server) listOrders(c *gin.Context, userID model.ID[model.User]) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
 
@@ -61,7 +112,7 @@ func (s *Server) ListOrders(c *gin.Context) {
 		pageSize = 10
 	}
 
-	orders, total, err := s.service.Order.ListOrders(page, pageSize)
+	orders, total, err := s.service.Order.ListOrders(page, pageSize, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -77,16 +128,68 @@ func (s *Server) ListOrders(c *gin.Context) {
 
 // CancelOrder 取消订单
 func (s *Server) CancelOrder(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	s.orderAction(c, "订单已取消", func(id model.ID[model.Order], reason string) error {
+		return s.service.Order.CancelOrder(id, currentUserID(c), hasPermission(c, model.PermOrderManage), reason)
+	})
+}
+
+// ShipOrder 订单发货，发出全部尚未发货的商品
+func (s *Server) ShipOrder(c *gin.Context) {
+	s.orderAction(c, "订单已发货", func(id model.ID[model.Order], reason string) error {
+		_, err := s.service.Shipment.CreateShipment(id, currentUserID(c), service.ShipmentInput{Note: reason})
+		return err
+	})
+}
+
+// DeliverOrder 订单送达
+func (s *Server) DeliverOrder(c *gin.Context) {
+	s.orderAction(c, "订单已送达", func(id model.ID[model.Order], reason string) error {
+		return s.service.Order.DeliverOrder(id, currentUserID(c), reason)
+	})
+}
+
+// CompleteOrder 确认收货
+func (s *Server) CompleteOrder(c *gin.Context) {
+	s.orderAction(c, "订单已完成", func(id model.ID[model.Order], reason string) error {
+		return s.service.Order.CompleteOrder(id, currentUserID(c), hasPermission(c, model.PermOrderManage), reason)
+	})
+}
+
+// orderAction 解析订单ID和可选的 reason，执行状态操作
+func (s *Server) orderAction(c *gin.Context, message string, action func(id model.ID[model.Order], reason string) error) {
+	id, err := model.ParseID[model.Order](c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的订单ID"})
 		return
 	}
 
-	if err := s.service.Order.CancelOrder(uint(id)); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var req OrderActionRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if err := action(id, req.Reason); err != nil {
+		c.JSON(orderErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "订单已取消"})
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
+
+// orderErrorStatus 将订单服务错误映射为HTTP状态码
+func orderErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrOrderNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrOrderForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrInvalidTransition), errors.Is(err, repository.ErrStatusConflict),
+		errors.Is(err, repository.ErrShipmentExceeded):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
 }
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SimulatePaymentRequest 模拟支付结果请求
+type SimulatePaymentRequest struct {
+	IntentID string `json:"intent_id" binding:"required"`
+	Status   string `json:"status" binding:"required,oneof=succeeded failed"`
+	DelayMS  int    `json:"delay_ms" binding:"min=0,max=60000"`
+}
+
+// PayOrder 为订单创建支付意图，支付结果通过支付渠道回调通知
+func (s *Server) PayOrder(c *gin.Context) {
+	id, err := model.ParseID[model.Order](c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的订单ID"})
+		return
+	}
+
+	payment, err := s.service.Payment.Pay(id, currentUserID(c))
+	if err != nil {
+		c.JSON(paymentErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, payment)
+}
+
+// ListOrderPayments 获取订单的支付记录
+func (s *Server) ListOrderPayments(c *gin.Context) {
+	id, err := model.ParseID[model.Order](c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的订单ID"})
+		return
+	}
+
+	payments, err := s.service.Payment.ListPayments(id, currentUserID(c), hasPermission(c, model.PermOrderManage))
+	if err != nil {
+		c.JSON(paymentErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": payments})
+}
+
+// PaymentWebhook 支付渠道回调，签名放在 X-Signature 请求头中
+func (s *Server) PaymentWebhook(c *gin.Context) {
+	payload, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.service.Payment.HandleWebhook(c.Param("provider"), payload, c.GetHeader("X-Signature")); err != nil {
+		c.JSON(paymentErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "ok"})
+}
+
+// SimulatePayment 使用模拟渠道触发支付成功或失败的回调
+func (s *Server) SimulatePayment(c *gin.Context) {
+	var req SimulatePaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	delay := time.Duration(req.DelayMS) * time.Millisecond
+	err := s.service.Payment.SimulatePayment(req.IntentID, currentUserID(c), hasPermission(c, model.PermOrderManage), req.Status, delay)
+	if err != nil {
+		c.JSON(paymentErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	if delay > 0 {
+		c.JSON(http.StatusAccepted, gin.H{"message": "回调将在延迟后发送"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "回调已处理"})
+}
+
+// ListOrphanedPayments 获取已收款但订单已不是待支付、等待原路退回的支付
+func (s *Server) ListOrphanedPayments(c *gin.Context) {
+	payments, err := s.service.Payment.ListOrphanedPayments()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": payments})
+}
+
+// RefundOrphanedPayment 重试孤立支付的原路退回
+func (s *Server) RefundOrphanedPayment(c *gin.Context) {
+	id, err := model.ParseID[model.Payment](c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的支付ID"})
+		return
+	}
+
+	payment, err := s.service.Payment.RefundOrphanedPayment(id)
+	if err != nil {
+		c.JSON(paymentErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, payment)
+}
+
+// paymentErrorStatus 将支付服务错误映射为HTTP状态码
+func paymentErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrInvalidSignature):
+		return http.StatusUnauthorized
+	case errors.Is(err, service.ErrPaymentNotFound), errors.Is(err, service.ErrPaymentProviderNotFound):
+		return http.StatusNotFound
+	default:
+		return orderErrorStatus(err)
+	}
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/internal/service"
+	"gin-learn/phase4/pkg/money"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ShippingZoneRequest 配送区域请求，创建和修改共用
+type ShippingZoneRequest struct {
+	Name        string       `json:"name" binding:"required,max=100"`
+	Regions     string       `json:"regions" binding:"max=500"` // 适用的省份，逗号分隔，为空表示其他地区
+	Method      string       `json:"method" binding:"required,oneof=flat weight"`
+	Currency    string       `json:"currency"`
+	Fee         money.Amount `json:"fee"`
+	FirstWeight int          `json:"first_weight"`
+	StepWeight  int          `json:"step_weight"`
+	StepFee     money.Amount `json:"step_fee"`
+	FreeAbove   money.Amount `json:"free_above"`
+}
+
+func (r *ShippingZoneRequest) zone() model.ShippingZone {
+	return model.ShippingZone{
+		Name:        r.Name,
+		Regions:     r.Regions,
+		Method:      r.Method,
+		Currency:    r.Currency,
+		Fee:         r.Fee,
+		FirstWeight: r.FirstWeight,
+		StepWeight:  r.StepWeight,
+		StepFee:     r.StepFee,
+		FreeAbove:   r.FreeAbove,
+	}
+}
+
+// SetTaxRateRequest 设置税率请求，region 和 tax_class 为空表示不限
+type SetTaxRateRequest struct {
+	Region   string `json:"region" binding:"max=50"`
+	TaxClass string `json:"tax_class" binding:"max=20"`
+	Rate     string `json:"rate" binding:"required"`
+}
+
+// ListShippingZones 获取全部配送区域
+func (s *Server) ListShippingZones(c *gin.Context) {
+	zones, err := s.service.Pricing.ListShippingZones()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": zones})
+}
+
+// CreateShippingZone 创建配送区域
+func (s *Server) CreateShippingZone(c *gin.Context) {
+	var req ShippingZoneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	zone, err := s.service.Pricing.CreateShippingZone(req.zone())
+	if err != nil {
+		c.JSON(pricingErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, zone)
+}
+
+// UpdateShippingZone 修改配送区域
+func (s *Server) UpdateShippingZone(c *gin.Context) {
+	id, err := model.ParseID[model.ShippingZone](c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的配送区域ID"})
+		return
+	}
+
+	var req ShippingZoneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	zone, err := s.service.Pricing.UpdateShippingZone(id, req.zone())
+	if err != nil {
+		c.JSON(pricingErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, zone)
+}
+
+// DeleteShippingZone 删除配送区域
+func (s *Server) DeleteShippingZone(c *gin.Context) {
+	id, err := model.ParseID[model.ShippingZone](c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的配送区域ID"})
+		return
+	}
+
+	if err := s.service.Pricing.DeleteShippingZone(id); err != nil {
+		c.JSON(pricingErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// ListTaxRates 获取全部税率
+func (s *Server) ListTaxRates(c *gin.Context) {
+	rates, err := s.service.Pricing.ListTaxRates()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": rates})
+}
+
+// SetTaxRate 新增或更新税率
+func (s *Server) SetTaxRate(c *gin.Context) {
+	var req SetTaxRateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rate, err := s.service.Pricing.SetTaxRate(req.Region, req.TaxClass, req.Rate)
+	if err != nil {
+		c.JSON(pricingErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rate)
+}
+
+// DeleteTaxRate 删除税率
+func (s *Server) DeleteTaxRate(c *gin.Context) {
+	id, err := model.ParseID[model.TaxRate](c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的税率ID"})
+		return
+	}
+
+	if err := s.service.Pricing.DeleteTaxRate(id); err != nil {
+		c.JSON(pricingErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// pricingErrorStatus 将运费和税率配置错误映射为HTTP状态码
+func pricingErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrShippingZoneNotFound), errors.Is(err, service.ErrTaxRateNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidShippingZone), errors.Is(err, service.ErrInvalidTaxRate),
+		errors.Is(err, service.ErrInvalidCurrency):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
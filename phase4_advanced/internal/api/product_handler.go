@@ -1,19 +1,29 @@
 package api
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
+	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/internal/repository"
+	"gin-learn/phase4/internal/service"
+	"gin-learn/phase4/pkg/money"
+
 	"github.com/gin-gonic/gin"
 )
 
 // 产品请求结构体
 type CreateProductRequest struct {
-	Name        string  `json:"name" binding:"required"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price" binding:"required,gt=0"`
-	Stock       int     `json:"stock" binding:"gte=0"`
-	CategoryID  uint    `json:"category_id"`
+	Name         string                   `json:"name" binding:"required"`
+	Description  string                   `json:"description"`
+	Price        money.Amount             `json:"price" binding:"required,gt=0"`
+	Currency     string                   `json:"currency"`
+	Stock        int                      `json:"stock" binding:"gte=0"`
+	ReorderPoint int                      `json:"reorder_point" binding:"gte=0"` // 补货点，0 表示不告警
+	Weight       int                      `json:"weight" binding:"gte=0"`        // 重量（克）
+	TaxClass     string                   `json:"tax_class" binding:"max=20"`    // 税类，为空时使用 standard
+	CategoryID   model.ID[model.Category] `json:"category_id"`
 }
 
 // CreateProduct 创建产品
@@ -24,9 +34,9 @@ func (s *Server) CreateProduct(c *gin.Context) {
 		return
 	}
 
-	product, err := s.service.Product.CreateProduct(req.Name, req.Description, req.Price, req.Stock, req.CategoryID)
+	product, err := s.service.Product.CreateProduct(req.Name, req.Description, req.Price, req.Currency, req.Stock, req.ReorderPoint, req.Weight, req.TaxClass, req.CategoryID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(currencyErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -35,26 +45,34 @@ func (s *Server) CreateProduct(c *gin.Context) {
 
 // GetProduct 获取产品详情
 func (s *Server) GetProduct(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	id, err := model.ParseID[model.Product](c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的产品ID"})
 		return
 	}
 
-	product, err := s.service.Product.GetProduct(uint(id))
+	product, err := s.service.Product.GetProduct(id)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "产品不存在"})
 		return
 	}
 
-	c.JSON(http.StatusOK, product)
+	products := []model.Product{*product}
+	if !s.localizeProducts(c, products) {
+		return
+	}
+
+	c.JSON(http.StatusOK, products[0])
 }
 
-// ListProducts 获取产品列表
+// ListProducts 获取产品列表，可通过 options[规格类型]=取值 按规格过滤
 func (s *Server) ListProducts(c *gin.Context) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
-	categoryID, _ := strconv.ParseUint(c.DefaultQuery("category_id", "0"), 10, 32)
+	categoryID, ok := queryID[model.Category](c, "category_id", "无效的分类ID")
+	if !ok {
+		return
+	}
 	keyword := c.Query("keyword")
 
 	if page < 1 {
@@ -64,12 +82,16 @@ func (s *Server) ListProducts(c *gin.Context) {
 		pageSize = 10
 	}
 
-	products, total, err := s.service.Product.ListProducts(page, pageSize, uint(categoryID), keyword)
+	products, total, err := s.service.Product.ListProducts(page, pageSize, categoryID, keyword, c.QueryMap("options"))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	if !s.localizeProducts(c, products) {
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"data":      products,
 		"total":     total,
@@ -80,7 +102,7 @@ func (s *Server) ListProducts(c *gin.Context) {
 
 // UpdateProduct 更新产品
 func (s *Server) UpdateProduct(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	id, err := model.ParseID[model.Product](c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的产品ID"})
 		return
@@ -92,7 +114,7 @@ func (s *Server) UpdateProduct(c *gin.Context) {
 		return
 	}
 
-	if err := s.service.Product.UpdateProduct(uint(id), updates); err != nil {
+	if err := s.service.Product.UpdateProduct(id, updates); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -102,13 +124,13 @@ func (s *Server) UpdateProduct(c *gin.Context) {
 
 // DeleteProduct 删除产品
 func (s *Server) DeleteProduct(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	id, err := model.ParseID[model.Product](c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的产品ID"})
 		return
 	}
 
-	if err := s.service.Product.DeleteProduct(uint(id)); err != nil {
+	if err := s.service.Product.DeleteProduct(id); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -116,23 +138,30 @@ func (s *Server) DeleteProduct(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "产品已删除"})
 }
 
-// SearchProducts 搜索产品
+// SearchProducts 搜索产品，可通过 options[规格类型]=取值 按规格过滤
 func (s *Server) SearchProducts(c *gin.Context) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
-	minPrice, _ := strconv.ParseFloat(c.DefaultQuery("min_price", "0"), 64)
-	maxPrice, _ := strconv.ParseFloat(c.DefaultQuery("max_price", "0"), 64)
-	categoryID, _ := strconv.ParseUint(c.DefaultQuery("category_id", "0"), 10, 32)
+	minPrice, _ := money.Parse(c.DefaultQuery("min_price", "0"))
+	maxPrice, _ := money.Parse(c.DefaultQuery("max_price", "0"))
+	categoryID, ok := queryID[model.Category](c, "category_id", "无效的分类ID")
+	if !ok {
+		return
+	}
 	keyword := c.Query("keyword")
 	sortBy := c.DefaultQuery("sort_by", "id")
 	sortOrder := c.DefaultQuery("sort_order", "asc")
 
-	products, total, err := s.service.Product.SearchProducts(minPrice, maxPrice, uint(categoryID), keyword, sortBy, sortOrder, page, pageSize)
+	products, total, err := s.service.Product.SearchProducts(minPrice, maxPrice, categoryID, keyword, c.QueryMap("options"), sortBy, sortOrder, page, pageSize)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	if !s.localizeProducts(c, products) {
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"data":      products,
 		"total":     total,
@@ -140,3 +169,30 @@ func (s *Server) SearchProducts(c *gin.Context) {
 		"page_size": pageSize,
 	})
 }
+
+// localizeProducts 按 currency 查询参数换算产品价格，失败时直接返回400
+func (s *Server) localizeProducts(c *gin.Context, products []model.Product) bool {
+	currency := c.Query("currency")
+	if currency == "" {
+		return true
+	}
+
+	if err := s.service.Currency.LocalizeProducts(products, currency); err != nil {
+		c.JSON(currencyErrorStatus(err), gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+// currencyErrorStatus 将货币和汇率错误映射为HTTP状态码
+func currencyErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrInvalidCurrency), errors.Is(err, repository.ErrRateNotFound),
+		errors.Is(err, money.ErrInvalidRate):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrRateNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
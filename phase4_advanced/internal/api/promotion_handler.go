@@ -0,0 +1,137 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PromotionRequest 促销活动请求，创建和修改共用
+type PromotionRequest struct {
+	Name         string                   `json:"name" binding:"required,max=100"`
+	BuyQuantity  int                      `json:"buy_quantity" binding:"required,min=1"`
+	FreeQuantity int                      `json:"free_quantity" binding:"required,min=1"`
+	CategoryID   model.ID[model.Category] `json:"category_id"`
+	ProductID    model.ID[model.Product]  `json:"product_id"`
+	StartsAt     *time.Time               `json:"starts_at"`
+	EndsAt       *time.Time               `json:"ends_at"`
+}
+
+func (r *PromotionRequest) promotion() model.Promotion {
+	return model.Promotion{
+		Name:         r.Name,
+		BuyQuantity:  r.BuyQuantity,
+		FreeQuantity: r.FreeQuantity,
+		CategoryID:   r.CategoryID,
+		ProductID:    r.ProductID,
+		StartsAt:     r.StartsAt,
+		EndsAt:       r.EndsAt,
+	}
+}
+
+// ListPromotions 获取全部促销活动
+func (s *Server) ListPromotions(c *gin.Context) {
+	promotions, err := s.service.Promotion.ListPromotions()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": promotions})
+}
+
+// CreatePromotion 创建促销活动
+func (s *Server) CreatePromotion(c *gin.Context) {
+	var req PromotionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	promotion, err := s.service.Promotion.CreatePromotion(req.promotion())
+	if err != nil {
+		c.JSON(promotionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, promotion)
+}
+
+// GetPromotion 获取促销活动详情
+func (s *Server) GetPromotion(c *gin.Context) {
+	id, ok := promotionID(c)
+	if !ok {
+		return
+	}
+
+	promotion, err := s.service.Promotion.GetPromotion(id)
+	if err != nil {
+		c.JSON(promotionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, promotion)
+}
+
+// UpdatePromotion 修改促销活动
+func (s *Server) UpdatePromotion(c *gin.Context) {
+	id, ok := promotionID(c)
+	if !ok {
+		return
+	}
+
+	var req PromotionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	promotion, err := s.service.Promotion.UpdatePromotion(id, req.promotion())
+	if err != nil {
+		c.JSON(promotionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, promotion)
+}
+
+// DeletePromotion 删除促销活动
+func (s *Server) DeletePromotion(c *gin.Context) {
+	id, ok := promotionID(c)
+	if !ok {
+		return
+	}
+
+	if err := s.service.Promotion.DeletePromotion(id); err != nil {
+		c.JSON(promotionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+func promotionID(c *gin.Context) (model.ID[model.Promotion], bool) {
+	id, err := model.ParseID[model.Promotion](c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的促销活动ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// promotionErrorStatus 将促销活动服务错误映射为HTTP状态码
+func promotionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrPromotionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidPromotion):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/internal/repository"
+	"gin-learn/phase4/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateRefundRequest 退款请求，items 为空时退还订单剩余的全部金额和商品
+type CreateRefundRequest struct {
+	Items   []service.RefundItemInput `json:"items" binding:"dive"`
+	Restock bool                      `json:"restock"`
+	Reason  string                    `json:"reason" binding:"max=255"`
+}
+
+// CreateRefund 创建退款
+func (s *Server) CreateRefund(c *gin.Context) {
+	id, err := model.ParseID[model.Order](c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的订单ID"})
+		return
+	}
+
+	var req CreateRefundRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	refund, err := s.service.Refund.CreateRefund(id, currentUserID(c), service.RefundInput{
+		Items:   req.Items,
+		Restock: req.Restock,
+		Reason:  req.Reason,
+	})
+	if err != nil {
+		c.JSON(refundErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, refund)
+}
+
+// ListRefunds 获取订单的退款记录
+func (s *Server) ListRefunds(c *gin.Context) {
+	id, err := model.ParseID[model.Order](c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的订单ID"})
+		return
+	}
+
+	refunds, err := s.service.Refund.ListRefunds(id, currentUserID(c), hasPermission(c, model.PermOrderManage))
+	if err != nil {
+		c.JSON(orderErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": refunds})
+}
+
+// refundErrorStatus 将退款错误映射为HTTP状态码
+func refundErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, repository.ErrRefundExceeded), errors.Is(err, repository.ErrRestockExceeded),
+		errors.Is(err, repository.ErrRefundNotPending):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrPaymentProviderNotFound):
+		return http.StatusInternalServerError
+	default:
+		return orderErrorStatus(err)
+	}
+}
@@ -0,0 +1,174 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/internal/repository"
+	"gin-learn/phase4/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateReturnRequest 退货申请请求
+type CreateReturnRequest struct {
+	ReasonCode  string                    `json:"reason_code" binding:"required,oneof=damaged defective wrong_item not_as_described no_longer_needed other"`
+	Description string                    `json:"description" binding:"max=500"`
+	Items       []service.ReturnItemInput `json:"items" binding:"required,min=1,dive"`
+}
+
+// ReviewReturnRequest 审核退货申请请求，请求体可省略
+type ReviewReturnRequest struct {
+	Note string `json:"note" binding:"max=255"`
+}
+
+// ReceiveReturnRequest 确认收货请求，restock 省略时默认退回库存
+type ReceiveReturnRequest struct {
+	Restock *bool `json:"restock"`
+}
+
+// CreateReturn 为订单申请退货
+func (s *Server) CreateReturn(c *gin.Context) {
+	id, err := model.ParseID[model.Order](c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的订单ID"})
+		return
+	}
+
+	var req CreateReturnRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ret, err := s.service.Return.CreateReturn(id, currentUserID(c), hasPermission(c, model.PermOrderManage),
+		req.ReasonCode, req.Description, req.Items)
+	if err != nil {
+		c.JSON(returnErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, ret)
+}
+
+// ListReturns 获取订单的退货申请
+func (s *Server) ListReturns(c *gin.Context) {
+	id, err := model.ParseID[model.Order](c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的订单ID"})
+		return
+	}
+
+	returns, err := s.service.Return.ListReturns(id, currentUserID(c), hasPermission(c, model.PermOrderManage))
+	if err != nil {
+		c.JSON(returnErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": returns})
+}
+
+// GetReturn 获取退货申请详情
+func (s *Server) GetReturn(c *gin.Context) {
+	orderID, returnID, ok := returnIDs(c)
+	if !ok {
+		return
+	}
+
+	ret, err := s.service.Return.GetReturn(orderID, returnID, currentUserID(c), hasPermission(c, model.PermOrderManage))
+	if err != nil {
+		c.JSON(returnErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, ret)
+}
+
+// ApproveReturn 同意退货申请
+func (s *Server) ApproveReturn(c *gin.Context) {
+	s.reviewReturn(c, s.service.Return.ApproveReturn)
+}
+
+// RejectReturn 拒绝退货申请
+func (s *Server) RejectReturn(c *gin.Context) {
+	s.reviewReturn(c, s.service.Return.RejectReturn)
+}
+
+// ReceiveReturn 确认收到退回的商品并退款
+func (s *Server) ReceiveReturn(c *gin.Context) {
+	orderID, returnID, ok := returnIDs(c)
+	if !ok {
+		return
+	}
+
+	var req ReceiveReturnRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	restock := req.Restock == nil || *req.Restock
+
+	ret, err := s.service.Return.ReceiveReturn(orderID, returnID, currentUserID(c), restock)
+	if err != nil {
+		c.JSON(returnErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, ret)
+}
+
+// reviewReturn 解析可选的审核意见并执行审核操作
+func (s *Server) reviewReturn(c *gin.Context, review func(orderID model.ID[model.Order], returnID model.ID[model.ReturnRequest], actorID model.ID[model.User], note string) (*model.ReturnRequest, error)) {
+	orderID, returnID, ok := returnIDs(c)
+	if !ok {
+		return
+	}
+
+	var req ReviewReturnRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	ret, err := review(orderID, returnID, currentUserID(c), req.Note)
+	if err != nil {
+		c.JSON(returnErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, ret)
+}
+
+// returnIDs 解析路径中的订单ID和退货申请ID，无效时直接返回 400
+func returnIDs(c *gin.Context) (model.ID[model.Order], model.ID[model.ReturnRequest], bool) {
+	orderID, err := model.ParseID[model.Order](c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的订单ID"})
+		return 0, 0, false
+	}
+	returnID, err := model.ParseID[model.ReturnRequest](c.Param("return_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的退货申请ID"})
+		return 0, 0, false
+	}
+	return orderID, returnID, true
+}
+
+// returnErrorStatus 将退货错误映射为HTTP状态码
+func returnErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrReturnNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrReturnNotAllowed), errors.Is(err, service.ErrReturnTransition),
+		errors.Is(err, repository.ErrReturnExceeded),
+		errors.Is(err, repository.ErrReturnStatusConflict):
+		return http.StatusConflict
+	default:
+		return refundErrorStatus(err)
+	}
+}
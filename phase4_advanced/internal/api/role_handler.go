@@ -0,0 +1,135 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 角色请求结构体
+type CreateRoleRequest struct {
+	Name        string   `json:"name" binding:"required,max=50"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+type UpdateRoleRequest struct {
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+type AssignRolesRequest struct {
+	Roles []string `json:"roles" binding:"required"`
+}
+
+// ListRoles 获取角色列表
+func (s *Server) ListRoles(c *gin.Context) {
+	roles, err := s.service.Role.ListRoles()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, roles)
+}
+
+// CreateRole 创建角色
+func (s *Server) CreateRole(c *gin.Context) {
+	var req CreateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	role, err := s.service.Role.CreateRole(req.Name, req.Description, req.Permissions)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, role)
+}
+
+// UpdateRole 更新角色描述和权限
+func (s *Server) UpdateRole(c *gin.Context) {
+	id, err := model.ParseID[model.Role](c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的角色ID"})
+		return
+	}
+
+	var req UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	role, err := s.service.Role.UpdateRole(id, req.Description, req.Permissions)
+	if err != nil {
+		c.JSON(roleErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, role)
+}
+
+// DeleteRole 删除角色
+func (s *Server) DeleteRole(c *gin.Context) {
+	id, err := model.ParseID[model.Role](c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的角色ID"})
+		return
+	}
+
+	if err := s.service.Role.DeleteRole(id); err != nil {
+		c.JSON(roleErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "角色已删除"})
+}
+
+// ListPermissions 获取权限列表
+func (s *Server) ListPermissions(c *gin.Context) {
+	perms, err := s.service.Role.ListPermissions()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, perms)
+}
+
+// AssignUserRoles 设置用户角色
+func (s *Server) AssignUserRoles(c *gin.Context) {
+	id, err := model.ParseID[model.User](c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+		return
+	}
+
+	var req AssignRolesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.service.Role.AssignRoles(id, req.Roles); err != nil {
+		c.JSON(roleErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "用户角色已更新"})
+}
+
+// roleErrorStatus 将角色服务错误映射为HTTP状态码
+func roleErrorStatus(err error) int {
+	if errors.Is(err, service.ErrRoleNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
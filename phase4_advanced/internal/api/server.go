@@ -1,7 +1,13 @@
 package api
 
 import (
+	"context"
 	"fmt"
+	"net/http"
+	"time"
+
+	"gin-learn/phase4/config"
+	"gin-learn/phase4/internal/model"
 	"gin-learn/phase4/internal/service"
 	"gin-learn/phase4/pkg/logger"
 
@@ -44,15 +50,39 @@ func (s *Server) setupRoutes() {
 
 	// API v1
 	v1 := s.router.Group("/api/v1")
+	authRequired := JWTAuth(s.service.Auth)
+	idempotent := Idempotency(s.service.Idempotency)
 	{
+		// 认证路由
+		auth := v1.Group("/auth")
+		{
+			auth.POST("/register", idempotent, s.Register)
+			auth.POST("/login", s.Login)
+			auth.POST("/refresh", s.RefreshToken)
+			auth.POST("/logout", authRequired, s.Logout)
+		}
+
+		// 当前用户路由
+		me := v1.Group("/me", authRequired)
+		{
+			me.GET("", s.GetProfile)
+			me.PUT("/password", s.ChangePassword)
+			me.GET("/orders", s.ListMyOrders)
+			me.GET("/addresses", s.ListAddresses)
+			me.POST("/addresses", s.CreateAddress)
+			me.GET("/addresses/:id", s.GetAddress)
+			me.PUT("/addresses/:id", s.UpdateAddress)
+			me.DELETE("/addresses/:id", s.DeleteAddress)
+			me.PUT("/addresses/:id/default", s.SetDefaultAddress)
+		}
+
 		// 用户路由
-		users := v1.Group("/users")
+		users := v1.Group("/users", authRequired)
 		{
-			users.GET("", s.ListUsers)
-			users.GET("/:id", s.GetUser)
-			users.POST("", s.Register)
-			users.PUT("/:id", s.UpdateUser)
-			users.DELETE("/:id", s.DeleteUser)
+			users.GET("", RequirePermission(model.PermUserRead), s.ListUsers)
+			users.GET("/:id", RequirePermission(model.PermUserRead), s.GetUser)
+			users.PUT("/:id", RequirePermission(model.PermUserWrite), s.UpdateUser)
+			users.DELETE("/:id", RequirePermission(model.PermUserWrite), s.DeleteUser)
 		}
 
 		// 产品路由
@@ -60,9 +90,14 @@ func (s *Server) setupRoutes() {
 		{
 			products.GET("", s.ListProducts)
 			products.GET("/:id", s.GetProduct)
-			products.POST("", s.CreateProduct)
-			products.PUT("/:id", s.UpdateProduct)
-			products.DELETE("/:id", s.DeleteProduct)
+			products.POST("", authRequired, RequirePermission(model.PermProductWrite), idempotent, s.CreateProduct)
+			products.PUT("/:id", authRequired, RequirePermission(model.PermProductWrite), s.UpdateProduct)
+			products.DELETE("/:id", authRequired, RequirePermission(model.PermProductWrite), s.DeleteProduct)
+			products.POST("/:id/options", authRequired, RequirePermission(model.PermProductWrite), s.SaveProductOption)
+			products.POST("/:id/variants", authRequired, RequirePermission(model.PermProductWrite), s.CreateVariant)
+			products.PUT("/:id/variants/:variant_id", authRequired, RequirePermission(model.PermProductWrite), s.UpdateVariant)
+			products.POST("/:id/stock-adjustments", authRequired, RequirePermission(model.PermProductWrite), s.AdjustStock)
+			products.GET("/:id/stock-movements", authRequired, RequirePermission(model.PermProductWrite), s.ListStockMovements)
 		}
 
 		// 分类路由
@@ -70,16 +105,132 @@ func (s *Server) setupRoutes() {
 		{
 			categories.GET("", s.ListCategories)
 			categories.GET("/:id", s.GetCategory)
-			categories.POST("", s.CreateCategory)
+			categories.POST("", authRequired, RequirePermission(model.PermCategoryWrite), s.CreateCategory)
 		}
 
 		// 订单路由
-		orders := v1.Group("/orders")
+		orders := v1.Group("/orders", authRequired)
 		{
-			orders.GET("", s.ListOrders)
-			orders.POST("", s.CreateOrder)
+			orders.GET("", RequirePermission(model.PermOrderManage), s.ListOrders)
+			orders.POST("", idempotent, s.CreateOrder)
+			orders.POST("/quote", s.QuoteOrder)
+			orders.GET("/number/:order_no", s.GetOrderByNumber)
 			orders.GET("/:id", s.GetOrder)
 			orders.POST("/:id/cancel", s.CancelOrder)
+			orders.POST("/:id/pay", idempotent, s.PayOrder)
+			orders.GET("/:id/payments", s.ListOrderPayments)
+			orders.POST("/:id/refunds", RequirePermission(model.PermOrderManage), idempotent, s.CreateRefund)
+			orders.GET("/:id/refunds", s.ListRefunds)
+			orders.POST("/:id/ship", RequirePermission(model.PermOrderManage), s.ShipOrder)
+			orders.POST("/:id/shipments", RequirePermission(model.PermOrderManage), s.CreateShipment)
+			orders.GET("/:id/shipments", s.ListShipments)
+			orders.PUT("/:id/shipments/:shipment_id", RequirePermission(model.PermOrderManage), s.UpdateShipment)
+			orders.POST("/:id/returns", s.CreateReturn)
+			orders.GET("/:id/returns", s.ListReturns)
+			orders.GET("/:id/returns/:return_id", s.GetReturn)
+			orders.POST("/:id/returns/:return_id/approve", RequirePermission(model.PermOrderManage), s.ApproveReturn)
+			orders.POST("/:id/returns/:return_id/reject", RequirePermission(model.PermOrderManage), s.RejectReturn)
+			orders.POST("/:id/returns/:return_id/receive", RequirePermission(model.PermOrderManage), idempotent, s.ReceiveReturn)
+			orders.POST("/:id/deliver", RequirePermission(model.PermOrderManage), s.DeliverOrder)
+			orders.POST("/:id/complete", s.CompleteOrder)
+		}
+
+		// 汇率路由
+		rates := v1.Group("/exchange-rates")
+		{
+			rates.GET("", s.ListExchangeRates)
+			rates.PUT("", authRequired, RequirePermission(model.PermProductWrite), s.SetExchangeRate)
+			rates.POST("/import", authRequired, RequirePermission(model.PermProductWrite), s.ImportExchangeRates)
+			rates.DELETE("/:id", authRequired, RequirePermission(model.PermProductWrite), s.DeleteExchangeRate)
+		}
+
+		// 配送区域路由
+		zones := v1.Group("/shipping-zones")
+		{
+			zones.GET("", s.ListShippingZones)
+			zones.POST("", authRequired, RequirePermission(model.PermProductWrite), s.CreateShippingZone)
+			zones.PUT("/:id", authRequired, RequirePermission(model.PermProductWrite), s.UpdateShippingZone)
+			zones.DELETE("/:id", authRequired, RequirePermission(model.PermProductWrite), s.DeleteShippingZone)
+		}
+
+		// 税率路由
+		taxes := v1.Group("/tax-rates")
+		{
+			taxes.GET("", s.ListTaxRates)
+			taxes.PUT("", authRequired, RequirePermission(model.PermProductWrite), s.SetTaxRate)
+			taxes.DELETE("/:id", authRequired, RequirePermission(model.PermProductWrite), s.DeleteTaxRate)
+		}
+
+		// 仓库路由
+		warehouses := v1.Group("/warehouses", authRequired, RequirePermission(model.PermProductWrite))
+		{
+			warehouses.GET("", s.ListWarehouses)
+			warehouses.POST("", s.CreateWarehouse)
+			warehouses.POST("/transfers", s.TransferStock)
+			warehouses.GET("/:id", s.GetWarehouse)
+			warehouses.PUT("/:id", s.UpdateWarehouse)
+			warehouses.GET("/:id/stocks", s.ListWarehouseStocks)
+		}
+
+		// 低库存告警路由
+		alerts := v1.Group("/admin/stock-alerts", authRequired, RequirePermission(model.PermProductWrite))
+		{
+			alerts.GET("", s.ListStockAlerts)
+			alerts.POST("/:id/resolve", s.ResolveStockAlert)
+		}
+
+		// 优惠券路由
+		coupons := v1.Group("/coupons", authRequired, RequirePermission(model.PermPromotionManage))
+		{
+			coupons.GET("", s.ListCoupons)
+			coupons.POST("", s.CreateCoupon)
+			coupons.GET("/:id", s.GetCoupon)
+			coupons.PUT("/:id", s.UpdateCoupon)
+			coupons.DELETE("/:id", s.DeleteCoupon)
+		}
+
+		// 促销活动路由
+		promotions := v1.Group("/promotions")
+		{
+			promotions.GET("", s.ListPromotions)
+			promotions.GET("/:id", s.GetPromotion)
+			promotions.POST("", authRequired, RequirePermission(model.PermPromotionManage), s.CreatePromotion)
+			promotions.PUT("/:id", authRequired, RequirePermission(model.PermPromotionManage), s.UpdatePromotion)
+			promotions.DELETE("/:id", authRequired, RequirePermission(model.PermPromotionManage), s.DeletePromotion)
+		}
+
+		// 支付路由
+		payments := v1.Group("/payments")
+		{
+			payments.POST("/webhook/:provider", s.PaymentWebhook)
+			payments.GET("/orphaned", authRequired, RequirePermission(model.PermOrderManage), s.ListOrphanedPayments)
+			payments.POST("/:id/refund", authRequired, RequirePermission(model.PermOrderManage), s.RefundOrphanedPayment)
+			// 模拟支付结果会绕过真实付款，只在使用模拟渠道且显式开启时注册
+			if config.C.Payment.Provider == "mock" && config.C.Payment.Mock.SimulateEnabled {
+				payments.POST("/mock/simulate", authRequired, s.SimulatePayment)
+			}
+		}
+
+		// 购物车路由
+		cart := v1.Group("/cart", authRequired)
+		{
+			cart.GET("", s.GetCart)
+			cart.DELETE("", s.ClearCart)
+			cart.POST("/items", s.AddCartItem)
+			cart.PUT("/items/:id", s.UpdateCartItem)
+			cart.DELETE("/items/:id", s.RemoveCartItem)
+			cart.POST("/checkout", idempotent, s.Checkout)
+		}
+
+		// 权限管理路由
+		admin := v1.Group("/admin", authRequired, RequirePermission(model.PermRoleManage))
+		{
+			admin.GET("/roles", s.ListRoles)
+			admin.POST("/roles", s.CreateRole)
+			admin.PUT("/roles/:id", s.UpdateRole)
+			admin.DELETE("/roles/:id", s.DeleteRole)
+			admin.GET("/permissions", s.ListPermissions)
+			admin.PUT("/users/:id/roles", s.AssignUserRoles)
 		}
 
 		// 搜索路由
@@ -87,9 +238,29 @@ func (s *Server) setupRoutes() {
 	}
 }
 
-// Run 启动服务器
-func (s *Server) Run(port int) error {
-	addr := fmt.Sprintf(":%d", port)
-	logger.Info("Starting HTTP server", logger.String("address", addr))
-	return s.router.Run(addr)
+// Run 启动服务器，ctx 被取消后停止接收新请求，并等待处理中的请求完成
+func (s *Server) Run(ctx context.Context, port int) error {
+	srv := &http.Server{
+		Addr:         fmt.Sprintf(":%d", port),
+		Handler:      s.router,
+		ReadTimeout:  time.Duration(config.C.Server.ReadTimeout) * time.Second,
+		WriteTimeout: time.Duration(config.C.Server.WriteTimeout) * time.Second,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		logger.Info("Starting HTTP server", logger.String("address", srv.Addr))
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	logger.Info("Shutting down HTTP server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
 }
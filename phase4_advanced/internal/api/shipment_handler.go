@@ -0,0 +1,112 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateShipmentRequest 发货请求，items 为空时发出全部尚未发货的商品
+type CreateShipmentRequest struct {
+	WarehouseID    model.ID[model.Warehouse]   `json:"warehouse_id"`
+	Carrier        string                      `json:"carrier" binding:"max=50"`
+	TrackingNumber string                      `json:"tracking_number" binding:"max=100"`
+	Note           string                      `json:"note" binding:"max=255"`
+	Items          []service.ShipmentItemInput `json:"items" binding:"dive"`
+}
+
+// UpdateShipmentRequest 修改发货单的承运商和运单号
+type UpdateShipmentRequest struct {
+	Carrier        string `json:"carrier" binding:"max=50"`
+	TrackingNumber string `json:"tracking_number" binding:"max=100"`
+}
+
+// CreateShipment 为订单创建发货单
+func (s *Server) CreateShipment(c *gin.Context) {
+	id, err := model.ParseID[model.Order](c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的订单ID"})
+		return
+	}
+
+	var req CreateShipmentRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	shipment, err := s.service.Shipment.CreateShipment(id, currentUserID(c), service.ShipmentInput{
+		WarehouseID:    req.WarehouseID,
+		Carrier:        req.Carrier,
+		TrackingNumber: req.TrackingNumber,
+		Note:           req.Note,
+		Items:          req.Items,
+	})
+	if err != nil {
+		c.JSON(shipmentErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, shipment)
+}
+
+// ListShipments 获取订单的发货单
+func (s *Server) ListShipments(c *gin.Context) {
+	id, err := model.ParseID[model.Order](c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的订单ID"})
+		return
+	}
+
+	shipments, err := s.service.Shipment.ListShipments(id, currentUserID(c), hasPermission(c, model.PermOrderManage))
+	if err != nil {
+		c.JSON(orderErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": shipments})
+}
+
+// UpdateShipment 修改发货单的承运商和运单号
+func (s *Server) UpdateShipment(c *gin.Context) {
+	id, err := model.ParseID[model.Order](c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的订单ID"})
+		return
+	}
+	shipmentID, err := model.ParseID[model.Shipment](c.Param("shipment_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的发货单ID"})
+		return
+	}
+
+	var req UpdateShipmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	shipment, err := s.service.Shipment.UpdateTracking(id, shipmentID, req.Carrier, req.TrackingNumber)
+	if err != nil {
+		c.JSON(shipmentErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, shipment)
+}
+
+// shipmentErrorStatus 将发货错误映射为HTTP状态码
+func shipmentErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrShipmentNotFound), errors.Is(err, service.ErrWarehouseNotFound):
+		return http.StatusNotFound
+	default:
+		return orderErrorStatus(err)
+	}
+}
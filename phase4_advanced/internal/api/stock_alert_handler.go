@@ -0,0 +1,64 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListStockAlerts 分页获取低库存告警，可按 status 过滤（open、resolved）
+func (s *Server) ListStockAlerts(c *gin.Context) {
+	status := c.Query("status")
+	if status != "" && status != model.StockAlertOpen && status != model.StockAlertResolved {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的告警状态"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	alerts, total, err := s.service.StockAlert.ListAlerts(status, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":      alerts,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// ResolveStockAlert 手动关闭低库存告警
+func (s *Server) ResolveStockAlert(c *gin.Context) {
+	id, err := model.ParseID[model.StockAlert](c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的告警ID"})
+		return
+	}
+
+	if err := s.service.StockAlert.ResolveAlert(id, currentUserID(c)); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrStockAlertNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "告警已关闭"})
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/internal/repository"
+	"gin-learn/phase4/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdjustStockRequest 库存调整请求，delta 为正数时入库、负数时出库，未指定仓库时调整默认仓库。
+// 有规格的产品入库时必须指定规格
+type AdjustStockRequest struct {
+	VariantID   model.ID[model.ProductVariant] `json:"variant_id"`
+	WarehouseID model.ID[model.Warehouse]      `json:"warehouse_id"`
+	Delta       int                            `json:"delta" binding:"required"`
+	Note        string                         `json:"note" binding:"max=255"`
+}
+
+// AdjustStock 手动调整产品库存
+func (s *Server) AdjustStock(c *gin.Context) {
+	id, err := model.ParseID[model.Product](c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的产品ID"})
+		return
+	}
+
+	var req AdjustStockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	movement, err := s.service.Stock.AdjustStock(id, req.VariantID, req.WarehouseID, currentUserID(c), req.Delta, req.Note)
+	if err != nil {
+		c.JSON(stockErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, movement)
+}
+
+// ListStockMovements 分页获取产品的库存流水，可按 warehouse_id 过滤
+func (s *Server) ListStockMovements(c *gin.Context) {
+	id, err := model.ParseID[model.Product](c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的产品ID"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	warehouseID, ok := queryID[model.Warehouse](c, "warehouse_id", "无效的仓库ID")
+	if !ok {
+		return
+	}
+
+	movements, total, err := s.service.Stock.ListMovements(id, warehouseID, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":      movements,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// stockErrorStatus 将库存服务错误映射为HTTP状态码
+func stockErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrProductNotFound), errors.Is(err, service.ErrWarehouseNotFound),
+		errors.Is(err, repository.ErrVariantNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, repository.ErrInsufficientStock):
+		return http.StatusConflict
+	case errors.Is(err, repository.ErrVariantRequired):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
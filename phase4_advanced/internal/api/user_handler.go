@@ -4,6 +4,8 @@ import (
 	"net/http"
 	"strconv"
 
+	"gin-learn/phase4/internal/model"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -11,10 +13,15 @@ import (
 type RegisterRequest struct {
 	Username string `json:"username" binding:"required,min=3,max=50"`
 	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=6"`
+	Password string `json:"password" binding:"required,min=6,max=72"`
 	Age      int    `json:"age" binding:"gte=0,lte=150"`
 }
 
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=6,max=72"`
+}
+
 // Register 用户注册
 func (s *Server) Register(c *gin.Context) {
 	var req RegisterRequest
@@ -34,13 +41,13 @@ func (s *Server) Register(c *gin.Context) {
 
 // GetUser 获取用户详情
 func (s *Server) GetUser(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	id, err := model.ParseID[model.User](c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
 		return
 	}
 
-	user, err := s.service.User.GetUser(uint(id))
+	user, err := s.service.User.GetUser(id)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
 		return
@@ -78,7 +85,7 @@ func (s *Server) ListUsers(c *gin.Context) {
 
 // UpdateUser 更新用户
 func (s *Server) UpdateUser(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	id, err := model.ParseID[model.User](c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
 		return
@@ -90,7 +97,7 @@ func (s *Server) UpdateUser(c *gin.Context) {
 		return
 	}
 
-	if err := s.service.User.UpdateUser(uint(id), updates); err != nil {
+	if err := s.service.User.UpdateUser(id, updates); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -100,16 +107,32 @@ func (s *Server) UpdateUser(c *gin.Context) {
 
 // DeleteUser 删除用户
 func (s *Server) DeleteUser(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	id, err := model.ParseID[model.User](c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
 		return
 	}
 
-	if err := s.service.User.DeleteUser(uint(id)); err != nil {
+	if err := s.service.User.DeleteUser(id); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "用户已删除"})
 }
+
+// ChangePassword 修改当前用户密码
+func (s *Server) ChangePassword(c *gin.Context) {
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.service.User.ChangePassword(currentUserID(c), req.OldPassword, req.NewPassword); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "密码修改成功"})
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/internal/repository"
+	"gin-learn/phase4/internal/service"
+	"gin-learn/phase4/pkg/money"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SaveProductOptionRequest 新增规格类型或追加可选值
+type SaveProductOptionRequest struct {
+	Name   string   `json:"name" binding:"required,max=50"`
+	Values []string `json:"values" binding:"required,min=1,dive,max=50"`
+}
+
+// CreateVariantRequest 创建规格请求，options 为规格类型名称到取值的映射，如 {"尺码": "M", "颜色": "红"}。
+// price 为空时使用产品价格，初始库存入库到 warehouse_id 指定的仓库，未指定时使用默认仓库
+type CreateVariantRequest struct {
+	SKU         string                    `json:"sku" binding:"required,max=64"`
+	Price       money.Amount              `json:"price" binding:"gte=0"`
+	Stock       int                       `json:"stock" binding:"gte=0"`
+	WarehouseID model.ID[model.Warehouse] `json:"warehouse_id"`
+	Options     map[string]string         `json:"options" binding:"required,min=1"`
+}
+
+// UpdateVariantRequest 修改规格的SKU或价格，price 为 0 时恢复使用产品价格
+type UpdateVariantRequest struct {
+	SKU   *string       `json:"sku" binding:"omitempty,max=64"`
+	Price *money.Amount `json:"price"`
+}
+
+// SaveProductOption 为产品新增规格类型或追加可选值
+func (s *Server) SaveProductOption(c *gin.Context) {
+	id, err := model.ParseID[model.Product](c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的产品ID"})
+		return
+	}
+
+	var req SaveProductOptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	option, err := s.service.Product.SaveOption(id, req.Name, req.Values)
+	if err != nil {
+		c.JSON(variantErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, option)
+}
+
+// CreateVariant 为产品创建规格
+func (s *Server) CreateVariant(c *gin.Context) {
+	id, err := model.ParseID[model.Product](c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的产品ID"})
+		return
+	}
+
+	var req CreateVariantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	variant, err := s.service.Product.CreateVariant(id, currentUserID(c), service.VariantInput{
+		SKU:         req.SKU,
+		Price:       req.Price,
+		Stock:       req.Stock,
+		WarehouseID: req.WarehouseID,
+		Options:     req.Options,
+	})
+	if err != nil {
+		c.JSON(variantErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, variant)
+}
+
+// UpdateVariant 修改规格的SKU或价格
+func (s *Server) UpdateVariant(c *gin.Context) {
+	id, err := model.ParseID[model.Product](c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的产品ID"})
+		return
+	}
+	variantID, err := model.ParseID[model.ProductVariant](c.Param("variant_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的规格ID"})
+		return
+	}
+
+	var req UpdateVariantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	variant, err := s.service.Product.UpdateVariant(id, variantID, req.SKU, req.Price)
+	if err != nil {
+		c.JSON(variantErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, variant)
+}
+
+// variantErrorStatus 将规格相关错误映射为HTTP状态码
+func variantErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrProductNotFound), errors.Is(err, service.ErrWarehouseNotFound),
+		errors.Is(err, repository.ErrVariantNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, repository.ErrVariantExists), errors.Is(err, repository.ErrOptionsLocked),
+		errors.Is(err, repository.ErrProductHasStock):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrInvalidOption), errors.Is(err, service.ErrInvalidSKU),
+		errors.Is(err, repository.ErrVariantOptions), errors.Is(err, money.ErrInvalidAmount):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
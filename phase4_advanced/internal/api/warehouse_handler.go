@@ -0,0 +1,185 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/internal/repository"
+	"gin-learn/phase4/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WarehouseRequest 仓库请求，创建和修改共用，Priority 越小越优先分配
+type WarehouseRequest struct {
+	Code     string `json:"code" binding:"required,max=20"`
+	Name     string `json:"name" binding:"required,max=100"`
+	Priority int    `json:"priority"`
+}
+
+func (r *WarehouseRequest) warehouse() model.Warehouse {
+	return model.Warehouse{
+		Code:     r.Code,
+		Name:     r.Name,
+		Priority: r.Priority,
+	}
+}
+
+// TransferStockRequest 仓库间调拨请求，有规格的产品必须指定规格
+type TransferStockRequest struct {
+	ProductID       model.ID[model.Product]        `json:"product_id" binding:"required"`
+	VariantID       model.ID[model.ProductVariant] `json:"variant_id"`
+	FromWarehouseID model.ID[model.Warehouse]      `json:"from_warehouse_id" binding:"required"`
+	ToWarehouseID   model.ID[model.Warehouse]      `json:"to_warehouse_id" binding:"required"`
+	Quantity        int                            `json:"quantity" binding:"required,min=1"`
+	Note            string                         `json:"note" binding:"max=255"`
+}
+
+// ListWarehouses 按分配顺序获取全部仓库
+func (s *Server) ListWarehouses(c *gin.Context) {
+	warehouses, err := s.service.Warehouse.ListWarehouses()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": warehouses})
+}
+
+// CreateWarehouse 创建仓库
+func (s *Server) CreateWarehouse(c *gin.Context) {
+	var req WarehouseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	warehouse, err := s.service.Warehouse.CreateWarehouse(req.warehouse())
+	if err != nil {
+		c.JSON(warehouseErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, warehouse)
+}
+
+// GetWarehouse 获取仓库详情
+func (s *Server) GetWarehouse(c *gin.Context) {
+	id, ok := warehouseID(c)
+	if !ok {
+		return
+	}
+
+	warehouse, err := s.service.Warehouse.GetWarehouse(id)
+	if err != nil {
+		c.JSON(warehouseErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, warehouse)
+}
+
+// UpdateWarehouse 修改仓库
+func (s *Server) UpdateWarehouse(c *gin.Context) {
+	id, ok := warehouseID(c)
+	if !ok {
+		return
+	}
+
+	var req WarehouseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	warehouse, err := s.service.Warehouse.UpdateWarehouse(id, req.warehouse())
+	if err != nil {
+		c.JSON(warehouseErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, warehouse)
+}
+
+// ListWarehouseStocks 分页获取仓库中各产品的库存
+func (s *Server) ListWarehouseStocks(c *gin.Context) {
+	id, ok := warehouseID(c)
+	if !ok {
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	stocks, total, err := s.service.Warehouse.ListStocks(id, page, pageSize)
+	if err != nil {
+		c.JSON(warehouseErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":      stocks,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// TransferStock 将产品库存从一个仓库调拨到另一个仓库
+func (s *Server) TransferStock(c *gin.Context) {
+	var req TransferStockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	transfer, err := s.service.Warehouse.Transfer(model.StockTransfer{
+		ProductID:       req.ProductID,
+		VariantID:       req.VariantID,
+		FromWarehouseID: req.FromWarehouseID,
+		ToWarehouseID:   req.ToWarehouseID,
+		Quantity:        req.Quantity,
+		ActorID:         currentUserID(c),
+		Note:            req.Note,
+	})
+	if err != nil {
+		c.JSON(warehouseErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, transfer)
+}
+
+func warehouseID(c *gin.Context) (model.ID[model.Warehouse], bool) {
+	id, err := model.ParseID[model.Warehouse](c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的仓库ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// warehouseErrorStatus 将仓库服务错误映射为HTTP状态码
+func warehouseErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrWarehouseNotFound), errors.Is(err, service.ErrProductNotFound),
+		errors.Is(err, repository.ErrVariantNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrWarehouseExists), errors.Is(err, repository.ErrInsufficientStock):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrInvalidWarehouse), errors.Is(err, repository.ErrSameWarehouse),
+		errors.Is(err, repository.ErrVariantRequired):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
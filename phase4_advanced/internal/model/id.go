@@ -0,0 +1,94 @@
+package model
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"gin-learn/phase4/pkg/publicid"
+)
+
+// ID 主键和外键类型，T 为所属实体，如 ID[Order]。数据库中保存自增整数，对外（JSON、路径和查询参数）
+// 使用 publicid 编码的不透明字符串，0 表示未设置，序列化为 null。
+// 不同实体使用不同的编码，订单ID不能当作用户ID或产品ID使用
+type ID[T any] uint
+
+// ParseID 解析 T 实体对外公开的ID，空字符串返回 0，不接受整数形式的ID
+func ParseID[T any](s string) (ID[T], error) {
+	if s == "" {
+		return 0, nil
+	}
+	v, err := publicid.Decode(kind[T](), s)
+	if err != nil {
+		return 0, err
+	}
+	return ID[T](v), nil
+}
+
+// kind publicid 的实体名，取实体类型名
+func kind[T any]() string {
+	return reflect.TypeFor[T]().Name()
+}
+
+// String 对外公开的ID字符串，0 和超出编码范围的ID返回空字符串，序列化时使用 MarshalText 或 MarshalJSON 以得到错误
+func (id ID[T]) String() string {
+	s, _ := id.encode()
+	return s
+}
+
+// encode 编码为对外公开的ID字符串，0 返回空字符串，超出 32 位时返回 publicid.ErrOutOfRange
+func (id ID[T]) encode() (string, error) {
+	if id == 0 {
+		return "", nil
+	}
+	return publicid.EncodeUint(kind[T](), uint(id))
+}
+
+// MarshalText 实现 encoding.TextMarshaler，用于 JSON 对象的键
+func (id ID[T]) MarshalText() ([]byte, error) {
+	s, err := id.encode()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(s), nil
+}
+
+// UnmarshalText 实现 encoding.TextUnmarshaler
+func (id *ID[T]) UnmarshalText(data []byte) error {
+	v, err := ParseID[T](string(data))
+	if err != nil {
+		return err
+	}
+	*id = v
+	return nil
+}
+
+// MarshalJSON 序列化为字符串，0 序列化为 null
+func (id ID[T]) MarshalJSON() ([]byte, error) {
+	if id == 0 {
+		return []byte("null"), nil
+	}
+	s, err := id.encode()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(s)
+}
+
+// UnmarshalJSON 接受字符串、null 或空字符串，不接受 JSON 数字
+func (id *ID[T]) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*id = 0
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return publicid.ErrInvalid
+	}
+	return id.UnmarshalText([]byte(s))
+}
+
+// UnmarshalParam 实现 gin 的 binding.BindUnmarshaler，用于查询参数和表单绑定
+func (id *ID[T]) UnmarshalParam(param string) error {
+	return id.UnmarshalText([]byte(param))
+}
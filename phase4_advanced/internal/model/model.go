@@ -1,64 +1,679 @@
 package model
 
 import (
+	"encoding/json"
 	"time"
 
+	"gin-learn/phase4/pkg/money"
+
 	"gorm.io/gorm"
 )
 
 // User 用户模型
 type User struct {
-	ID        uint           `json:"id" gorm:"primarykey"`
+	ID        ID[User]       `json:"id" gorm:"primarykey"`
 	Username  string         `json:"username" gorm:"uniqueIndex;not null;size:50"`
 	Email     string         `json:"email" gorm:"uniqueIndex;not null;size:100"`
 	Password  string         `json:"-" gorm:"not null;size:255"`
 	Age       int            `json:"age"`
 	Status    int            `json:"status" gorm:"default:1;index"`
+	Roles     []Role         `json:"roles,omitempty" gorm:"many2many:user_roles"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
+// HasPermission 判断用户的任一角色是否拥有指定权限，需预加载 Roles.Permissions
+func (u *User) HasPermission(code string) bool {
+	for _, role := range u.Roles {
+		for _, perm := range role.Permissions {
+			if perm.Code == code {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 内置角色
+const (
+	RoleAdmin    = "admin"
+	RoleStaff    = "staff"
+	RoleCustomer = "customer"
+)
+
+// 权限编码
+const (
+	PermUserRead        = "user:read"
+	PermUserWrite       = "user:write"
+	PermProductWrite    = "product:write"
+	PermCategoryWrite   = "category:write"
+	PermOrderManage     = "order:manage"
+	PermRoleManage      = "role:manage"
+	PermPromotionManage = "promotion:manage"
+)
+
+// Role 角色模型
+type Role struct {
+	ID          ID[Role]     `json:"id" gorm:"primarykey"`
+	Name        string       `json:"name" gorm:"uniqueIndex;not null;size:50"`
+	Description string       `json:"description" gorm:"size:200"`
+	Builtin     bool         `json:"builtin" gorm:"default:false"`
+	Permissions []Permission `json:"permissions,omitempty" gorm:"many2many:role_permissions"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// Permission 权限模型
+type Permission struct {
+	ID          ID[Permission] `json:"id" gorm:"primarykey"`
+	Code        string         `json:"code" gorm:"uniqueIndex;not null;size:100"`
+	Description string         `json:"description" gorm:"size:200"`
+}
+
+// DefaultCurrency 默认货币，产品未指定基础货币或下单未指定货币时使用
+const DefaultCurrency = "CNY"
+
+// DefaultTaxClass 默认税类，产品未指定税类时使用
+const DefaultTaxClass = "standard"
+
 // Product 产品模型
 type Product struct {
-	ID          uint      `json:"id" gorm:"primarykey"`
-	Name        string    `json:"name" gorm:"not null;size:200;index"`
-	Description string    `json:"description" gorm:"size:500"`
-	Price       float64   `json:"price" gorm:"not null;index"`
-	Stock       int       `json:"stock" gorm:"default:0"`
-	CategoryID  uint      `json:"category_id"`
-	Category    Category  `json:"category,omitempty" gorm:"foreignKey:CategoryID"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID           ID[Product]      `json:"id" gorm:"primarykey"`
+	Name         string           `json:"name" gorm:"not null;size:200;index"`
+	Description  string           `json:"description" gorm:"size:500"`
+	Price        money.Amount     `json:"price" gorm:"not null;index"`
+	Currency     string           `json:"currency" gorm:"size:3;not null;default:'CNY'"`                                             // 价格的基础货币
+	Stock        int              `json:"stock" gorm:"default:0;check:chk_products_stock,stock >= 0"`                                // 各仓库现有库存合计，支付后扣减
+	Reserved     int              `json:"reserved" gorm:"default:0;check:chk_products_reserved,reserved >= 0 AND reserved <= stock"` // 各仓库为待支付订单保留的库存合计
+	Available    int              `json:"available" gorm:"-"`                                                                        // 可售库存，Stock - Reserved
+	Weight       int              `json:"weight" gorm:"default:0"`                                                                   // 重量（克），用于按重量计算运费
+	TaxClass     string           `json:"tax_class" gorm:"size:20;default:'standard'"`                                               // 税类，与收货地区一起决定税率
+	ReorderPoint int              `json:"reorder_point" gorm:"default:0"`                                                            // 补货点，可售库存低于该值时触发低库存告警，0 表示不告警
+	CategoryID   ID[Category]     `json:"category_id"`
+	Category     Category         `json:"category,omitempty" gorm:"foreignKey:CategoryID"`
+	Stocks       []WarehouseStock `json:"warehouse_stocks,omitempty" gorm:"foreignKey:ProductID"`
+	Options      []ProductOption  `json:"options,omitempty" gorm:"foreignKey:ProductID"`  // 规格类型及其可选值
+	Variants     []ProductVariant `json:"variants,omitempty" gorm:"foreignKey:ProductID"` // 规格组合，有规格的产品按规格下单和管理库存
+	CreatedAt    time.Time        `json:"created_at"`
+	UpdatedAt    time.Time        `json:"updated_at"`
+}
+
+// AfterFind 计算可售库存
+func (p *Product) AfterFind(tx *gorm.DB) error {
+	p.Available = p.Stock - p.Reserved
+	return nil
+}
+
+// ProductOption 产品的规格类型，如尺码、颜色
+type ProductOption struct {
+	ID        ID[ProductOption]    `json:"id" gorm:"primarykey"`
+	ProductID ID[Product]          `json:"product_id" gorm:"not null;uniqueIndex:idx_product_option"`
+	Name      string               `json:"name" gorm:"size:50;not null;uniqueIndex:idx_product_option"`
+	Position  int                  `json:"position" gorm:"default:0"` // 显示顺序
+	Values    []ProductOptionValue `json:"values" gorm:"foreignKey:OptionID"`
+}
+
+// ProductOptionValue 规格类型的可选值，如尺码中的 M、L
+type ProductOptionValue struct {
+	ID       ID[ProductOptionValue] `json:"id" gorm:"primarykey"`
+	OptionID ID[ProductOption]      `json:"option_id" gorm:"not null;uniqueIndex:idx_option_value"`
+	Value    string                 `json:"value" gorm:"size:50;not null;uniqueIndex:idx_option_value"`
+	Position int                    `json:"position" gorm:"default:0"`
+}
+
+// ProductVariant 产品规格（SKU），每个规格类型各取一个值，拥有独立的SKU、价格和库存。
+// 产品的库存合计包含全部规格的库存
+type ProductVariant struct {
+	ID        ID[ProductVariant]   `json:"id" gorm:"primarykey"`
+	ProductID ID[Product]          `json:"product_id" gorm:"not null;index"`
+	SKU       string               `json:"sku" gorm:"size:64;not null;uniqueIndex"`
+	Price     money.Amount         `json:"price,omitempty" gorm:"default:0"`                                                                  // 覆盖产品价格，为 0 时使用产品价格
+	Stock     int                  `json:"stock" gorm:"default:0;check:chk_product_variants_stock,stock >= 0"`                                // 各仓库现有库存合计
+	Reserved  int                  `json:"reserved" gorm:"default:0;check:chk_product_variants_reserved,reserved >= 0 AND reserved <= stock"` // 各仓库为待支付订单保留的库存合计
+	Available int                  `json:"available" gorm:"-"`                                                                                // 可售库存，Stock - Reserved
+	Values    []ProductOptionValue `json:"values" gorm:"many2many:product_variant_values"`                                                    // 每个规格类型的取值
+	CreatedAt time.Time            `json:"created_at"`
+	UpdatedAt time.Time            `json:"updated_at"`
+}
+
+// AfterFind 计算可售库存
+func (v *ProductVariant) AfterFind(tx *gorm.DB) error {
+	v.Available = v.Stock - v.Reserved
+	return nil
+}
+
+// UnitPrice 规格的售价，未设置规格价格时使用产品价格
+func (v *ProductVariant) UnitPrice(product *Product) money.Amount {
+	if v.Price > 0 {
+		return v.Price
+	}
+	return product.Price
 }
 
 // Category 分类模型
 type Category struct {
-	ID          uint      `json:"id" gorm:"primarykey"`
-	Name        string    `json:"name" gorm:"uniqueIndex;not null;size:100"`
-	Description string    `json:"description" gorm:"size:500"`
-	Products    []Product `json:"products,omitempty" gorm:"foreignKey:CategoryID"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID          ID[Category] `json:"id" gorm:"primarykey"`
+	Name        string       `json:"name" gorm:"uniqueIndex;not null;size:100"`
+	Description string       `json:"description" gorm:"size:500"`
+	Products    []Product    `json:"products,omitempty" gorm:"foreignKey:CategoryID"`
+	CreatedAt   time.Time    `json:"created_at"`
 }
 
+// 订单状态
+const (
+	OrderStatusPending          = "pending"
+	OrderStatusPaid             = "paid"
+	OrderStatusPartiallyShipped = "partially_shipped" // 部分商品已发货
+	OrderStatusShipped          = "shipped"
+	OrderStatusDelivered        = "delivered"
+	OrderStatusCompleted        = "completed"
+	OrderStatusCancelled        = "cancelled"
+	OrderStatusRefunded         = "refunded"
+)
+
 // Order 订单模型
 type Order struct {
-	ID        uint        `json:"id" gorm:"primarykey"`
-	UserID    uint        `json:"user_id" gorm:"index"`
-	User      User        `json:"user,omitempty" gorm:"foreignKey:UserID"`
-	Total     float64     `json:"total"`
-	Status    string      `json:"status" gorm:"default:'pending';index"`
-	Items     []OrderItem `json:"items,omitempty" gorm:"foreignKey:OrderID"`
-	CreatedAt time.Time   `json:"created_at"`
-	UpdatedAt time.Time   `json:"updated_at"`
+	ID              ID[Order]            `json:"id" gorm:"primarykey"`
+	OrderNo         string               `json:"order_no" gorm:"size:32;uniqueIndex"` // 对外展示的订单号，下单日期加雪花ID
+	UserID          ID[User]             `json:"user_id" gorm:"index"`
+	User            User                 `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Currency        string               `json:"currency" gorm:"size:3;not null;default:'CNY'"` // 订单货币，订单中所有金额均为该货币
+	Subtotal        money.Amount         `json:"subtotal"`                                      // 商品原价合计
+	Discount        money.Amount         `json:"discount" gorm:"default:0"`                     // 优惠合计，含运费减免
+	Shipping        money.Amount         `json:"shipping" gorm:"default:0"`                     // 运费
+	Tax             money.Amount         `json:"tax" gorm:"default:0"`                          // 税费合计
+	Total           money.Amount         `json:"total"`                                         // 应付金额，Subtotal - Discount + Shipping + Tax
+	ShippingZone    string               `json:"shipping_zone,omitempty" gorm:"size:100"`       // 下单时匹配的配送区域名称
+	CouponCode      string               `json:"coupon_code,omitempty" gorm:"size:50"`
+	Refunded        money.Amount         `json:"refunded" gorm:"default:0"` // 已退款金额
+	Status          string               `json:"status" gorm:"default:'pending';index"`
+	ShippingAddress AddressInfo          `json:"shipping_address" gorm:"embedded;embeddedPrefix:shipping_"` // 下单时的收货地址快照
+	Items           []OrderItem          `json:"items,omitempty" gorm:"foreignKey:OrderID"`
+	Discounts       []OrderDiscount      `json:"discounts,omitempty" gorm:"foreignKey:OrderID"`
+	Reservations    []StockReservation   `json:"reservations,omitempty" gorm:"foreignKey:OrderID"`
+	History         []OrderStatusHistory `json:"history,omitempty" gorm:"foreignKey:OrderID"`
+	Shipments       []Shipment           `json:"shipments,omitempty" gorm:"foreignKey:OrderID"`
+	CreatedAt       time.Time            `json:"created_at"`
+	UpdatedAt       time.Time            `json:"updated_at"`
+}
+
+// OrderStatusHistory 订单状态变更记录
+type OrderStatusHistory struct {
+	ID         ID[OrderStatusHistory] `json:"id" gorm:"primarykey"`
+	OrderID    ID[Order]              `json:"order_id" gorm:"index"`
+	FromStatus string                 `json:"from_status" gorm:"size:20"`
+	ToStatus   string                 `json:"to_status" gorm:"size:20;not null"`
+	ActorID    ID[User]               `json:"actor_id"`
+	Reason     string                 `json:"reason" gorm:"size:255"`
+	CreatedAt  time.Time              `json:"created_at"`
 }
 
 // OrderItem 订单项
 type OrderItem struct {
-	ID        uint    `json:"id" gorm:"primarykey"`
-	OrderID   uint    `json:"order_id" gorm:"index"`
-	ProductID uint    `json:"product_id"`
-	Product   Product `json:"product,omitempty" gorm:"foreignKey:ProductID"`
-	Quantity  int     `json:"quantity"`
-	Price     float64 `json:"price"`
+	ID                ID[OrderItem]      `json:"id" gorm:"primarykey"`
+	OrderID           ID[Order]          `json:"order_id" gorm:"index"`
+	ProductID         ID[Product]        `json:"product_id"`
+	Product           Product            `json:"product,omitempty" gorm:"foreignKey:ProductID"`
+	VariantID         ID[ProductVariant] `json:"variant_id,omitempty" gorm:"default:0"`  // 购买的规格，没有规格的产品为 0
+	SKU               string             `json:"sku,omitempty" gorm:"size:64"`           // 下单时的规格SKU
+	VariantName       string             `json:"variant_name,omitempty" gorm:"size:200"` // 下单时的规格描述，如 "尺码: M / 颜色: 红"
+	Quantity          int                `json:"quantity"`
+	Price             money.Amount       `json:"price"`                               // 订单货币的成交单价
+	BasePrice         money.Amount       `json:"base_price"`                          // 下单时产品基础货币的单价
+	BaseCurrency      string             `json:"base_currency" gorm:"size:3"`         // 产品基础货币
+	ExchangeRate      string             `json:"exchange_rate" gorm:"size:32"`        // 下单时使用的汇率，1 基础货币 = ExchangeRate 订单货币
+	Discount          money.Amount       `json:"discount" gorm:"default:0"`           // 分摊到该订单项的优惠金额，退款时按数量扣除
+	Tax               money.Amount       `json:"tax" gorm:"default:0"`                // 该订单项的税费，按优惠后的金额计算，退款时按数量退还
+	TaxRate           string             `json:"tax_rate,omitempty" gorm:"size:32"`   // 下单时适用的税率
+	RefundedQuantity  int                `json:"refunded_quantity" gorm:"default:0"`  // 已退款数量
+	RestockedQuantity int                `json:"restocked_quantity" gorm:"default:0"` // 已退回库存的数量（取消订单或退款时退回）
+	ShippedQuantity   int                `json:"shipped_quantity" gorm:"default:0"`   // 已发货数量
+
+	Allocations []OrderItemAllocation `json:"allocations,omitempty" gorm:"foreignKey:OrderItemID"` // 发货仓库分配
+}
+
+// RefreshToken 刷新令牌，只保存哈希值
+// 同一次登录后轮换产生的令牌属于同一个令牌族（FamilyID）
+type RefreshToken struct {
+	ID        ID[RefreshToken] `json:"id" gorm:"primarykey"`
+	UserID    ID[User]         `json:"user_id" gorm:"index"`
+	FamilyID  string           `json:"family_id" gorm:"not null;size:32;index"`
+	TokenHash string           `json:"-" gorm:"uniqueIndex;not null;size:64"`
+	ExpiresAt time.Time        `json:"expires_at" gorm:"index"`
+	UsedAt    *time.Time       `json:"used_at"`
+	RevokedAt *time.Time       `json:"revoked_at"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// RevokedToken 已吊销的 Access Token，过期后可清理
+type RevokedToken struct {
+	JTI       string    `json:"jti" gorm:"primarykey;size:32"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// 幂等请求处理状态
+const (
+	IdempotencyProcessing = "processing"
+	IdempotencyCompleted  = "completed"
+)
+
+// IdempotencyKey 幂等键记录，同一用户对同一接口使用相同的 Key 时直接返回首次请求的响应
+type IdempotencyKey struct {
+	ID           ID[IdempotencyKey] `json:"id" gorm:"primarykey"`
+	Key          string             `json:"key" gorm:"column:idempotency_key;not null;size:100;uniqueIndex:idx_idempotency_scope"`
+	UserID       ID[User]           `json:"user_id" gorm:"uniqueIndex:idx_idempotency_scope"`
+	Method       string             `json:"method" gorm:"not null;size:10;uniqueIndex:idx_idempotency_scope"`
+	Path         string             `json:"path" gorm:"not null;size:200;uniqueIndex:idx_idempotency_scope"`
+	RequestHash  string             `json:"-" gorm:"not null;size:64"`
+	Status       string             `json:"status" gorm:"not null;size:20"`
+	ResponseCode int                `json:"response_code"`
+	ResponseBody string             `json:"-" gorm:"type:text"`
+	ExpiresAt    time.Time          `json:"expires_at" gorm:"index"`
+	CreatedAt    time.Time          `json:"created_at"`
+}
+
+// CartItem 购物车商品，每个用户的同一产品只有一条记录
+type CartItem struct {
+	ID        ID[CartItem]       `json:"id" gorm:"primarykey"`
+	UserID    ID[User]           `json:"user_id" gorm:"not null;uniqueIndex:idx_cart_user_product_variant"`
+	ProductID ID[Product]        `json:"product_id" gorm:"not null;uniqueIndex:idx_cart_user_product_variant"`
+	Product   Product            `json:"product,omitempty" gorm:"foreignKey:ProductID"`
+	VariantID ID[ProductVariant] `json:"variant_id,omitempty" gorm:"not null;default:0;uniqueIndex:idx_cart_user_product_variant"` // 选择的规格，没有规格的产品为 0
+	Variant   *ProductVariant    `json:"variant,omitempty" gorm:"foreignKey:VariantID"`
+	Quantity  int                `json:"quantity" gorm:"not null"`
+	Price     money.Amount       `json:"price"` // 加入购物车时的单价，用于提示价格变动
+	CreatedAt time.Time          `json:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at"`
+}
+
+// AddressInfo 收货地址信息，同时用于地址簿和订单上的地址快照
+type AddressInfo struct {
+	Receiver   string `json:"receiver" gorm:"size:50"`
+	Phone      string `json:"phone" gorm:"size:20"`
+	Province   string `json:"province" gorm:"size:50"`
+	City       string `json:"city" gorm:"size:50"`
+	District   string `json:"district" gorm:"size:50"`
+	Detail     string `json:"detail" gorm:"size:255"`
+	PostalCode string `json:"postal_code" gorm:"size:10"`
+}
+
+// Address 用户收货地址，每个用户最多一个默认地址
+type Address struct {
+	ID          ID[Address] `json:"id" gorm:"primarykey"`
+	UserID      ID[User]    `json:"user_id" gorm:"not null;index"`
+	IsDefault   bool        `json:"is_default"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+	AddressInfo `gorm:"embedded"`
+}
+
+// 支付状态
+const (
+	PaymentStatusPending   = "pending"
+	PaymentStatusSucceeded = "succeeded"
+	PaymentStatusFailed    = "failed"
+	PaymentStatusOrphaned  = "orphaned" // 已收款但订单已不是待支付，等待原路退回
+	PaymentStatusRefunded  = "refunded" // 孤立支付已原路退回
+)
+
+// Payment 支付记录，一个订单可以有多次支付尝试，最多一次成功
+type Payment struct {
+	ID            ID[Payment]  `json:"id" gorm:"primarykey"`
+	OrderID       ID[Order]    `json:"order_id" gorm:"not null;index"`
+	UserID        ID[User]     `json:"user_id" gorm:"not null;index"`
+	Provider      string       `json:"provider" gorm:"size:20;not null;uniqueIndex:idx_payment_intent"`
+	IntentID      string       `json:"intent_id" gorm:"size:64;not null;uniqueIndex:idx_payment_intent"`
+	Amount        money.Amount `json:"amount"`
+	Currency      string       `json:"currency" gorm:"size:3"`
+	Status        string       `json:"status" gorm:"size:20;default:'pending';index"`
+	FailureReason string       `json:"failure_reason,omitempty" gorm:"size:255"`
+	RefundRef     string       `json:"refund_ref,omitempty" gorm:"size:64"` // 孤立支付退回时支付渠道的退款单号
+	PaidAt        *time.Time   `json:"paid_at,omitempty"`
+	CreatedAt     time.Time    `json:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+}
+
+// 退款状态
+const (
+	RefundStatusPending   = "pending"   // 已记录，等待支付渠道退款
+	RefundStatusSucceeded = "succeeded" // 退款完成
+	RefundStatusFailed    = "failed"    // 支付渠道退款失败，已退回可退数量和金额
+)
+
+// Refund 退款记录，Items 为空表示不关联具体商品的金额退款
+type Refund struct {
+	ID        ID[Refund]   `json:"id" gorm:"primarykey"`
+	OrderID   ID[Order]    `json:"order_id" gorm:"not null;index"`
+	ActorID   ID[User]     `json:"actor_id"`
+	Amount    money.Amount `json:"amount"`
+	Reason    string       `json:"reason" gorm:"size:255"`
+	Status    string       `json:"status" gorm:"size:20;not null;default:'succeeded';index"`
+	PaymentID *ID[Payment] `json:"payment_id,omitempty"`                // 原路退回的支付记录，线下支付的订单为空
+	RefundRef string       `json:"refund_ref,omitempty" gorm:"size:64"` // 支付渠道的退款单号
+	Items     []RefundItem `json:"items,omitempty" gorm:"foreignKey:RefundID"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// RefundItem 退款明细
+type RefundItem struct {
+	ID              ID[RefundItem] `json:"id" gorm:"primarykey"`
+	RefundID        ID[Refund]     `json:"refund_id" gorm:"not null;index"`
+	OrderItemID     ID[OrderItem]  `json:"order_item_id" gorm:"not null;index"`
+	Quantity        int            `json:"quantity"`
+	Amount          money.Amount   `json:"amount"`
+	Restock         bool           `json:"restock"`          // 已发货的商品是否退回库存，未发货的商品总是退回
+	RestockQuantity int            `json:"restock_quantity"` // 退款完成时退回库存的数量
+}
+
+// SchemaMigration 已执行的一次性数据迁移
+type SchemaMigration struct {
+	ID        string    `gorm:"primarykey;size:100"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+// ExchangeRate 汇率，1 单位 Base 货币兑换 Rate 单位 Quote 货币，Rate 以十进制字符串保存避免精度损失
+type ExchangeRate struct {
+	ID        ID[ExchangeRate] `json:"id" gorm:"primarykey"`
+	Base      string           `json:"base" gorm:"size:3;not null;uniqueIndex:idx_exchange_pair"`
+	Quote     string           `json:"quote" gorm:"size:3;not null;uniqueIndex:idx_exchange_pair"`
+	Rate      string           `json:"rate" gorm:"size:32;not null"`
+	UpdatedAt time.Time        `json:"updated_at"`
+}
+
+// 优惠券类型
+const (
+	CouponTypePercent      = "percent"       // 按比例折扣
+	CouponTypeFixed        = "fixed"         // 固定金额减免
+	CouponTypeFreeShipping = "free_shipping" // 免运费
+)
+
+// Coupon 优惠券，CategoryID 和 ProductID 限定适用的商品，均为 0 时适用于全部商品
+type Coupon struct {
+	ID           ID[Coupon]   `json:"id" gorm:"primarykey"`
+	Code         string       `json:"code" gorm:"uniqueIndex;not null;size:50"`
+	Type         string       `json:"type" gorm:"size:20;not null"`
+	Percent      int          `json:"percent"`                                       // 折扣百分比（1-100），percent 类型使用
+	Amount       money.Amount `json:"amount"`                                        // 减免金额，fixed 类型使用
+	Currency     string       `json:"currency" gorm:"size:3;not null;default:'CNY'"` // Amount 和 MinSpend 的货币
+	MinSpend     money.Amount `json:"min_spend"`                                     // 适用商品的最低消费金额
+	CategoryID   ID[Category] `json:"category_id"`
+	ProductID    ID[Product]  `json:"product_id"`
+	StartsAt     *time.Time   `json:"starts_at"`
+	EndsAt       *time.Time   `json:"ends_at"`
+	UsageLimit   int          `json:"usage_limit"`    // 总使用次数上限，0 表示不限
+	PerUserLimit int          `json:"per_user_limit"` // 每个用户的使用次数上限，0 表示不限
+	UsedCount    int          `json:"used_count" gorm:"default:0"`
+	CreatedAt    time.Time    `json:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+}
+
+// CouponRedemption 优惠券使用记录，订单取消时删除并归还使用次数
+type CouponRedemption struct {
+	ID        ID[CouponRedemption] `json:"id" gorm:"primarykey"`
+	CouponID  ID[Coupon]           `json:"coupon_id" gorm:"not null;index:idx_redemption_coupon_user"`
+	UserID    ID[User]             `json:"user_id" gorm:"not null;index:idx_redemption_coupon_user"`
+	OrderID   ID[Order]            `json:"order_id" gorm:"not null;index"`
+	CreatedAt time.Time            `json:"created_at"`
+}
+
+// Promotion 自动促销：同一商品每买 BuyQuantity 件赠送 FreeQuantity 件，
+// CategoryID 和 ProductID 限定适用的商品，均为 0 时适用于全部商品
+type Promotion struct {
+	ID           ID[Promotion] `json:"id" gorm:"primarykey"`
+	Name         string        `json:"name" gorm:"size:100;not null"`
+	BuyQuantity  int           `json:"buy_quantity" gorm:"not null"`
+	FreeQuantity int           `json:"free_quantity" gorm:"not null"`
+	CategoryID   ID[Category]  `json:"category_id"`
+	ProductID    ID[Product]   `json:"product_id"`
+	StartsAt     *time.Time    `json:"starts_at"`
+	EndsAt       *time.Time    `json:"ends_at"`
+	CreatedAt    time.Time     `json:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+}
+
+// 优惠来源
+const (
+	DiscountSourceCoupon    = "coupon"
+	DiscountSourcePromotion = "promotion"
+)
+
+// OrderDiscount 订单优惠明细，促销优惠关联到具体的订单项
+type OrderDiscount struct {
+	ID          ID[OrderDiscount] `json:"id" gorm:"primarykey"`
+	OrderID     ID[Order]         `json:"order_id" gorm:"not null;index"`
+	Source      string            `json:"source" gorm:"size:20;not null"`
+	CouponID    *ID[Coupon]       `json:"coupon_id,omitempty"`
+	PromotionID *ID[Promotion]    `json:"promotion_id,omitempty"`
+	OrderItemID *ID[OrderItem]    `json:"order_item_id,omitempty"`
+	Description string            `json:"description" gorm:"size:255"`
+	Amount      money.Amount      `json:"amount"`
+	Shipping    bool              `json:"shipping,omitempty"` // 运费减免，不分摊到订单项
+}
+
+// 库存保留状态
+const (
+	ReservationActive    = "active"    // 保留中，占用可售库存
+	ReservationCommitted = "committed" // 订单已支付，已从现有库存扣减
+	ReservationReleased  = "released"  // 订单取消或保留过期，已释放
+)
+
+// StockReservation 下单时为订单项保留的库存，支付后转为扣减，过期未支付时释放
+type StockReservation struct {
+	ID          ID[StockReservation] `json:"id" gorm:"primarykey"`
+	OrderID     ID[Order]            `json:"order_id" gorm:"not null;index"`
+	OrderItemID ID[OrderItem]        `json:"order_item_id" gorm:"not null"`
+	ProductID   ID[Product]          `json:"product_id" gorm:"not null;index"`
+	VariantID   ID[ProductVariant]   `json:"variant_id,omitempty" gorm:"default:0"`
+	WarehouseID ID[Warehouse]        `json:"warehouse_id"`
+	Quantity    int                  `json:"quantity" gorm:"not null"`
+	Status      string               `json:"status" gorm:"size:20;not null;index:idx_reservation_expiry"`
+	ExpiresAt   *time.Time           `json:"expires_at" gorm:"index:idx_reservation_expiry"` // 为空表示不过期
+	CreatedAt   time.Time            `json:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at"`
+}
+
+// 库存变动原因
+const (
+	StockReasonOrder      = "order"      // 订单支付，扣减库存
+	StockReasonCancel     = "cancel"     // 已支付订单取消，退回库存
+	StockReasonRefund     = "refund"     // 退款退回库存
+	StockReasonAdjustment = "adjustment" // 手动调整
+	StockReasonImport     = "import"     // 期初库存
+	StockReasonTransfer   = "transfer"   // 仓库间调拨
+)
+
+// StockMovement 库存流水，只追加不修改，产品在每个仓库的现有库存等于该仓库全部流水 Delta 之和
+type StockMovement struct {
+	ID          ID[StockMovement]  `json:"id" gorm:"primarykey"`
+	ProductID   ID[Product]        `json:"product_id" gorm:"not null;index"`
+	VariantID   ID[ProductVariant] `json:"variant_id,omitempty" gorm:"default:0;index"` // 规格，没有规格的产品为 0
+	WarehouseID ID[Warehouse]      `json:"warehouse_id" gorm:"index"`
+	Delta       int                `json:"delta" gorm:"not null"`
+	Reason      string             `json:"reason" gorm:"size:20;not null"`
+	ReferenceID uint               `json:"-"`        // 关联的订单或调拨单主键，按 Reason 区分，对外编码见 MarshalJSON
+	ActorID     ID[User]           `json:"actor_id"` // 操作人，系统操作为 0
+	Note        string             `json:"note,omitempty" gorm:"size:255"`
+	CreatedAt   time.Time          `json:"created_at"`
+}
+
+// MarshalJSON 将 ReferenceID 按 Reason 编码为调拨单或订单的对外ID，输出为 reference_id
+func (m StockMovement) MarshalJSON() ([]byte, error) {
+	type movement StockMovement
+	out := struct {
+		movement
+		ReferenceID string `json:"reference_id,omitempty"`
+	}{movement: movement(m)}
+
+	if m.Reason == StockReasonTransfer {
+		out.ReferenceID = ID[StockTransfer](m.ReferenceID).String()
+	} else {
+		out.ReferenceID = ID[Order](m.ReferenceID).String()
+	}
+	return json.Marshal(out)
+}
+
+// Warehouse 仓库，下单时按 Priority 从小到大分配库存
+type Warehouse struct {
+	ID        ID[Warehouse] `json:"id" gorm:"primarykey"`
+	Code      string        `json:"code" gorm:"uniqueIndex;not null;size:20"`
+	Name      string        `json:"name" gorm:"not null;size:100"`
+	Priority  int           `json:"priority" gorm:"default:0"`
+	IsDefault bool          `json:"is_default"` // 默认仓库，未指定仓库的入库和升级前的库存归入该仓库
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// WarehouseStock 产品在单个仓库中的库存，各仓库之和等于 Product.Stock / Product.Reserved
+type WarehouseStock struct {
+	ID          ID[WarehouseStock] `json:"id" gorm:"primarykey"`
+	WarehouseID ID[Warehouse]      `json:"warehouse_id" gorm:"not null;uniqueIndex:idx_warehouse_product_variant"`
+	Warehouse   Warehouse          `json:"warehouse,omitempty" gorm:"foreignKey:WarehouseID"`
+	ProductID   ID[Product]        `json:"product_id" gorm:"not null;uniqueIndex:idx_warehouse_product_variant;index"`
+	VariantID   ID[ProductVariant] `json:"variant_id,omitempty" gorm:"not null;default:0;uniqueIndex:idx_warehouse_product_variant"` // 规格，没有规格的产品为 0
+	Stock       int                `json:"stock" gorm:"default:0;check:chk_warehouse_stocks_stock,stock >= 0"`
+	Reserved    int                `json:"reserved" gorm:"default:0;check:chk_warehouse_stocks_reserved,reserved >= 0 AND reserved <= stock"`
+	UpdatedAt   time.Time          `json:"updated_at"`
+}
+
+// StockTransfer 仓库间调拨，对应调出仓库和调入仓库各一条库存流水
+type StockTransfer struct {
+	ID              ID[StockTransfer]  `json:"id" gorm:"primarykey"`
+	ProductID       ID[Product]        `json:"product_id" gorm:"not null;index"`
+	VariantID       ID[ProductVariant] `json:"variant_id,omitempty" gorm:"default:0"`
+	FromWarehouseID ID[Warehouse]      `json:"from_warehouse_id" gorm:"not null"`
+	ToWarehouseID   ID[Warehouse]      `json:"to_warehouse_id" gorm:"not null"`
+	Quantity        int                `json:"quantity" gorm:"not null"`
+	ActorID         ID[User]           `json:"actor_id"`
+	Note            string             `json:"note,omitempty" gorm:"size:255"`
+	CreatedAt       time.Time          `json:"created_at"`
+}
+
+// OrderItemAllocation 订单项的发货仓库，单个仓库库存不足时一个订单项由多个仓库共同发货
+type OrderItemAllocation struct {
+	ID          ID[OrderItemAllocation] `json:"id" gorm:"primarykey"`
+	OrderItemID ID[OrderItem]           `json:"order_item_id" gorm:"not null;index"`
+	WarehouseID ID[Warehouse]           `json:"warehouse_id" gorm:"not null"`
+	Warehouse   Warehouse               `json:"warehouse,omitempty" gorm:"foreignKey:WarehouseID"`
+	Quantity    int                     `json:"quantity" gorm:"not null"`
+}
+
+// 低库存告警状态
+const (
+	StockAlertOpen     = "open"     // 未处理
+	StockAlertResolved = "resolved" // 已处理或库存已恢复
+)
+
+// StockAlert 低库存告警，同一产品同时只有一条未处理的告警
+type StockAlert struct {
+	ID           ID[StockAlert] `json:"id" gorm:"primarykey"`
+	ProductID    ID[Product]    `json:"product_id" gorm:"not null;index;uniqueIndex:idx_stock_alerts_open,where:status = 'open'"`
+	Product      Product        `json:"product,omitempty" gorm:"foreignKey:ProductID"`
+	Available    int            `json:"available"`     // 触发时的可售库存
+	ReorderPoint int            `json:"reorder_point"` // 触发时的补货点
+	Status       string         `json:"status" gorm:"size:20;not null;index"`
+	ResolvedBy   ID[User]       `json:"resolved_by,omitempty"` // 处理人，库存恢复后自动关闭时为 0
+	ResolvedAt   *time.Time     `json:"resolved_at,omitempty"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+}
+
+// 运费计算方式
+const (
+	ShippingMethodFlat   = "flat"   // 固定运费
+	ShippingMethodWeight = "weight" // 首重加续重
+)
+
+// ShippingZone 配送区域及其运费规则，金额以 Currency 计价，下单时按汇率换算为订单货币
+type ShippingZone struct {
+	ID          ID[ShippingZone] `json:"id" gorm:"primarykey"`
+	Name        string           `json:"name" gorm:"not null;size:100"`
+	Regions     string           `json:"regions" gorm:"size:500"` // 适用的省份，逗号分隔，为空表示其他未匹配的地区
+	Method      string           `json:"method" gorm:"size:20;not null"`
+	Currency    string           `json:"currency" gorm:"size:3;not null;default:'CNY'"`
+	Fee         money.Amount     `json:"fee"`          // flat 为固定运费，weight 为首重运费
+	FirstWeight int              `json:"first_weight"` // 首重（克）
+	StepWeight  int              `json:"step_weight"`  // 续重单位（克）
+	StepFee     money.Amount     `json:"step_fee"`     // 每个续重单位的运费
+	FreeAbove   money.Amount     `json:"free_above"`   // 优惠后的商品金额达到该值时免运费，0 表示不免运费
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+// TaxRate 税率，按收货地区和产品税类匹配，Region 或 TaxClass 为空表示不限
+type TaxRate struct {
+	ID        ID[TaxRate] `json:"id" gorm:"primarykey"`
+	Region    string      `json:"region" gorm:"size:50;uniqueIndex:idx_tax_rate_scope"`
+	TaxClass  string      `json:"tax_class" gorm:"size:20;uniqueIndex:idx_tax_rate_scope"`
+	Rate      string      `json:"rate" gorm:"size:32;not null"` // 小数形式，如 0.13 表示 13%
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// Shipment 发货单，一个订单可以分多次从不同仓库发货
+type Shipment struct {
+	ID             ID[Shipment]   `json:"id" gorm:"primarykey"`
+	OrderID        ID[Order]      `json:"order_id" gorm:"not null;index"`
+	WarehouseID    ID[Warehouse]  `json:"warehouse_id,omitempty"` // 发货仓库，0 表示未指定
+	Carrier        string         `json:"carrier" gorm:"size:50"`
+	TrackingNumber string         `json:"tracking_number" gorm:"size:100;index"`
+	ActorID        ID[User]       `json:"actor_id"`
+	Note           string         `json:"note" gorm:"size:255"`
+	Items          []ShipmentItem `json:"items" gorm:"foreignKey:ShipmentID"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+}
+
+// ShipmentItem 发货单中的订单项及发货数量
+type ShipmentItem struct {
+	ID          ID[ShipmentItem] `json:"id" gorm:"primarykey"`
+	ShipmentID  ID[Shipment]     `json:"shipment_id" gorm:"not null;index"`
+	OrderItemID ID[OrderItem]    `json:"order_item_id" gorm:"not null;index"`
+	Quantity    int              `json:"quantity" gorm:"not null"`
+}
+
+// 退货申请状态
+const (
+	ReturnStatusRequested = "requested" // 已提交，等待审核
+	ReturnStatusApproved  = "approved"  // 已同意，等待退回商品
+	ReturnStatusRejected  = "rejected"  // 已拒绝
+	ReturnStatusReceived  = "received"  // 已收到退回的商品并退款
+)
+
+// 退货原因
+const (
+	ReturnReasonDamaged        = "damaged"          // 商品损坏
+	ReturnReasonDefective      = "defective"        // 质量问题
+	ReturnReasonWrongItem      = "wrong_item"       // 发错商品
+	ReturnReasonNotAsDescribed = "not_as_described" // 与描述不符
+	ReturnReasonNoLongerNeeded = "no_longer_needed" // 不想要了
+	ReturnReasonOther          = "other"            // 其他
+)
+
+// ReturnRequest 退货申请，审核通过并收到退回的商品后按申请的商品退款
+type ReturnRequest struct {
+	ID           ID[ReturnRequest] `json:"id" gorm:"primarykey"`
+	OrderID      ID[Order]         `json:"order_id" gorm:"not null;index"`
+	UserID       ID[User]          `json:"user_id" gorm:"index"`
+	ReasonCode   string            `json:"reason_code" gorm:"size:30;not null"`
+	Description  string            `json:"description" gorm:"size:500"`
+	Status       string            `json:"status" gorm:"size:20;not null;index"`
+	RefundAmount money.Amount      `json:"refund_amount"`               // 审核通过时计算的退款金额，退款后为实际退款金额
+	RefundID     *ID[Refund]       `json:"refund_id,omitempty"`         // 收货后创建的退款
+	Restock      bool              `json:"restock"`                     // 收到的商品是否退回库存
+	ReviewerID   ID[User]          `json:"reviewer_id,omitempty"`       // 审核人
+	ReviewNote   string            `json:"review_note" gorm:"size:255"` // 审核意见
+	ReviewedAt   *time.Time        `json:"reviewed_at,omitempty"`       // 同意或拒绝的时间
+	ReceivedAt   *time.Time        `json:"received_at,omitempty"`       // 收到商品的时间
+	Items        []ReturnItem      `json:"items" gorm:"foreignKey:ReturnID"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+}
+
+// ReturnItem 退货申请中的订单项及退货数量
+type ReturnItem struct {
+	ID          ID[ReturnItem]    `json:"id" gorm:"primarykey"`
+	ReturnID    ID[ReturnRequest] `json:"return_id" gorm:"not null;index"`
+	OrderItemID ID[OrderItem]     `json:"order_item_id" gorm:"not null;index"`
+	Quantity    int               `json:"quantity" gorm:"not null"`
 }
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"errors"
+
+	"gin-learn/phase4/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// AddressRepository 收货地址仓库接口
+type AddressRepository interface {
+	Create(address *model.Address) error
+	GetByID(userID model.ID[model.User], id model.ID[model.Address]) (*model.Address, error)
+	GetDefault(userID model.ID[model.User]) (*model.Address, error)
+	List(userID model.ID[model.User]) ([]model.Address, error)
+	Update(address *model.Address) error
+	Delete(userID model.ID[model.User], id model.ID[model.Address]) error
+	SetDefault(userID model.ID[model.User], id model.ID[model.Address]) error
+}
+
+// addressRepository 收货地址仓库实现
+type addressRepository struct {
+	db *gorm.DB
+}
+
+func NewAddressRepository(db *gorm.DB) AddressRepository {
+	return &addressRepository{db: db}
+}
+
+// Create 创建地址，用户的第一个地址或标记为默认的地址会成为默认地址
+func (r *addressRepository) Create(address *model.Address) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&model.Address{}).Where("user_id = ?", address.UserID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			address.IsDefault = true
+		}
+
+		if address.IsDefault {
+			if err := clearDefaultAddress(tx, address.UserID); err != nil {
+				return err
+			}
+		}
+
+		return tx.Create(address).Error
+	})
+}
+
+func (r *addressRepository) GetByID(userID model.ID[model.User], id model.ID[model.Address]) (*model.Address, error) {
+	var address model.Address
+	if err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&address).Error; err != nil {
+		return nil, err
+	}
+	return &address, nil
+}
+
+func (r *addressRepository) GetDefault(userID model.ID[model.User]) (*model.Address, error) {
+	var address model.Address
+	if err := r.db.Where("user_id = ? AND is_default = ?", userID, true).First(&address).Error; err != nil {
+		return nil, err
+	}
+	return &address, nil
+}
+
+// List 获取用户的地址，默认地址排在最前
+func (r *addressRepository) List(userID model.ID[model.User]) ([]model.Address, error) {
+	var addresses []model.Address
+	if err := r.db.Where("user_id = ?", userID).Order("is_default DESC, id DESC").Find(&addresses).Error; err != nil {
+		return nil, err
+	}
+	return addresses, nil
+}
+
+// Update 更新地址内容，默认地址通过 SetDefault 修改
+func (r *addressRepository) Update(address *model.Address) error {
+	return r.db.Model(address).Select("receiver", "phone", "province", "city", "district", "detail", "postal_code").
+		Updates(address).Error
+}
+
+// Delete 删除地址，删除默认地址时将最近添加的地址设为默认
+func (r *addressRepository) Delete(userID model.ID[model.User], id model.ID[model.Address]) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		address, err := NewAddressRepository(tx).GetByID(userID, id)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Delete(address).Error; err != nil {
+			return err
+		}
+
+		if !address.IsDefault {
+			return nil
+		}
+
+		var next model.Address
+		err = tx.Where("user_id = ?", userID).Order("id DESC").First(&next).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return tx.Model(&next).Update("is_default", true).Error
+	})
+}
+
+// SetDefault 设置默认地址
+func (r *addressRepository) SetDefault(userID model.ID[model.User], id model.ID[model.Address]) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if _, err := NewAddressRepository(tx).GetByID(userID, id); err != nil {
+			return err
+		}
+
+		if err := clearDefaultAddress(tx, userID); err != nil {
+			return err
+		}
+
+		return tx.Model(&model.Address{}).Where("id = ?", id).Update("is_default", true).Error
+	})
+}
+
+func clearDefaultAddress(tx *gorm.DB, userID model.ID[model.User]) error {
+	return tx.Model(&model.Address{}).Where("user_id = ? AND is_default = ?", userID, true).Update("is_default", false).Error
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"gin-learn/phase4/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CartRepository 购物车仓库接口
+type CartRepository interface {
+	List(userID model.ID[model.User]) ([]model.CartItem, error)
+	GetByIDs(userID model.ID[model.User], ids []model.ID[model.CartItem]) ([]model.CartItem, error)
+	AddItem(item *model.CartItem, limit int) (bool, error)
+	UpdateQuantity(userID model.ID[model.User], id model.ID[model.CartItem], quantity int) error
+	Remove(userID model.ID[model.User], ids []model.ID[model.CartItem]) error
+	Clear(userID model.ID[model.User]) error
+}
+
+// cartRepository 购物车仓库实现
+type cartRepository struct {
+	db *gorm.DB
+}
+
+func NewCartRepository(db *gorm.DB) CartRepository {
+	return &cartRepository{db: db}
+}
+
+func (r *cartRepository) List(userID model.ID[model.User]) ([]model.CartItem, error) {
+	var items []model.CartItem
+	if err := r.db.Preload("Product").Preload("Variant").Preload("Variant.Values").Where("user_id = ?", userID).Order("id").Find(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *cartRepository) GetByIDs(userID model.ID[model.User], ids []model.ID[model.CartItem]) ([]model.CartItem, error) {
+	var items []model.CartItem
+	if err := r.db.Where("user_id = ? AND id IN ?", userID, ids).Order("id").Find(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// AddItem 加入购物车，同一产品规格已在购物车中时累加数量并刷新单价。
+// 累加后的数量超过 limit 时不做修改并返回 false，调用方需保证 item.Quantity 本身不超过 limit
+func (r *cartRepository) AddItem(item *model.CartItem, limit int) (bool, error) {
+	result := r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "product_id"}, {Name: "variant_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"quantity":   gorm.Expr("cart_items.quantity + excluded.quantity"),
+			"price":      gorm.Expr("excluded.price"),
+			"updated_at": gorm.Expr("excluded.updated_at"),
+		}),
+		Where: clause.Where{Exprs: []clause.Expression{
+			gorm.Expr("cart_items.quantity + excluded.quantity <= ?", limit),
+		}},
+	}).Create(item)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *cartRepository) UpdateQuantity(userID model.ID[model.User], id model.ID[model.CartItem], quantity int) error {
+	result := r.db.Model(&model.CartItem{}).Where("id = ? AND user_id = ?", id, userID).Update("quantity", quantity)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *cartRepository) Remove(userID model.ID[model.User], ids []model.ID[model.CartItem]) error {
+	return r.db.Where("user_id = ? AND id IN ?", userID, ids).Delete(&model.CartItem{}).Error
+}
+
+func (r *cartRepository) Clear(userID model.ID[model.User]) error {
+	return r.db.Where("user_id = ?", userID).Delete(&model.CartItem{}).Error
+}
@@ -9,7 +9,7 @@ import (
 // CategoryRepository 分类仓库接口
 type CategoryRepository interface {
 	Create(category *model.Category) error
-	GetByID(id uint) (*model.Category, error)
+	GetByID(id model.ID[model.Category]) (*model.Category, error)
 	List() ([]model.Category, error)
 }
 
@@ -26,7 +26,7 @@ func (r *categoryRepository) Create(category *model.Category) error {
 	return r.db.Create(category).Error
 }
 
-func (r *categoryRepository) GetByID(id uint) (*model.Category, error) {
+func (r *categoryRepository) GetByID(id model.ID[model.Category]) (*model.Category, error) {
 	var category model.Category
 	if err := r.db.Preload("Products").First(&category, id).Error; err != nil {
 		return nil, err
@@ -0,0 +1,227 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/pkg/money"
+
+	"gorm.io/gorm"
+)
+
+// ErrCouponUnavailable 优惠券不存在、已失效或不满足使用条件
+var ErrCouponUnavailable = errors.New("优惠券不可用")
+
+// CouponRepository 优惠券仓库接口
+type CouponRepository interface {
+	Create(coupon *model.Coupon) error
+	GetByID(id model.ID[model.Coupon]) (*model.Coupon, error)
+	GetByCode(code string) (*model.Coupon, error)
+	List() ([]model.Coupon, error)
+	Update(coupon *model.Coupon) error
+	Delete(id model.ID[model.Coupon]) error
+}
+
+// couponRepository 优惠券仓库实现
+type couponRepository struct {
+	db *gorm.DB
+}
+
+func NewCouponRepository(db *gorm.DB) CouponRepository {
+	return &couponRepository{db: db}
+}
+
+func (r *couponRepository) Create(coupon *model.Coupon) error {
+	return r.db.Create(coupon).Error
+}
+
+func (r *couponRepository) GetByID(id model.ID[model.Coupon]) (*model.Coupon, error) {
+	var coupon model.Coupon
+	if err := r.db.First(&coupon, id).Error; err != nil {
+		return nil, err
+	}
+	return &coupon, nil
+}
+
+func (r *couponRepository) GetByCode(code string) (*model.Coupon, error) {
+	var coupon model.Coupon
+	if err := r.db.Where("code = ?", code).First(&coupon).Error; err != nil {
+		return nil, err
+	}
+	return &coupon, nil
+}
+
+func (r *couponRepository) List() ([]model.Coupon, error) {
+	var coupons []model.Coupon
+	if err := r.db.Order("id DESC").Find(&coupons).Error; err != nil {
+		return nil, err
+	}
+	return coupons, nil
+}
+
+// Update 更新优惠券规则，已使用次数不会被修改
+func (r *couponRepository) Update(coupon *model.Coupon) error {
+	result := r.db.Model(coupon).Select("code", "type", "percent", "amount", "currency", "min_spend",
+		"category_id", "product_id", "starts_at", "ends_at", "usage_limit", "per_user_limit").
+		Updates(coupon)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *couponRepository) Delete(id model.ID[model.Coupon]) error {
+	result := r.db.Delete(&model.Coupon{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// applyCoupon 校验优惠券并计算优惠金额，优惠按金额比例分摊到适用的订单项。
+// 只读取数据，下单时由 redeemCoupon 记录使用
+func applyCoupon(tx *gorm.DB, order *model.Order, code string, lines []orderLine, now time.Time) (*model.Coupon, *model.OrderDiscount, error) {
+	var coupon model.Coupon
+	err := tx.Where("code = ?", code).First(&coupon).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, fmt.Errorf("%w: 优惠券 %s 不存在", ErrCouponUnavailable, code)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !activeAt(coupon.StartsAt, coupon.EndsAt, now) {
+		return nil, nil, fmt.Errorf("%w: 不在有效期内", ErrCouponUnavailable)
+	}
+	if coupon.UsageLimit > 0 && coupon.UsedCount >= coupon.UsageLimit {
+		return nil, nil, fmt.Errorf("%w: 已被领完", ErrCouponUnavailable)
+	}
+
+	if coupon.PerUserLimit > 0 {
+		var used int64
+		err := tx.Model(&model.CouponRedemption{}).
+			Where("coupon_id = ? AND user_id = ?", coupon.ID, order.UserID).Count(&used).Error
+		if err != nil {
+			return nil, nil, err
+		}
+		if used >= int64(coupon.PerUserLimit) {
+			return nil, nil, fmt.Errorf("%w: 已达到每人使用次数上限", ErrCouponUnavailable)
+		}
+	}
+
+	var (
+		eligible []*orderLine
+		subtotal money.Amount
+	)
+	for i := range lines {
+		if lines[i].matches(coupon.CategoryID, coupon.ProductID) {
+			eligible = append(eligible, &lines[i])
+			subtotal += lines[i].amount()
+		}
+	}
+	if len(eligible) == 0 {
+		return nil, nil, fmt.Errorf("%w: 订单中没有适用的商品", ErrCouponUnavailable)
+	}
+
+	// 最低消费和减免金额按下单时的汇率换算为订单货币
+	rate, err := NewExchangeRateRepository(tx).Rate(coupon.Currency, order.Currency)
+	if err != nil {
+		return nil, nil, err
+	}
+	minSpend, err := coupon.MinSpend.MulRate(rate)
+	if err != nil {
+		return nil, nil, err
+	}
+	if subtotal < minSpend {
+		return nil, nil, fmt.Errorf("%w: 适用商品未满 %s %s", ErrCouponUnavailable, minSpend, order.Currency)
+	}
+
+	discount := &model.OrderDiscount{
+		OrderID:     order.ID,
+		Source:      model.DiscountSourceCoupon,
+		CouponID:    &coupon.ID,
+		Description: "优惠券 " + coupon.Code,
+	}
+	switch coupon.Type {
+	case model.CouponTypePercent:
+		discount.Amount = subtotal.MulPercent(coupon.Percent)
+	case model.CouponTypeFixed:
+		amount, err := coupon.Amount.MulRate(rate)
+		if err != nil {
+			return nil, nil, err
+		}
+		discount.Amount = min(amount, subtotal)
+	case model.CouponTypeFreeShipping:
+		discount.Description += "（免运费）"
+		// 减免金额在计算运费后确定
+		discount.Shipping = true
+	}
+
+	allocateDiscount(eligible, subtotal, discount.Amount)
+	return &coupon, discount, nil
+}
+
+// redeemCoupon 记录订单使用优惠券。使用次数以条件更新的方式增加，并发下单时不会超过总次数上限
+func redeemCoupon(tx *gorm.DB, order *model.Order, coupon *model.Coupon) error {
+	result := tx.Model(&model.Coupon{}).
+		Where("id = ? AND (usage_limit = 0 OR used_count < usage_limit)", coupon.ID).
+		UpdateColumn("used_count", gorm.Expr("used_count + 1"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: 已被领完", ErrCouponUnavailable)
+	}
+
+	redemption := model.CouponRedemption{CouponID: coupon.ID, UserID: order.UserID, OrderID: order.ID}
+	return tx.Create(&redemption).Error
+}
+
+// releaseCoupons 删除订单的优惠券使用记录并归还使用次数，订单取消时调用
+func releaseCoupons(tx *gorm.DB, orderID model.ID[model.Order]) error {
+	var redemptions []model.CouponRedemption
+	if err := tx.Where("order_id = ?", orderID).Find(&redemptions).Error; err != nil {
+		return err
+	}
+
+	for _, redemption := range redemptions {
+		if err := tx.Delete(&redemption).Error; err != nil {
+			return err
+		}
+		err := tx.Model(&model.Coupon{}).Where("id = ? AND used_count > 0", redemption.CouponID).
+			UpdateColumn("used_count", gorm.Expr("used_count - 1")).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// allocateDiscount 将优惠金额按各订单项的应付金额比例分摊，舍入误差由最后一项承担
+func allocateDiscount(lines []*orderLine, subtotal, amount money.Amount) {
+	remaining := amount
+	for i, line := range lines {
+		share := remaining
+		if i < len(lines)-1 {
+			share = amount * line.amount() / subtotal
+		}
+		line.item.Discount += share
+		remaining -= share
+	}
+}
+
+// activeAt 判断 now 是否在有效期内，起止时间为空表示不限
+func activeAt(startsAt, endsAt *time.Time, now time.Time) bool {
+	if startsAt != nil && now.Before(*startsAt) {
+		return false
+	}
+	return endsAt == nil || now.Before(*endsAt)
+}
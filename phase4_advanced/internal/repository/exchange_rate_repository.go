@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/pkg/money"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrRateNotFound 缺少两种货币之间的汇率
+var ErrRateNotFound = errors.New("汇率不存在")
+
+// ExchangeRateRepository 汇率仓库接口
+type ExchangeRateRepository interface {
+	List() ([]model.ExchangeRate, error)
+	Save(rates []model.ExchangeRate) error
+	Delete(id model.ID[model.ExchangeRate]) error
+	Rate(from, to string) (string, error)
+}
+
+// exchangeRateRepository 汇率仓库实现
+type exchangeRateRepository struct {
+	db *gorm.DB
+}
+
+func NewExchangeRateRepository(db *gorm.DB) ExchangeRateRepository {
+	return &exchangeRateRepository{db: db}
+}
+
+func (r *exchangeRateRepository) List() ([]model.ExchangeRate, error) {
+	var rates []model.ExchangeRate
+	if err := r.db.Order("base, quote").Find(&rates).Error; err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
+
+// Save 新增或更新汇率，同一货币对只保留一条记录，全部写入在同一事务中完成
+func (r *exchangeRateRepository) Save(rates []model.ExchangeRate) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for i := range rates {
+			rates[i].UpdatedAt = time.Now()
+			err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "base"}, {Name: "quote"}},
+				DoUpdates: clause.AssignmentColumns([]string{"rate", "updated_at"}),
+			}).Create(&rates[i]).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *exchangeRateRepository) Delete(id model.ID[model.ExchangeRate]) error {
+	result := r.db.Delete(&model.ExchangeRate{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Rate 获取 from 到 to 的汇率：相同货币为 1，优先使用直接汇率，其次使用反向汇率的倒数
+func (r *exchangeRateRepository) Rate(from, to string) (string, error) {
+	if from == to {
+		return "1", nil
+	}
+
+	var rate model.ExchangeRate
+	err := r.db.Where("base = ? AND quote = ?", from, to).First(&rate).Error
+	if err == nil {
+		return rate.Rate, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+
+	err = r.db.Where("base = ? AND quote = ?", to, from).First(&rate).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", fmt.Errorf("%w: %s -> %s", ErrRateNotFound, from, to)
+	}
+	if err != nil {
+		return "", err
+	}
+	return money.InvertRate(rate.Rate)
+}
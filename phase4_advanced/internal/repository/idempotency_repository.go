@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"time"
+
+	"gin-learn/phase4/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// IdempotencyRepository 幂等键仓库接口
+type IdempotencyRepository interface {
+	Acquire(record *model.IdempotencyKey) (*model.IdempotencyKey, bool, error)
+	Complete(id model.ID[model.IdempotencyKey], code int, body string) error
+	Release(id model.ID[model.IdempotencyKey]) error
+	PurgeExpired() error
+}
+
+// idempotencyRepository 幂等键仓库实现
+type idempotencyRepository struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyRepository(db *gorm.DB) IdempotencyRepository {
+	return &idempotencyRepository{db: db}
+}
+
+// Acquire 尝试占用幂等键。占用成功返回 (record, true)；
+// 键已存在时返回已有记录和 false，由调用方决定重放响应还是拒绝请求
+func (r *idempotencyRepository) Acquire(record *model.IdempotencyKey) (*model.IdempotencyKey, bool, error) {
+	var existing model.IdempotencyKey
+	acquired := false
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		scope := tx.Where("idempotency_key = ? AND user_id = ? AND method = ? AND path = ?",
+			record.Key, record.UserID, record.Method, record.Path)
+
+		// 过期的键视为不存在
+		if err := scope.Session(&gorm.Session{}).Where("expires_at < ?", time.Now()).Delete(&model.IdempotencyKey{}).Error; err != nil {
+			return err
+		}
+
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 1 {
+			acquired = true
+			return nil
+		}
+
+		return scope.Session(&gorm.Session{}).First(&existing).Error
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	if acquired {
+		return record, true, nil
+	}
+	return &existing, false, nil
+}
+
+// Complete 保存首次请求的响应
+func (r *idempotencyRepository) Complete(id model.ID[model.IdempotencyKey], code int, body string) error {
+	return r.db.Model(&model.IdempotencyKey{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":        model.IdempotencyCompleted,
+		"response_code": code,
+		"response_body": body,
+	}).Error
+}
+
+// Release 删除幂等键，允许客户端使用相同的键重试
+func (r *idempotencyRepository) Release(id model.ID[model.IdempotencyKey]) error {
+	return r.db.Delete(&model.IdempotencyKey{}, id).Error
+}
+
+func (r *idempotencyRepository) PurgeExpired() error {
+	return r.db.Where("expires_at < ?", time.Now()).Delete(&model.IdempotencyKey{}).Error
+}
@@ -0,0 +1,237 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+// migration 一次性数据迁移，用于 AutoMigrate 无法完成的数据转换
+type migration struct {
+	ID  string
+	Run func(tx *gorm.DB) error
+}
+
+// migrations 按顺序执行的数据迁移，在 AutoMigrate 之前运行，执行记录保存在 schema_migrations 表中，
+// 已执行的迁移不会再次执行。新增迁移只能追加在末尾，已发布的迁移不能修改
+var migrations = []migration{
+	{ID: "20261016_money_minor_units", Run: migrateMoneyToMinorUnits},
+	{ID: "20261016_order_subtotal", Run: migrateOrderSubtotal},
+	{ID: "20261016_stock_ledger_baseline", Run: migrateStockLedgerBaseline},
+	{ID: "20261016_multi_warehouse", Run: migrateMultiWarehouse},
+	{ID: "20261016_order_shipped_quantity", Run: migrateOrderShippedQuantity},
+	{ID: "20261016_order_numbers", Run: migrateOrderNumbers},
+	{ID: "20261016_product_variants", Run: migrateProductVariants},
+}
+
+// runMigrations 执行尚未执行过的数据迁移，每个迁移与其执行记录在同一事务中提交
+func runMigrations(db *gorm.DB) error {
+	if err := db.AutoMigrate(&model.SchemaMigration{}); err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		var count int64
+		if err := db.Model(&model.SchemaMigration{}).Where("id = ?", m.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Run(tx); err != nil {
+				return err
+			}
+			return tx.Create(&model.SchemaMigration{ID: m.ID, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %s: %w", m.ID, err)
+		}
+		logger.Info("Migration applied", logger.String("id", m.ID))
+	}
+
+	return nil
+}
+
+// moneyColumns 以 money.Amount 保存的金额列
+var moneyColumns = []struct {
+	model  interface{}
+	column string
+}{
+	{&model.Product{}, "price"},
+	{&model.Order{}, "total"},
+	{&model.Order{}, "refunded"},
+	{&model.OrderItem{}, "price"},
+	{&model.CartItem{}, "price"},
+	{&model.Payment{}, "amount"},
+	{&model.Refund{}, "amount"},
+	{&model.RefundItem{}, "amount"},
+}
+
+// migrateMoneyToMinorUnits 将浮点金额（元）转换为整数分，不足一分的部分四舍五入，并把列类型改为整数。
+// 新建的数据库没有这些表，不需要转换
+func migrateMoneyToMinorUnits(tx *gorm.DB) error {
+	migrator := tx.Migrator()
+	for _, c := range moneyColumns {
+		if !migrator.HasColumn(c.model, c.column) {
+			continue
+		}
+
+		stmt := &gorm.Statement{DB: tx}
+		if err := stmt.Parse(c.model); err != nil {
+			return err
+		}
+
+		sql := fmt.Sprintf("UPDATE %s SET %s = ROUND(%s * 100)", stmt.Table, c.column, c.column)
+		if err := tx.Exec(sql).Error; err != nil {
+			return err
+		}
+
+		if err := migrator.AlterColumn(c.model, c.column); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateOrderSubtotal 为已有订单补齐商品合计，引入优惠之前的订单 subtotal 等于 total
+func migrateOrderSubtotal(tx *gorm.DB) error {
+	migrator := tx.Migrator()
+	if !migrator.HasTable(&model.Order{}) || migrator.HasColumn(&model.Order{}, "subtotal") {
+		return nil
+	}
+
+	if err := migrator.AddColumn(&model.Order{}, "Subtotal"); err != nil {
+		return err
+	}
+	return tx.Model(&model.Order{}).Where("1 = 1").UpdateColumn("subtotal", gorm.Expr("total")).Error
+}
+
+// migrateStockLedgerBaseline 为已有产品写入一条期初库存流水，使流水合计等于当前库存
+func migrateStockLedgerBaseline(tx *gorm.DB) error {
+	if !tx.Migrator().HasTable(&model.Product{}) {
+		return nil
+	}
+	if err := tx.AutoMigrate(&model.StockMovement{}); err != nil {
+		return err
+	}
+
+	var products []model.Product
+	if err := tx.Select("id", "stock").Where("stock <> 0").Find(&products).Error; err != nil {
+		return err
+	}
+	for _, p := range products {
+		movement := model.StockMovement{
+			ProductID: p.ID,
+			Delta:     p.Stock,
+			Reason:    model.StockReasonImport,
+			Note:      "期初库存",
+		}
+		if err := tx.Create(&movement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateMultiWarehouse 将已有产品的库存和保留数量、库存保留及库存流水全部归入默认仓库
+func migrateMultiWarehouse(tx *gorm.DB) error {
+	migrator := tx.Migrator()
+	if !migrator.HasTable(&model.Product{}) {
+		return nil
+	}
+	if err := tx.AutoMigrate(&model.Warehouse{}, &model.WarehouseStock{}, &model.StockReservation{}, &model.StockMovement{}); err != nil {
+		return err
+	}
+
+	warehouseID, err := defaultWarehouseID(tx)
+	if err != nil {
+		return err
+	}
+
+	// 引入库存保留之前的数据库没有 reserved 列
+	reserved := "0"
+	if migrator.HasColumn(&model.Product{}, "reserved") {
+		reserved = "reserved"
+	}
+	err = tx.Exec("INSERT INTO warehouse_stocks (warehouse_id, product_id, stock, reserved, updated_at) "+
+		"SELECT ?, id, stock, "+reserved+", ? FROM products WHERE stock <> 0", warehouseID, time.Now()).Error
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Model(&model.StockReservation{}).Where("warehouse_id IS NULL OR warehouse_id = 0").
+		UpdateColumn("warehouse_id", warehouseID).Error; err != nil {
+		return err
+	}
+	return tx.Model(&model.StockMovement{}).Where("warehouse_id IS NULL OR warehouse_id = 0").
+		UpdateColumn("warehouse_id", warehouseID).Error
+}
+
+// migrateOrderShippedQuantity 已发货、已送达和已完成的旧订单没有发货单，将其订单项视为全部已发货
+func migrateOrderShippedQuantity(tx *gorm.DB) error {
+	if !tx.Migrator().HasTable(&model.OrderItem{}) {
+		return nil
+	}
+	if err := tx.AutoMigrate(&model.OrderItem{}); err != nil {
+		return err
+	}
+
+	shipped := tx.Model(&model.Order{}).Select("id").Where("status IN ?",
+		[]string{model.OrderStatusShipped, model.OrderStatusDelivered, model.OrderStatusCompleted})
+	return tx.Model(&model.OrderItem{}).Where("order_id IN (?)", shipped).
+		UpdateColumn("shipped_quantity", gorm.Expr("quantity")).Error
+}
+
+// migrateOrderNumbers 为已有订单补充订单号，日期部分取下单时间。
+// 需在 AutoMigrate 创建唯一索引之前完成，否则已有订单的空订单号会违反唯一约束
+func migrateOrderNumbers(tx *gorm.DB) error {
+	migrator := tx.Migrator()
+	if !migrator.HasTable(&model.Order{}) {
+		return nil
+	}
+	if !migrator.HasColumn(&model.Order{}, "OrderNo") {
+		if err := migrator.AddColumn(&model.Order{}, "OrderNo"); err != nil {
+			return err
+		}
+	}
+
+	var orders []model.Order
+	if err := tx.Select("id", "created_at").Where("order_no IS NULL OR order_no = ''").Order("id").Find(&orders).Error; err != nil {
+		return err
+	}
+	for _, order := range orders {
+		err := tx.Model(&model.Order{}).Where("id = ?", order.ID).UpdateColumn("order_no", newOrderNo(order.CreatedAt)).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateProductVariants 删除不含规格的旧唯一索引，AutoMigrate 随后创建包含 variant_id 的新索引，
+// 同一仓库中的不同规格、购物车中同一产品的不同规格才能分别保存
+func migrateProductVariants(tx *gorm.DB) error {
+	migrator := tx.Migrator()
+	indexes := []struct {
+		model interface{}
+		name  string
+	}{
+		{&model.WarehouseStock{}, "idx_warehouse_product"},
+		{&model.CartItem{}, "idx_cart_user_product"},
+	}
+	for _, index := range indexes {
+		if !migrator.HasTable(index.model) || !migrator.HasIndex(index.model, index.name) {
+			continue
+		}
+		if err := migrator.DropIndex(index.model, index.name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
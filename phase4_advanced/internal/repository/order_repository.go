@@ -1,26 +1,77 @@
 package repository
 
 import (
+	"errors"
 	"fmt"
 	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/pkg/money"
+	"gin-learn/phase4/pkg/snowflake"
+	"sort"
+	"strconv"
+	"time"
 
 	"gorm.io/gorm"
 )
 
+var (
+	// ErrStatusConflict 订单状态已被其他请求修改
+	ErrStatusConflict = errors.New("订单状态已变更，请刷新后重试")
+	// ErrInsufficientStock 库存不足
+	ErrInsufficientStock = errors.New("库存不足")
+	// ErrRestockExceeded 退回库存的数量超过购买数量
+	ErrRestockExceeded = errors.New("退回库存数量超过购买数量")
+	// ErrNoShippingAddress 未指定收货地址且用户没有默认地址
+	ErrNoShippingAddress = errors.New("请先添加收货地址")
+)
+
 // OrderRepository 订单仓库接口
 type OrderRepository interface {
-	CreateOrder(userID uint, items []OrderItemInput) (*model.Order, error)
-	GetByID(id uint) (*model.Order, error)
-	List(page, pageSize int) ([]model.Order, int64, error)
-	CancelOrder(id uint) error
+	CreateOrder(input CreateOrderInput) (*model.Order, error)
+	QuoteOrder(input CreateOrderInput) (*model.Order, error)
+	GetByID(id model.ID[model.Order]) (*model.Order, error)
+	GetByOrderNo(orderNo string) (*model.Order, error)
+	List(page, pageSize int, userID model.ID[model.User]) ([]model.Order, int64, error)
+	ListPendingBefore(before time.Time, afterID model.ID[model.Order], limit int) ([]model.Order, error)
+	ListReservationExpired(now time.Time, afterID model.ID[model.Order], limit int) ([]model.Order, error)
+	TransitionStatus(id model.ID[model.Order], from, to string, actorID model.ID[model.User], reason string, restock bool) error
+}
+
+// CreateOrderInput 创建订单输入
+type CreateOrderInput struct {
+	UserID     model.ID[model.User]
+	AddressID  model.ID[model.Address] // 收货地址ID，为 0 时使用用户的默认地址
+	Currency   string                  // 订单货币，为空时使用默认货币
+	CouponCode string                  // 优惠券码，为空时不使用优惠券
+	Items      []OrderItemInput
+
+	// ReservationTTL 库存保留时间，为 0 时保留不过期
+	ReservationTTL time.Duration
 }
 
 // OrderItemInput 订单项输入
 type OrderItemInput struct {
-	ProductID uint
+	ProductID model.ID[model.Product]
+	VariantID model.ID[model.ProductVariant] // 产品规格，没有规格的产品为 0
 	Quantity  int
 }
 
+// orderLine 下单时的订单项及其产品，产品用于匹配优惠的适用范围、计算税费和分配库存
+type orderLine struct {
+	item    model.OrderItem
+	product model.Product
+}
+
+// amount 订单项扣除已有优惠后的应付金额
+func (l *orderLine) amount() money.Amount {
+	return l.item.Price.Mul(l.item.Quantity) - l.item.Discount
+}
+
+// matches 判断订单项是否在适用范围内，categoryID 和 productID 为 0 表示不限
+func (l *orderLine) matches(categoryID model.ID[model.Category], productID model.ID[model.Product]) bool {
+	return (productID == 0 || l.item.ProductID == productID) &&
+		(categoryID == 0 || l.product.CategoryID == categoryID)
+}
+
 // orderRepository 订单仓库实现
 type orderRepository struct {
 	db *gorm.DB
@@ -30,128 +81,552 @@ func NewOrderRepository(db *gorm.DB) OrderRepository {
 	return &orderRepository{db: db}
 }
 
-func (r *orderRepository) CreateOrder(userID uint, items []OrderItemInput) (*model.Order, error) {
-	var order model.Order
-
+func (r *orderRepository) CreateOrder(input CreateOrderInput) (*model.Order, error) {
+	var order *model.Order
 	err := r.db.Transaction(func(tx *gorm.DB) error {
-		// 验证用户
-		var user model.User
-		if err := tx.First(&user, userID).Error; err != nil {
-			return fmt.Errorf("用户不存在")
-		}
+		var err error
+		order, err = createOrder(tx, input)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetByID(order.ID)
+}
+
+// QuoteOrder 按与 CreateOrder 相同的流程计算订单金额但不保存任何数据：
+// 只读取产品、库存和优惠数据，不开启写事务、不保留库存、不增加优惠券使用次数
+func (r *orderRepository) QuoteOrder(input CreateOrderInput) (*model.Order, error) {
+	pricing, err := priceOrder(r.db, input)
+	if err != nil {
+		return nil, err
+	}
 
-		// 创建订单
-		order = model.Order{
-			UserID: userID,
-			Status: "pending",
-			Total:  0,
+	quote := pricing.order
+	quote.Items = make([]model.OrderItem, len(pricing.lines))
+	for i := range pricing.lines {
+		line := &pricing.lines[i]
+		// 可售库存不足时与下单一样返回 ErrInsufficientStock
+		if _, err := planStock(r.db, &line.product, &line.item); err != nil {
+			return nil, err
 		}
-		if err := tx.Create(&order).Error; err != nil {
-			return err
+		quote.Items[i] = line.item
+		quote.Items[i].Product = line.product
+	}
+	quote.Discounts = pricing.discounts
+	return &quote, nil
+}
+
+// orderPricing 订单金额的计算结果，订单、订单项和优惠明细均未保存
+type orderPricing struct {
+	order     model.Order
+	lines     []orderLine
+	discounts []model.OrderDiscount
+	coupon    *model.Coupon // 使用的优惠券，没有时为 nil
+}
+
+// priceOrder 计算订单金额，依次计算商品金额、自动促销、优惠券、运费和税费。
+// 只读取数据不做任何修改，下单和报价共用
+func priceOrder(tx *gorm.DB, input CreateOrderInput) (*orderPricing, error) {
+	userID := input.UserID
+	currency := input.Currency
+	if currency == "" {
+		currency = model.DefaultCurrency
+	}
+
+	// 合并重复产品规格并按产品ID、规格ID排序，保证并发事务以相同顺序锁定库存行，避免死锁
+	items := mergeOrderItems(input.Items)
+
+	// 验证用户
+	var user model.User
+	if err := tx.First(&user, userID).Error; err != nil {
+		return nil, fmt.Errorf("用户不存在")
+	}
+
+	address, err := shippingAddress(tx, userID, input.AddressID)
+	if err != nil {
+		return nil, err
+	}
+	zone, err := matchShippingZone(tx, address.Province)
+	if err != nil {
+		return nil, err
+	}
+
+	order := model.Order{
+		UserID:          userID,
+		Currency:        currency,
+		Status:          model.OrderStatusPending,
+		ShippingAddress: address,
+		CouponCode:      input.CouponCode,
+	}
+	if zone != nil {
+		order.ShippingZone = zone.Name
+	}
+
+	// 计算订单项金额
+	rates := NewExchangeRateRepository(tx)
+	lines := make([]orderLine, 0, len(items))
+	var weight int
+	for _, item := range items {
+		var product model.Product
+		if err := tx.First(&product, item.ProductID).Error; err != nil {
+			return nil, fmt.Errorf("产品不存在: %s", item.ProductID)
 		}
 
-		// 处理订单项
-		var total float64
-		for _, item := range items {
-			var product model.Product
-			if err := tx.First(&product, item.ProductID).Error; err != nil {
-				return fmt.Errorf("产品不存在: %d", item.ProductID)
-			}
+		variant, err := productVariant(tx, product.ID, item.VariantID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", err, product.Name)
+		}
+		basePrice := product.Price
+		if variant != nil {
+			basePrice = variant.UnitPrice(&product)
+		}
 
-			if product.Stock < item.Quantity {
-				return fmt.Errorf("库存不足: %s", product.Name)
-			}
+		// 按下单时的汇率换算为订单货币
+		rate, err := rates.Rate(product.Currency, currency)
+		if err != nil {
+			return nil, err
+		}
+		price, err := basePrice.MulRate(rate)
+		if err != nil {
+			return nil, err
+		}
 
-			// 扣减库存
-			if err := tx.Model(&product).Update("stock", gorm.Expr("stock - ?", item.Quantity)).Error; err != nil {
-				return err
+		orderItem := model.OrderItem{
+			ProductID:    item.ProductID,
+			Quantity:     item.Quantity,
+			Price:        price,
+			BasePrice:    basePrice,
+			BaseCurrency: product.Currency,
+			ExchangeRate: rate,
+		}
+		if variant != nil {
+			orderItem.VariantID = variant.ID
+			orderItem.SKU = variant.SKU
+			if orderItem.VariantName, err = variantName(tx, variant); err != nil {
+				return nil, err
 			}
+		}
 
-			// 创建订单项
-			orderItem := model.OrderItem{
-				OrderID:   order.ID,
-				ProductID: item.ProductID,
-				Quantity:  item.Quantity,
-				Price:     product.Price,
-			}
-			if err := tx.Create(&orderItem).Error; err != nil {
-				return err
-			}
+		lines = append(lines, orderLine{item: orderItem, product: product})
+		order.Subtotal += price.Mul(item.Quantity)
+		weight += product.Weight * item.Quantity
+	}
 
-			total += product.Price * float64(item.Quantity)
+	// 先计算自动促销，优惠券按促销后的金额计算
+	now := time.Now()
+	discounts, err := applyPromotions(tx, &order, lines, now)
+	if err != nil {
+		return nil, err
+	}
+	var coupon *model.Coupon
+	if input.CouponCode != "" {
+		var discount *model.OrderDiscount
+		coupon, discount, err = applyCoupon(tx, &order, input.CouponCode, lines, now)
+		if err != nil {
+			return nil, err
 		}
+		discounts = append(discounts, *discount)
+	}
 
-		// 更新订单总价
-		if err := tx.Model(&order).Update("total", total).Error; err != nil {
-			return err
+	// 运费按优惠后的商品金额判断是否满额免运费
+	var merchandise money.Amount
+	for i := range lines {
+		merchandise += lines[i].amount()
+	}
+	order.Shipping, err = shippingFee(tx, zone, currency, weight, merchandise)
+	if err != nil {
+		return nil, err
+	}
+
+	// 税费按优惠后的金额计算，运费不计税
+	if err := applyTax(tx, address.Province, lines); err != nil {
+		return nil, err
+	}
+
+	for i := range discounts {
+		if discounts[i].Shipping {
+			// 免运费优惠减免全部运费
+			discounts[i].Amount = order.Shipping
 		}
+		order.Discount += discounts[i].Amount
+	}
+	for i := range lines {
+		order.Tax += lines[i].item.Tax
+	}
+	order.Total = order.Subtotal - order.Discount + order.Shipping + order.Tax
 
-		return nil
-	})
+	return &orderPricing{order: order, lines: lines, discounts: discounts, coupon: coupon}, nil
+}
 
+// createOrder 在事务中按 priceOrder 的计算结果保存订单、订单项和优惠明细，保留库存并使用优惠券
+func createOrder(tx *gorm.DB, input CreateOrderInput) (*model.Order, error) {
+	pricing, err := priceOrder(tx, input)
 	if err != nil {
 		return nil, err
 	}
 
-	// 加载关联数据
-	r.db.Preload("User").Preload("Items").Preload("Items.Product").First(&order, order.ID)
+	order := pricing.order
+	order.OrderNo = newOrderNo(time.Now())
+	if err := tx.Create(&order).Error; err != nil {
+		return nil, err
+	}
+
+	history := model.OrderStatusHistory{
+		OrderID:  order.ID,
+		ToStatus: model.OrderStatusPending,
+		ActorID:  order.UserID,
+		Reason:   "创建订单",
+	}
+	if err := tx.Create(&history).Error; err != nil {
+		return nil, err
+	}
+
+	var expiresAt *time.Time
+	if input.ReservationTTL > 0 {
+		t := time.Now().Add(input.ReservationTTL)
+		expiresAt = &t
+	}
+
+	// 保存订单项并保留库存，促销优惠的 OrderItemID 指向 lines 中的订单项，保存后即为订单项ID
+	for i := range pricing.lines {
+		line := &pricing.lines[i]
+		line.item.OrderID = order.ID
+		if err := tx.Create(&line.item).Error; err != nil {
+			return nil, err
+		}
+		if err := allocateStock(tx, &line.product, &line.item, expiresAt); err != nil {
+			return nil, err
+		}
+	}
 
+	if pricing.coupon != nil {
+		if err := redeemCoupon(tx, &order, pricing.coupon); err != nil {
+			return nil, err
+		}
+	}
+	for i := range pricing.discounts {
+		pricing.discounts[i].OrderID = order.ID
+		if err := tx.Create(&pricing.discounts[i]).Error; err != nil {
+			return nil, err
+		}
+	}
 	return &order, nil
 }
 
-func (r *orderRepository) GetByID(id uint) (*model.Order, error) {
+func (r *orderRepository) GetByID(id model.ID[model.Order]) (*model.Order, error) {
+	return r.get("id = ?", id)
+}
+
+// GetByOrderNo 按订单号查询订单
+func (r *orderRepository) GetByOrderNo(orderNo string) (*model.Order, error) {
+	return r.get("order_no = ?", orderNo)
+}
+
+// get 查询订单并预加载订单详情需要的关联数据
+func (r *orderRepository) get(query string, args ...interface{}) (*model.Order, error) {
 	var order model.Order
-	if err := r.db.Preload("User").Preload("Items").Preload("Items.Product").First(&order, id).Error; err != nil {
+	err := r.db.Preload("User").Preload("Items").Preload("Items.Product").Preload("Items.Allocations").Preload("Discounts").Preload("Reservations").
+		Preload("History", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("Shipments", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).Preload("Shipments.Items").
+		Where(query, args...).First(&order).Error
+	if err != nil {
 		return nil, err
 	}
 	return &order, nil
 }
 
-func (r *orderRepository) List(page, pageSize int) ([]model.Order, int64, error) {
+// newOrderNo 生成订单号：下单日期 + 雪花ID，不同实例配置不同的节点编号即可保证不重复
+func newOrderNo(t time.Time) string {
+	return t.Format("20060102") + strconv.FormatInt(snowflake.Next(), 10)
+}
+
+// List 分页查询订单，userID 为 0 时查询全部用户的订单
+func (r *orderRepository) List(page, pageSize int, userID model.ID[model.User]) ([]model.Order, int64, error) {
+	query := r.db.Model(&model.Order{})
+
+	if userID > 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+
 	var total int64
-	r.db.Model(&model.Order{}).Count(&total)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
 
 	var orders []model.Order
 	offset := (page - 1) * pageSize
-	if err := r.db.Preload("User").Preload("Items").Preload("Items.Product").Offset(offset).Limit(pageSize).Find(&orders).Error; err != nil {
+	if err := query.Preload("User").Preload("Items").Preload("Items.Product").Order("id DESC").Offset(offset).Limit(pageSize).Find(&orders).Error; err != nil {
 		return nil, 0, err
 	}
 
 	return orders, total, nil
 }
 
-func (r *orderRepository) CancelOrder(id uint) error {
+// ListPendingBefore 获取创建时间早于 before、ID大于 afterID 的待支付订单，按ID排序，用于分页遍历
+func (r *orderRepository) ListPendingBefore(before time.Time, afterID model.ID[model.Order], limit int) ([]model.Order, error) {
+	var orders []model.Order
+	err := r.db.Where("status = ? AND created_at < ? AND id > ?", model.OrderStatusPending, before, afterID).
+		Order("id").Limit(limit).Find(&orders).Error
+	if err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// ListReservationExpired 获取库存保留已过期、ID大于 afterID 的待支付订单，按ID排序，用于分页遍历
+func (r *orderRepository) ListReservationExpired(now time.Time, afterID model.ID[model.Order], limit int) ([]model.Order, error) {
+	expired := r.db.Model(&model.StockReservation{}).Select("order_id").
+		Where("status = ? AND expires_at < ?", model.ReservationActive, now)
+
+	var orders []model.Order
+	err := r.db.Where("status = ? AND id > ? AND id IN (?)", model.OrderStatusPending, afterID, expired).
+		Order("id").Limit(limit).Find(&orders).Error
+	if err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// TransitionStatus 在事务中将订单从 from 状态变更为 to 状态并记录历史
+// 状态以条件更新的方式修改，并发修改时返回 ErrStatusConflict。支付时将库存保留转为扣减；
+// restock 为 true 时释放库存保留并退回已扣减的库存；订单取消时归还使用的优惠券
+func (r *orderRepository) TransitionStatus(id model.ID[model.Order], from, to string, actorID model.ID[model.User], reason string, restock bool) error {
 	return r.db.Transaction(func(tx *gorm.DB) error {
-		var order model.Order
-		if err := tx.First(&order, id).Error; err != nil {
-			return fmt.Errorf("订单不存在")
+		result := tx.Model(&model.Order{}).Where("id = ? AND status = ?", id, from).Update("status", to)
+		if result.Error != nil {
+			return result.Error
 		}
-
-		if order.Status == "cancelled" {
-			return fmt.Errorf("订单已取消")
+		if result.RowsAffected == 0 {
+			return ErrStatusConflict
 		}
 
-		if order.Status == "completed" {
-			return fmt.Errorf("订单已完成，无法取消")
+		if to == model.OrderStatusPaid {
+			if err := commitReservations(tx, id); err != nil {
+				return err
+			}
 		}
 
-		var items []model.OrderItem
-		if err := tx.Where("order_id = ?", id).Find(&items).Error; err != nil {
-			return err
+		if restock {
+			// 尚未支付的订单只需释放保留，释放的数量计入已退回数量
+			if err := releaseReservations(tx, id); err != nil {
+				return err
+			}
+
+			var items []model.OrderItem
+			if err := tx.Where("order_id = ?", id).Find(&items).Error; err != nil {
+				return err
+			}
+
+			// 只退回尚未通过退款退回的数量
+			for _, item := range items {
+				if err := restockItem(tx, &item, item.Quantity-item.RestockedQuantity, model.StockReasonCancel, actorID); err != nil {
+					return err
+				}
+			}
 		}
 
-		// 恢复库存
-		for _, item := range items {
-			if err := tx.Model(&model.Product{}).Where("id = ?", item.ProductID).Update("stock", gorm.Expr("stock + ?", item.Quantity)).Error; err != nil {
+		if to == model.OrderStatusCancelled {
+			if err := releaseCoupons(tx, id); err != nil {
 				return err
 			}
 		}
 
-		// 更新订单状态
-		if err := tx.Model(&order).Update("status", "cancelled").Error; err != nil {
+		history := model.OrderStatusHistory{
+			OrderID:    id,
+			FromStatus: from,
+			ToStatus:   to,
+			ActorID:    actorID,
+			Reason:     reason,
+		}
+		return tx.Create(&history).Error
+	})
+}
+
+// commitReservations 将订单的库存保留转为扣减：先减少保留数量，再通过库存流水扣减现有库存
+func commitReservations(tx *gorm.DB, orderID model.ID[model.Order]) error {
+	reservations, err := activeReservations(tx, orderID)
+	if err != nil {
+		return err
+	}
+
+	for _, reservation := range reservations {
+		ok, err := finishReservation(tx, reservation.ID, model.ReservationCommitted)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+
+		if err := releaseReserved(tx, &reservation); err != nil {
+			return err
+		}
+		err = applyStockDelta(tx, &model.StockMovement{
+			ProductID:   reservation.ProductID,
+			VariantID:   reservation.VariantID,
+			WarehouseID: reservation.WarehouseID,
+			Delta:       -reservation.Quantity,
+			Reason:      model.StockReasonOrder,
+			ReferenceID: uint(orderID),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseReservations 释放订单的库存保留，释放的数量计入订单项的已退回数量，避免之后重复退回库存
+func releaseReservations(tx *gorm.DB, orderID model.ID[model.Order]) error {
+	reservations, err := activeReservations(tx, orderID)
+	if err != nil {
+		return err
+	}
+
+	for _, reservation := range reservations {
+		ok, err := finishReservation(tx, reservation.ID, model.ReservationReleased)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+
+		if err := releaseReserved(tx, &reservation); err != nil {
+			return err
+		}
+		err = tx.Model(&model.OrderItem{}).Where("id = ?", reservation.OrderItemID).
+			Update("restocked_quantity", gorm.Expr("restocked_quantity + ?", reservation.Quantity)).Error
+		if err != nil {
 			return err
 		}
+	}
+	return nil
+}
 
+func activeReservations(tx *gorm.DB, orderID model.ID[model.Order]) ([]model.StockReservation, error) {
+	var reservations []model.StockReservation
+	err := tx.Where("order_id = ? AND status = ?", orderID, model.ReservationActive).
+		Order("product_id, warehouse_id").Find(&reservations).Error
+	if err != nil {
+		return nil, err
+	}
+	return reservations, nil
+}
+
+// finishReservation 以条件更新的方式结束保留中的库存保留，已被其他请求处理时返回 false
+func finishReservation(tx *gorm.DB, id model.ID[model.StockReservation], status string) (bool, error) {
+	result := tx.Model(&model.StockReservation{}).
+		Where("id = ? AND status = ?", id, model.ReservationActive).
+		Update("status", status)
+	return result.RowsAffected > 0, result.Error
+}
+
+// restockItem 将订单项的 quantity 件商品退回发货仓库并记录库存流水，已退回数量不能超过购买数量。
+// 按发货仓库分配的顺序依次退回，没有分配记录的旧订单退回默认仓库
+func restockItem(tx *gorm.DB, item *model.OrderItem, quantity int, reason string, actorID model.ID[model.User]) error {
+	if quantity <= 0 {
 		return nil
+	}
+
+	var restocked int
+	err := tx.Model(&model.OrderItem{}).Where("id = ?", item.ID).Select("restocked_quantity").Scan(&restocked).Error
+	if err != nil {
+		return err
+	}
+
+	result := tx.Model(&model.OrderItem{}).
+		Where("id = ? AND restocked_quantity = ? AND restocked_quantity + ? <= quantity", item.ID, restocked, quantity).
+		Update("restocked_quantity", gorm.Expr("restocked_quantity + ?", quantity))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: 订单项 %s", ErrRestockExceeded, item.ID)
+	}
+
+	var allocations []model.OrderItemAllocation
+	if err := tx.Where("order_item_id = ?", item.ID).Order("id").Find(&allocations).Error; err != nil {
+		return err
+	}
+	if len(allocations) == 0 {
+		allocations = []model.OrderItemAllocation{{Quantity: item.Quantity}}
+	}
+
+	// 跳过之前已退回的数量，剩余数量退回对应的仓库
+	skip := restocked
+	for _, allocation := range allocations {
+		if skip >= allocation.Quantity {
+			skip -= allocation.Quantity
+			continue
+		}
+		n := min(allocation.Quantity-skip, quantity)
+		skip = 0
+
+		err := applyStockDelta(tx, &model.StockMovement{
+			ProductID:   item.ProductID,
+			VariantID:   item.VariantID,
+			WarehouseID: allocation.WarehouseID,
+			Delta:       n,
+			Reason:      reason,
+			ReferenceID: uint(item.OrderID),
+			ActorID:     actorID,
+		})
+		if err != nil {
+			return err
+		}
+		quantity -= n
+		if quantity == 0 {
+			break
+		}
+	}
+	return nil
+}
+
+// shippingAddress 复制收货地址作为订单快照，未指定地址时使用默认地址，没有默认地址时返回 ErrNoShippingAddress
+func shippingAddress(tx *gorm.DB, userID model.ID[model.User], addressID model.ID[model.Address]) (model.AddressInfo, error) {
+	addresses := NewAddressRepository(tx)
+	if addressID != 0 {
+		address, err := addresses.GetByID(userID, addressID)
+		if err != nil {
+			return model.AddressInfo{}, fmt.Errorf("收货地址不存在: %s", addressID)
+		}
+		return address.AddressInfo, nil
+	}
+
+	address, err := addresses.GetDefault(userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return model.AddressInfo{}, ErrNoShippingAddress
+	}
+	if err != nil {
+		return model.AddressInfo{}, err
+	}
+	return address.AddressInfo, nil
+}
+
+// mergeOrderItems 合并同一产品规格的订单项并按产品ID、规格ID升序排列
+func mergeOrderItems(items []OrderItemInput) []OrderItemInput {
+	type key struct {
+		productID model.ID[model.Product]
+		variantID model.ID[model.ProductVariant]
+	}
+	quantities := make(map[key]int, len(items))
+	for _, item := range items {
+		quantities[key{item.ProductID, item.VariantID}] += item.Quantity
+	}
+
+	merged := make([]OrderItemInput, 0, len(quantities))
+	for k, quantity := range quantities {
+		merged = append(merged, OrderItemInput{ProductID: k.productID, VariantID: k.variantID, Quantity: quantity})
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		if merged[i].ProductID != merged[j].ProductID {
+			return merged[i].ProductID < merged[j].ProductID
+		}
+		return merged[i].VariantID < merged[j].VariantID
 	})
+
+	return merged
 }
@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"gin-learn/phase4/config"
+	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/pkg/logger"
+	"gin-learn/phase4/pkg/money"
+)
+
+// newTestRepository 使用临时文件 SQLite 数据库初始化仓库，连接参数和迁移与生产环境相同
+func newTestRepository(t *testing.T) *Repository {
+	t.Helper()
+
+	logger.Init("error")
+	config.C.DB = config.DBConfig{Type: "sqlite", Database: filepath.Join(t.TempDir(), "test.db")}
+
+	db, err := InitDB()
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return NewRepository(db)
+}
+
+// newTestBuyer 创建带默认收货地址的用户
+func newTestBuyer(t *testing.T, repo *Repository, username string) *model.User {
+	t.Helper()
+
+	user := &model.User{Username: username, Email: username + "@example.com", Password: "-", Status: 1}
+	if err := repo.User.Create(user); err != nil {
+		t.Fatal(err)
+	}
+	address := &model.Address{UserID: user.ID, AddressInfo: model.AddressInfo{Receiver: "收货人", Province: "广东省", City: "深圳市", Detail: "1号"}}
+	if err := repo.Address.Create(address); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func TestCreateOrderConcurrentNoOversell(t *testing.T) {
+	const (
+		orders = 300
+		stock  = 20
+	)
+
+	repo := newTestRepository(t)
+
+	user := newTestBuyer(t, repo, "buyer")
+	product := &model.Product{Name: "limited", Price: money.Amount(100), Stock: stock}
+	if err := repo.Product.Create(product); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		created  []model.ID[model.Order]
+		failures []error
+	)
+	start := make(chan struct{})
+	for i := 0; i < orders; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+
+			order, err := repo.Order.CreateOrder(CreateOrderInput{
+				UserID: user.ID,
+				Items:  []OrderItemInput{{ProductID: product.ID, Quantity: 1}},
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				created = append(created, order.ID)
+			case !errors.Is(err, ErrInsufficientStock):
+				failures = append(failures, err)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if len(failures) > 0 {
+		t.Fatalf("%d orders failed with non-stock errors, first: %v", len(failures), failures[0])
+	}
+	if len(created) != stock {
+		t.Fatalf("created %d orders, want %d", len(created), stock)
+	}
+
+	// 并发支付全部订单，扣减现有库存并写入流水
+	errs := make(chan error, len(created))
+	for _, id := range created {
+		wg.Add(1)
+		go func(id model.ID[model.Order]) {
+			defer wg.Done()
+			errs <- repo.Order.TransitionStatus(id, model.OrderStatusPending, model.OrderStatusPaid, 0, "", false)
+		}(id)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("pay order: %v", err)
+		}
+	}
+
+	current, err := repo.Product.GetByID(product.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.Stock != 0 || current.Reserved != 0 {
+		t.Errorf("product stock %d reserved %d, want 0 and 0", current.Stock, current.Reserved)
+	}
+	for _, ws := range current.Stocks {
+		if ws.Stock < 0 || ws.Reserved < 0 {
+			t.Errorf("warehouse %s stock %d reserved %d went negative", ws.WarehouseID, ws.Stock, ws.Reserved)
+		}
+	}
+
+	drifts, err := repo.Stock.Reconcile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drifts) > 0 {
+		t.Errorf("stock does not match ledger: %+v", drifts)
+	}
+}
+
+func TestQuoteOrderIsReadOnly(t *testing.T) {
+	repo := newTestRepository(t)
+
+	user := newTestBuyer(t, repo, "quoter")
+	product := &model.Product{Name: "widget", Price: money.Amount(1000), Stock: 5}
+	if err := repo.Product.Create(product); err != nil {
+		t.Fatal(err)
+	}
+	coupon := &model.Coupon{Code: "ONCE", Type: model.CouponTypeFixed, Amount: money.Amount(100), Currency: model.DefaultCurrency, UsageLimit: 1}
+	if err := repo.Coupon.Create(coupon); err != nil {
+		t.Fatal(err)
+	}
+
+	input := CreateOrderInput{
+		UserID:     user.ID,
+		CouponCode: coupon.Code,
+		Items:      []OrderItemInput{{ProductID: product.ID, Quantity: 2}},
+	}
+	for i := 0; i < 3; i++ {
+		quote, err := repo.Order.QuoteOrder(input)
+		if err != nil {
+			t.Fatalf("quote %d: %v", i+1, err)
+		}
+		if quote.ID != 0 || quote.Total != money.Amount(1900) {
+			t.Fatalf("quote id %d total %s, want unsaved 19.00", quote.ID, quote.Total)
+		}
+	}
+
+	current, err := repo.Coupon.GetByID(coupon.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.UsedCount != 0 {
+		t.Errorf("coupon used %d times after quotes, want 0", current.UsedCount)
+	}
+	stocked, err := repo.Product.GetByID(product.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stocked.Reserved != 0 {
+		t.Errorf("product reserved %d after quotes, want 0", stocked.Reserved)
+	}
+
+	// 报价没有占用使用次数，仅限一次的优惠券仍可下单
+	order, err := repo.Order.CreateOrder(input)
+	if err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+	if order.Total != money.Amount(1900) || len(order.Discounts) != 1 {
+		t.Errorf("order total %s with %d discounts, want 19.00 with 1", order.Total, len(order.Discounts))
+	}
+	if _, err := repo.Order.QuoteOrder(input); !errors.Is(err, ErrCouponUnavailable) {
+		t.Errorf("quote after coupon used up: err = %v, want ErrCouponUnavailable", err)
+	}
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"time"
+
+	"gin-learn/phase4/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// PaymentRepository 支付记录仓库接口
+type PaymentRepository interface {
+	Create(payment *model.Payment) error
+	GetByID(id model.ID[model.Payment]) (*model.Payment, error)
+	GetByIntent(provider, intentID string) (*model.Payment, error)
+	GetPendingByOrder(orderID model.ID[model.Order]) (*model.Payment, error)
+	GetSucceededByOrder(orderID model.ID[model.Order]) (*model.Payment, error)
+	ListByOrder(orderID model.ID[model.Order]) ([]model.Payment, error)
+	ListByStatus(status string) ([]model.Payment, error)
+	MarkSucceeded(id model.ID[model.Payment]) (bool, error)
+	MarkFailed(id model.ID[model.Payment], reason string) (bool, error)
+	MarkOrphaned(id model.ID[model.Payment]) (bool, error)
+	MarkRefunded(id model.ID[model.Payment], refundRef string) (bool, error)
+}
+
+// paymentRepository 支付记录仓库实现
+type paymentRepository struct {
+	db *gorm.DB
+}
+
+func NewPaymentRepository(db *gorm.DB) PaymentRepository {
+	return &paymentRepository{db: db}
+}
+
+func (r *paymentRepository) Create(payment *model.Payment) error {
+	return r.db.Create(payment).Error
+}
+
+func (r *paymentRepository) GetByID(id model.ID[model.Payment]) (*model.Payment, error) {
+	var payment model.Payment
+	if err := r.db.First(&payment, id).Error; err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+func (r *paymentRepository) GetByIntent(provider, intentID string) (*model.Payment, error) {
+	var payment model.Payment
+	if err := r.db.Where("provider = ? AND intent_id = ?", provider, intentID).First(&payment).Error; err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+func (r *paymentRepository) GetPendingByOrder(orderID model.ID[model.Order]) (*model.Payment, error) {
+	var payment model.Payment
+	err := r.db.Where("order_id = ? AND status = ?", orderID, model.PaymentStatusPending).
+		Order("id DESC").First(&payment).Error
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+func (r *paymentRepository) GetSucceededByOrder(orderID model.ID[model.Order]) (*model.Payment, error) {
+	var payment model.Payment
+	err := r.db.Where("order_id = ? AND status = ?", orderID, model.PaymentStatusSucceeded).First(&payment).Error
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+func (r *paymentRepository) ListByOrder(orderID model.ID[model.Order]) ([]model.Payment, error) {
+	var payments []model.Payment
+	if err := r.db.Where("order_id = ?", orderID).Order("id").Find(&payments).Error; err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
+
+func (r *paymentRepository) ListByStatus(status string) ([]model.Payment, error) {
+	var payments []model.Payment
+	if err := r.db.Where("status = ?", status).Order("id").Find(&payments).Error; err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
+
+// MarkSucceeded 将待支付记录标记为成功，记录已被处理过时返回 false
+func (r *paymentRepository) MarkSucceeded(id model.ID[model.Payment]) (bool, error) {
+	return r.finish(id, model.PaymentStatusPending, map[string]interface{}{
+		"status":  model.PaymentStatusSucceeded,
+		"paid_at": time.Now(),
+	})
+}
+
+// MarkFailed 将待支付记录标记为失败，记录已被处理过时返回 false
+func (r *paymentRepository) MarkFailed(id model.ID[model.Payment], reason string) (bool, error) {
+	return r.finish(id, model.PaymentStatusPending, map[string]interface{}{
+		"status":         model.PaymentStatusFailed,
+		"failure_reason": reason,
+	})
+}
+
+// MarkOrphaned 将成功但无法用于订单的支付标记为孤立支付，等待原路退回
+func (r *paymentRepository) MarkOrphaned(id model.ID[model.Payment]) (bool, error) {
+	return r.finish(id, model.PaymentStatusSucceeded, map[string]interface{}{
+		"status": model.PaymentStatusOrphaned,
+	})
+}
+
+// MarkRefunded 将孤立支付标记为已退回，记录已被处理过时返回 false
+func (r *paymentRepository) MarkRefunded(id model.ID[model.Payment], refundRef string) (bool, error) {
+	return r.finish(id, model.PaymentStatusOrphaned, map[string]interface{}{
+		"status":     model.PaymentStatusRefunded,
+		"refund_ref": refundRef,
+	})
+}
+
+// finish 条件更新，只有处于 from 状态的记录才会被修改，重复的回调不会重复处理
+func (r *paymentRepository) finish(id model.ID[model.Payment], from string, updates map[string]interface{}) (bool, error) {
+	result := r.db.Model(&model.Payment{}).
+		Where("id = ? AND status = ?", id, from).
+		Updates(updates)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/pkg/money"
 
 	"gorm.io/gorm"
 )
@@ -9,11 +10,11 @@ import (
 // ProductRepository 产品仓库接口
 type ProductRepository interface {
 	Create(product *model.Product) error
-	GetByID(id uint) (*model.Product, error)
-	List(page, pageSize int, categoryID uint, keyword string) ([]model.Product, int64, error)
+	GetByID(id model.ID[model.Product]) (*model.Product, error)
+	List(page, pageSize int, categoryID model.ID[model.Category], keyword string, options map[string]string) ([]model.Product, int64, error)
 	Update(product *model.Product) error
-	Delete(id uint) error
-	Search(minPrice, maxPrice float64, categoryID uint, keyword, sortBy, sortOrder string, page, pageSize int) ([]model.Product, int64, error)
+	Delete(id model.ID[model.Product]) error
+	Search(minPrice, maxPrice money.Amount, categoryID model.ID[model.Category], keyword string, options map[string]string, sortBy, sortOrder string, page, pageSize int) ([]model.Product, int64, error)
 }
 
 // productRepository 产品仓库实现
@@ -25,19 +26,42 @@ func NewProductRepository(db *gorm.DB) ProductRepository {
 	return &productRepository{db: db}
 }
 
+// Create 创建产品，初始库存作为期初库存写入默认仓库的库存流水
 func (r *productRepository) Create(product *model.Product) error {
-	return r.db.Create(product).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		stock := product.Stock
+		product.Stock = 0
+		if err := tx.Create(product).Error; err != nil {
+			return err
+		}
+
+		product.Stock = stock
+		return applyStockDelta(tx, &model.StockMovement{
+			ProductID: product.ID,
+			Delta:     stock,
+			Reason:    model.StockReasonImport,
+			Note:      "初始库存",
+		})
+	})
 }
 
-func (r *productRepository) GetByID(id uint) (*model.Product, error) {
+// GetByID 获取产品及其仓库库存、规格类型和全部规格
+func (r *productRepository) GetByID(id model.ID[model.Product]) (*model.Product, error) {
+	byPosition := func(db *gorm.DB) *gorm.DB { return db.Order("position, id") }
+
 	var product model.Product
-	if err := r.db.Preload("Category").First(&product, id).Error; err != nil {
+	err := r.db.Preload("Category").Preload("Stocks").Preload("Stocks.Warehouse").
+		Preload("Options", byPosition).Preload("Options.Values", byPosition).
+		Preload("Variants", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).Preload("Variants.Values").
+		First(&product, id).Error
+	if err != nil {
 		return nil, err
 	}
 	return &product, nil
 }
 
-func (r *productRepository) List(page, pageSize int, categoryID uint, keyword string) ([]model.Product, int64, error) {
+// List 分页获取产品，options 为规格类型名称到取值的映射，只返回有规格匹配全部取值的产品
+func (r *productRepository) List(page, pageSize int, categoryID model.ID[model.Category], keyword string, options map[string]string) ([]model.Product, int64, error) {
 	query := r.db.Model(&model.Product{})
 
 	if categoryID > 0 {
@@ -48,6 +72,8 @@ func (r *productRepository) List(page, pageSize int, categoryID uint, keyword st
 		query = query.Where("name LIKE ? OR description LIKE ?", "%"+keyword+"%", "%"+keyword+"%")
 	}
 
+	query = filterByOptions(r.db, query, options)
+
 	var total int64
 	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, err
@@ -62,15 +88,16 @@ func (r *productRepository) List(page, pageSize int, categoryID uint, keyword st
 	return products, total, nil
 }
 
+// Update 保存产品信息，库存只能通过库存流水修改，不会被覆盖；规格通过 VariantRepository 维护
 func (r *productRepository) Update(product *model.Product) error {
-	return r.db.Save(product).Error
+	return r.db.Omit("stock", "reserved", "Stocks", "Options", "Variants").Save(product).Error
 }
 
-func (r *productRepository) Delete(id uint) error {
+func (r *productRepository) Delete(id model.ID[model.Product]) error {
 	return r.db.Delete(&model.Product{}, id).Error
 }
 
-func (r *productRepository) Search(minPrice, maxPrice float64, categoryID uint, keyword, sortBy, sortOrder string, page, pageSize int) ([]model.Product, int64, error) {
+func (r *productRepository) Search(minPrice, maxPrice money.Amount, categoryID model.ID[model.Category], keyword string, options map[string]string, sortBy, sortOrder string, page, pageSize int) ([]model.Product, int64, error) {
 	query := r.db.Model(&model.Product{})
 
 	if keyword != "" {
@@ -86,6 +113,7 @@ func (r *productRepository) Search(minPrice, maxPrice float64, categoryID uint,
 	if categoryID > 0 {
 		query = query.Where("category_id = ?", categoryID)
 	}
+	query = filterByOptions(r.db, query, options)
 
 	orderStr := sortBy + " " + sortOrder
 	query = query.Order(orderStr)
@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/pkg/money"
+
+	"gorm.io/gorm"
+)
+
+// PromotionRepository 促销活动仓库接口
+type PromotionRepository interface {
+	Create(promotion *model.Promotion) error
+	GetByID(id model.ID[model.Promotion]) (*model.Promotion, error)
+	List() ([]model.Promotion, error)
+	Update(promotion *model.Promotion) error
+	Delete(id model.ID[model.Promotion]) error
+}
+
+// promotionRepository 促销活动仓库实现
+type promotionRepository struct {
+	db *gorm.DB
+}
+
+func NewPromotionRepository(db *gorm.DB) PromotionRepository {
+	return &promotionRepository{db: db}
+}
+
+func (r *promotionRepository) Create(promotion *model.Promotion) error {
+	return r.db.Create(promotion).Error
+}
+
+func (r *promotionRepository) GetByID(id model.ID[model.Promotion]) (*model.Promotion, error) {
+	var promotion model.Promotion
+	if err := r.db.First(&promotion, id).Error; err != nil {
+		return nil, err
+	}
+	return &promotion, nil
+}
+
+func (r *promotionRepository) List() ([]model.Promotion, error) {
+	var promotions []model.Promotion
+	if err := r.db.Order("id DESC").Find(&promotions).Error; err != nil {
+		return nil, err
+	}
+	return promotions, nil
+}
+
+func (r *promotionRepository) Update(promotion *model.Promotion) error {
+	result := r.db.Model(promotion).Select("name", "buy_quantity", "free_quantity",
+		"category_id", "product_id", "starts_at", "ends_at").
+		Updates(promotion)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *promotionRepository) Delete(id model.ID[model.Promotion]) error {
+	result := r.db.Delete(&model.Promotion{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// applyPromotions 为每个订单项选择优惠金额最多的有效促销，一个订单项最多参加一个促销
+func applyPromotions(tx *gorm.DB, order *model.Order, lines []orderLine, now time.Time) ([]model.OrderDiscount, error) {
+	var promotions []model.Promotion
+	if err := tx.Order("id").Find(&promotions).Error; err != nil {
+		return nil, err
+	}
+
+	var discounts []model.OrderDiscount
+	for i := range lines {
+		line := &lines[i]
+
+		var (
+			best       *model.Promotion
+			bestAmount money.Amount
+		)
+		for j := range promotions {
+			p := &promotions[j]
+			if !activeAt(p.StartsAt, p.EndsAt, now) || !line.matches(p.CategoryID, p.ProductID) {
+				continue
+			}
+			// 每 BuyQuantity + FreeQuantity 件中有 FreeQuantity 件免费
+			free := line.item.Quantity / (p.BuyQuantity + p.FreeQuantity) * p.FreeQuantity
+			if amount := line.item.Price.Mul(free); amount > bestAmount {
+				best, bestAmount = p, amount
+			}
+		}
+		if best == nil {
+			continue
+		}
+
+		line.item.Discount += bestAmount
+		discounts = append(discounts, model.OrderDiscount{
+			OrderID:     order.ID,
+			Source:      model.DiscountSourcePromotion,
+			PromotionID: &best.ID,
+			OrderItemID: &line.item.ID,
+			Description: fmt.Sprintf("%s（买 %d 送 %d）", best.Name, best.BuyQuantity, best.FreeQuantity),
+			Amount:      bestAmount,
+		})
+	}
+	return discounts, nil
+}
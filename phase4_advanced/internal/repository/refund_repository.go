@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"gin-learn/phase4/internal/model"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrRefundExceeded 退款金额或数量超过订单可退范围
+	ErrRefundExceeded = errors.New("退款超过可退范围")
+	// ErrRefundNotPending 退款已完成或已失败
+	ErrRefundNotPending = errors.New("退款不是待处理状态")
+)
+
+// RefundRepository 退款仓库接口
+type RefundRepository interface {
+	Create(refund *model.Refund) error
+	Complete(refund *model.Refund, paymentID *model.ID[model.Payment], ref string) error
+	Fail(refund *model.Refund) error
+	ListByOrder(orderID model.ID[model.Order]) ([]model.Refund, error)
+}
+
+// refundRepository 退款仓库实现
+type refundRepository struct {
+	db *gorm.DB
+}
+
+func NewRefundRepository(db *gorm.DB) RefundRepository {
+	return &refundRepository{db: db}
+}
+
+// Create 以待处理状态记录退款，在同一事务中累加订单项退款数量和订单退款金额，占用可退范围，
+// 并确定退款完成时退回库存的数量：未发货的商品总是退回，已发货的商品按 Restock 退回。
+// 所有累加都使用条件更新，并发退款也不会超过订单金额或购买数量
+func (r *refundRepository) Create(refund *model.Refund) error {
+	refund.Status = model.RefundStatusPending
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for i := range refund.Items {
+			line := &refund.Items[i]
+			var item model.OrderItem
+			if err := tx.Where("id = ? AND order_id = ?", line.OrderItemID, refund.OrderID).First(&item).Error; err != nil {
+				return fmt.Errorf("订单项不存在: %s", line.OrderItemID)
+			}
+
+			// 发货不会发出已退款的商品，未发货且未退款的数量即本次可退回库存的未发货商品
+			line.RestockQuantity = min(line.Quantity, max(item.Quantity-item.ShippedQuantity-item.RefundedQuantity, 0))
+			if line.Restock {
+				line.RestockQuantity = line.Quantity
+			}
+			line.RestockQuantity = min(line.RestockQuantity, item.Quantity-item.RestockedQuantity)
+
+			result := tx.Model(&model.OrderItem{}).
+				Where("id = ? AND refunded_quantity + ? <= quantity", item.ID, line.Quantity).
+				Update("refunded_quantity", gorm.Expr("refunded_quantity + ?", line.Quantity))
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return fmt.Errorf("%w: 订单项 %s 可退数量不足", ErrRefundExceeded, item.ID)
+			}
+		}
+
+		result := tx.Model(&model.Order{}).
+			Where("id = ? AND refunded + ? <= total", refund.OrderID, refund.Amount).
+			Update("refunded", gorm.Expr("refunded + ?", refund.Amount))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("%w: 退款总额不能超过订单金额", ErrRefundExceeded)
+		}
+
+		return tx.Create(refund).Error
+	})
+}
+
+// Complete 将待处理的退款标记为完成，记录支付渠道的退款单号并退回库存，线下退款时 paymentID 为 nil
+func (r *refundRepository) Complete(refund *model.Refund, paymentID *model.ID[model.Payment], ref string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.Refund{}).
+			Where("id = ? AND status = ?", refund.ID, model.RefundStatusPending).
+			Updates(map[string]interface{}{
+				"status":     model.RefundStatusSucceeded,
+				"payment_id": paymentID,
+				"refund_ref": ref,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRefundNotPending
+		}
+
+		for _, line := range refund.Items {
+			var item model.OrderItem
+			if err := tx.First(&item, line.OrderItemID).Error; err != nil {
+				return err
+			}
+			if err := restockItem(tx, &item, line.RestockQuantity, model.StockReasonRefund, refund.ActorID); err != nil {
+				return err
+			}
+		}
+
+		refund.Status = model.RefundStatusSucceeded
+		refund.PaymentID = paymentID
+		refund.RefundRef = ref
+		return nil
+	})
+}
+
+// Fail 将待处理的退款标记为失败，并退回 Create 占用的订单项退款数量和订单退款金额
+func (r *refundRepository) Fail(refund *model.Refund) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.Refund{}).
+			Where("id = ? AND status = ?", refund.ID, model.RefundStatusPending).
+			Update("status", model.RefundStatusFailed)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRefundNotPending
+		}
+
+		for _, line := range refund.Items {
+			err := tx.Model(&model.OrderItem{}).Where("id = ?", line.OrderItemID).
+				Update("refunded_quantity", gorm.Expr("refunded_quantity - ?", line.Quantity)).Error
+			if err != nil {
+				return err
+			}
+		}
+		err := tx.Model(&model.Order{}).Where("id = ?", refund.OrderID).
+			Update("refunded", gorm.Expr("refunded - ?", refund.Amount)).Error
+		if err != nil {
+			return err
+		}
+
+		refund.Status = model.RefundStatusFailed
+		return nil
+	})
+}
+
+func (r *refundRepository) ListByOrder(orderID model.ID[model.Order]) ([]model.Refund, error) {
+	var refunds []model.Refund
+	if err := r.db.Preload("Items").Where("order_id = ?", orderID).Order("id").Find(&refunds).Error; err != nil {
+		return nil, err
+	}
+	return refunds, nil
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"fmt"
+	"strings"
 
 	"gin-learn/phase4/config"
 	"gin-learn/phase4/internal/model"
@@ -22,7 +23,7 @@ func InitDB() (*gorm.DB, error) {
 
 	switch cfg.Type {
 	case "sqlite":
-		db, err = gorm.Open(sqlite.Open(cfg.Database), &gorm.Config{
+		db, err = gorm.Open(sqlite.Open(sqliteDSN(cfg.Database)), &gorm.Config{
 			Logger: gormlogger.Default.LogMode(getLogMode(cfg.LogMode)),
 		})
 	default:
@@ -33,21 +34,77 @@ func InitDB() (*gorm.DB, error) {
 		return nil, fmt.Errorf("failed to connect database: %w", err)
 	}
 
+	// 数据迁移
+	if err := runMigrations(db); err != nil {
+		return nil, fmt.Errorf("failed to migrate data: %w", err)
+	}
+
 	// 自动迁移
 	if err := db.AutoMigrate(
+		&model.Permission{},
+		&model.Role{},
 		&model.User{},
 		&model.Category{},
 		&model.Product{},
+		&model.ProductOption{},
+		&model.ProductOptionValue{},
+		&model.ProductVariant{},
 		&model.Order{},
 		&model.OrderItem{},
+		&model.OrderStatusHistory{},
+		&model.RefreshToken{},
+		&model.RevokedToken{},
+		&model.IdempotencyKey{},
+		&model.CartItem{},
+		&model.Address{},
+		&model.Payment{},
+		&model.Refund{},
+		&model.RefundItem{},
+		&model.ExchangeRate{},
+		&model.Coupon{},
+		&model.CouponRedemption{},
+		&model.Promotion{},
+		&model.OrderDiscount{},
+		&model.StockReservation{},
+		&model.StockMovement{},
+		&model.Warehouse{},
+		&model.WarehouseStock{},
+		&model.StockTransfer{},
+		&model.OrderItemAllocation{},
+		&model.StockAlert{},
+		&model.ShippingZone{},
+		&model.TaxRate{},
+		&model.Shipment{},
+		&model.ShipmentItem{},
+		&model.ReturnRequest{},
+		&model.ReturnItem{},
 	); err != nil {
 		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
 
+	// 初始化默认角色和权限
+	if err := seedRBAC(db); err != nil {
+		return nil, fmt.Errorf("failed to seed roles: %w", err)
+	}
+
+	// 初始化默认仓库
+	if err := seedWarehouse(db); err != nil {
+		return nil, fmt.Errorf("failed to seed warehouse: %w", err)
+	}
+
 	logger.Info("Database initialized successfully")
 	return db, nil
 }
 
+// sqliteDSN 为 SQLite 连接设置锁等待时间，并让写事务在开始时即获取写锁，
+// 避免并发事务在读后升级写锁时互相等待而直接失败
+func sqliteDSN(database string) string {
+	if strings.Contains(database, "?") {
+		return database
+	}
+	return database + "?_busy_timeout=5000&_txlock=immediate"
+}
+
 func getLogMode(logMode bool) gormlogger.LogLevel {
 	if logMode {
 		return gormlogger.Info
@@ -57,18 +114,66 @@ func getLogMode(logMode bool) gormlogger.LogLevel {
 
 // Repository 仓库接口
 type Repository struct {
-	User     UserRepository
-	Product  ProductRepository
-	Category CategoryRepository
-	Order    OrderRepository
+	db *gorm.DB
+
+	User         UserRepository
+	Product      ProductRepository
+	Variant      VariantRepository
+	Category     CategoryRepository
+	Order        OrderRepository
+	Role         RoleRepository
+	Token        TokenRepository
+	Idempotency  IdempotencyRepository
+	Cart         CartRepository
+	Address      AddressRepository
+	Payment      PaymentRepository
+	Refund       RefundRepository
+	ExchangeRate ExchangeRateRepository
+	Coupon       CouponRepository
+	Promotion    PromotionRepository
+	Stock        StockRepository
+	Warehouse    WarehouseRepository
+	StockAlert   StockAlertRepository
+	ShippingZone ShippingZoneRepository
+	TaxRate      TaxRateRepository
+	Shipment     ShipmentRepository
+	Return       ReturnRepository
 }
 
 // NewRepository 创建仓库实例
 func NewRepository(db *gorm.DB) *Repository {
 	return &Repository{
-		User:     NewUserRepository(db),
-		Product:  NewProductRepository(db),
-		Category: NewCategoryRepository(db),
-		Order:    NewOrderRepository(db),
+		db: db,
+
+		User:         NewUserRepository(db),
+		Product:      NewProductRepository(db),
+		Variant:      NewVariantRepository(db),
+		Category:     NewCategoryRepository(db),
+		Order:        NewOrderRepository(db),
+		Role:         NewRoleRepository(db),
+		Token:        NewTokenRepository(db),
+		Idempotency:  NewIdempotencyRepository(db),
+		Cart:         NewCartRepository(db),
+		Address:      NewAddressRepository(db),
+		Payment:      NewPaymentRepository(db),
+		Refund:       NewRefundRepository(db),
+		ExchangeRate: NewExchangeRateRepository(db),
+		Coupon:       NewCouponRepository(db),
+		Promotion:    NewPromotionRepository(db),
+		Stock:        NewStockRepository(db),
+		Warehouse:    NewWarehouseRepository(db),
+		StockAlert:   NewStockAlertRepository(db),
+		ShippingZone: NewShippingZoneRepository(db),
+		TaxRate:      NewTaxRateRepository(db),
+		Shipment:     NewShipmentRepository(db),
+		Return:       NewReturnRepository(db),
 	}
 }
+
+// Transaction 在同一个数据库事务中执行 fn，fn 收到的 tx 中所有仓库共享该事务
+// 仓库方法内部的事务会以嵌套事务（SAVEPOINT）的方式执行
+func (r *Repository) Transaction(fn func(tx *Repository) error) error {
+	return r.db.Transaction(func(db *gorm.DB) error {
+		return fn(NewRepository(db))
+	})
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"gin-learn/phase4/internal/model"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrReturnExceeded 退货数量超过可退货数量
+	ErrReturnExceeded = errors.New("退货数量超过可退货数量")
+	// ErrReturnStatusConflict 退货申请状态已被其他请求修改
+	ErrReturnStatusConflict = errors.New("退货申请状态已变更，请刷新后重试")
+)
+
+// openReturnStatuses 尚未结束、占用可退货数量的退货申请状态
+var openReturnStatuses = []string{model.ReturnStatusRequested, model.ReturnStatusApproved}
+
+// ReturnRepository 退货申请仓库接口
+type ReturnRepository interface {
+	Create(ret *model.ReturnRequest) error
+	GetByID(id model.ID[model.ReturnRequest]) (*model.ReturnRequest, error)
+	ListByOrder(orderID model.ID[model.Order]) ([]model.ReturnRequest, error)
+	Transition(id model.ID[model.ReturnRequest], from, to string, updates map[string]interface{}) error
+}
+
+// returnRepository 退货申请仓库实现
+type returnRepository struct {
+	db *gorm.DB
+}
+
+func NewReturnRepository(db *gorm.DB) ReturnRepository {
+	return &returnRepository{db: db}
+}
+
+// Create 在事务中校验并保存退货申请，每个订单项的退货数量加上已退款数量和其他未结束申请中的数量不能超过购买数量
+func (r *returnRepository) Create(ret *model.ReturnRequest) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, line := range ret.Items {
+			var item model.OrderItem
+			if err := tx.Where("id = ? AND order_id = ?", line.OrderItemID, ret.OrderID).First(&item).Error; err != nil {
+				return fmt.Errorf("订单项不存在: %s", line.OrderItemID)
+			}
+
+			var pending int
+			err := tx.Model(&model.ReturnItem{}).
+				Joins("JOIN return_requests ON return_requests.id = return_items.return_id").
+				Where("return_items.order_item_id = ? AND return_requests.status IN ?", item.ID, openReturnStatuses).
+				Select("COALESCE(SUM(return_items.quantity), 0)").Scan(&pending).Error
+			if err != nil {
+				return err
+			}
+
+			available := item.Quantity - item.RefundedQuantity - pending
+			if line.Quantity > available {
+				return fmt.Errorf("%w: 订单项 %s 可退货 %d 件", ErrReturnExceeded, item.ID, max(available, 0))
+			}
+		}
+
+		return tx.Create(ret).Error
+	})
+}
+
+func (r *returnRepository) GetByID(id model.ID[model.ReturnRequest]) (*model.ReturnRequest, error) {
+	var ret model.ReturnRequest
+	if err := r.db.Preload("Items").First(&ret, id).Error; err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+func (r *returnRepository) ListByOrder(orderID model.ID[model.Order]) ([]model.ReturnRequest, error) {
+	var returns []model.ReturnRequest
+	if err := r.db.Preload("Items").Where("order_id = ?", orderID).Order("id").Find(&returns).Error; err != nil {
+		return nil, err
+	}
+	return returns, nil
+}
+
+// Transition 以条件更新的方式将退货申请从 from 状态变更为 to 状态并更新其他字段，
+// 状态已被其他请求修改时返回 ErrReturnStatusConflict
+func (r *returnRepository) Transition(id model.ID[model.ReturnRequest], from, to string, updates map[string]interface{}) error {
+	values := map[string]interface{}{"status": to}
+	for k, v := range updates {
+		values[k] = v
+	}
+
+	result := r.db.Model(&model.ReturnRequest{}).Where("id = ? AND status = ?", id, from).Updates(values)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrReturnStatusConflict
+	}
+	return nil
+}
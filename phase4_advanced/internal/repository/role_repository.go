@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"gin-learn/phase4/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// RoleRepository 角色仓库接口
+type RoleRepository interface {
+	Create(role *model.Role) error
+	GetByID(id model.ID[model.Role]) (*model.Role, error)
+	GetByNames(names []string) ([]model.Role, error)
+	List() ([]model.Role, error)
+	Update(role *model.Role) error
+	Delete(id model.ID[model.Role]) error
+	ListPermissions() ([]model.Permission, error)
+	GetPermissionsByCodes(codes []string) ([]model.Permission, error)
+	SetUserRoles(userID model.ID[model.User], roles []model.Role) error
+	AddUserRoles(userID model.ID[model.User], roles []model.Role) error
+}
+
+// roleRepository 角色仓库实现
+type roleRepository struct {
+	db *gorm.DB
+}
+
+func NewRoleRepository(db *gorm.DB) RoleRepository {
+	return &roleRepository{db: db}
+}
+
+func (r *roleRepository) Create(role *model.Role) error {
+	return r.db.Create(role).Error
+}
+
+func (r *roleRepository) GetByID(id model.ID[model.Role]) (*model.Role, error) {
+	var role model.Role
+	if err := r.db.Preload("Permissions").First(&role, id).Error; err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (r *roleRepository) GetByNames(names []string) ([]model.Role, error) {
+	var roles []model.Role
+	if err := r.db.Where("name IN ?", names).Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (r *roleRepository) List() ([]model.Role, error) {
+	var roles []model.Role
+	if err := r.db.Preload("Permissions").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+// Update 保存角色信息，并用 role.Permissions 替换原有权限
+func (r *roleRepository) Update(role *model.Role) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Permissions").Save(role).Error; err != nil {
+			return err
+		}
+		return tx.Model(role).Association("Permissions").Replace(role.Permissions)
+	})
+}
+
+func (r *roleRepository) Delete(id model.ID[model.Role]) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		role := model.Role{ID: id}
+		if err := tx.Model(&role).Association("Permissions").Clear(); err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM user_roles WHERE role_id = ?", id).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&role).Error
+	})
+}
+
+func (r *roleRepository) ListPermissions() ([]model.Permission, error) {
+	var perms []model.Permission
+	if err := r.db.Order("code").Find(&perms).Error; err != nil {
+		return nil, err
+	}
+	return perms, nil
+}
+
+func (r *roleRepository) GetPermissionsByCodes(codes []string) ([]model.Permission, error) {
+	var perms []model.Permission
+	if err := r.db.Where("code IN ?", codes).Find(&perms).Error; err != nil {
+		return nil, err
+	}
+	return perms, nil
+}
+
+// SetUserRoles 用 roles 替换用户原有的角色
+func (r *roleRepository) SetUserRoles(userID model.ID[model.User], roles []model.Role) error {
+	user := model.User{ID: userID}
+	return r.db.Model(&user).Association("Roles").Replace(roles)
+}
+
+// AddUserRoles 为用户追加角色，已拥有的角色会被忽略
+func (r *roleRepository) AddUserRoles(userID model.ID[model.User], roles []model.Role) error {
+	user := model.User{ID: userID}
+	return r.db.Model(&user).Association("Roles").Append(roles)
+}
+
+// 默认权限
+var defaultPermissions = []model.Permission{
+	{Code: model.PermUserRead, Description: "查看用户"},
+	{Code: model.PermUserWrite, Description: "修改和删除用户"},
+	{Code: model.PermProductWrite, Description: "创建、修改和删除产品"},
+	{Code: model.PermCategoryWrite, Description: "创建分类"},
+	{Code: model.PermOrderManage, Description: "查看和处理所有用户的订单"},
+	{Code: model.PermRoleManage, Description: "管理角色和用户授权"},
+	{Code: model.PermPromotionManage, Description: "管理优惠券和促销活动"},
+}
+
+// 默认角色及其权限
+var defaultRoles = []struct {
+	Name        string
+	Description string
+	Permissions []string
+}{
+	{
+		Name:        model.RoleAdmin,
+		Description: "管理员",
+		Permissions: []string{
+			model.PermUserRead, model.PermUserWrite, model.PermProductWrite,
+			model.PermCategoryWrite, model.PermOrderManage, model.PermRoleManage,
+			model.PermPromotionManage,
+		},
+	},
+	{
+		Name:        model.RoleStaff,
+		Description: "运营人员",
+		Permissions: []string{
+			model.PermUserRead, model.PermProductWrite, model.PermCategoryWrite, model.PermOrderManage,
+			model.PermPromotionManage,
+		},
+	},
+	{
+		Name:        model.RoleCustomer,
+		Description: "普通用户",
+	},
+}
+
+// seedRBAC 写入默认权限和角色，内置角色只在首次创建时授予默认权限，之后由管理员维护
+func seedRBAC(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		perms := make(map[string]model.Permission, len(defaultPermissions))
+		for _, p := range defaultPermissions {
+			perm := p
+			if err := tx.Where(model.Permission{Code: p.Code}).Attrs(p).FirstOrCreate(&perm).Error; err != nil {
+				return err
+			}
+			perms[perm.Code] = perm
+		}
+
+		for _, r := range defaultRoles {
+			role := model.Role{Name: r.Name}
+			attrs := model.Role{Description: r.Description, Builtin: true}
+			result := tx.Where(model.Role{Name: r.Name}).Attrs(attrs).FirstOrCreate(&role)
+			if result.Error != nil {
+				return result.Error
+			}
+
+			// 角色已存在时保留管理员的修改，不再补回被移除的权限
+			if result.RowsAffected == 0 || len(r.Permissions) == 0 {
+				continue
+			}
+			rolePerms := make([]model.Permission, 0, len(r.Permissions))
+			for _, code := range r.Permissions {
+				rolePerms = append(rolePerms, perms[code])
+			}
+			if err := tx.Model(&role).Association("Permissions").Append(rolePerms); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
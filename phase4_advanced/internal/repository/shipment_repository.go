@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"gin-learn/phase4/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// ErrShipmentExceeded 发货数量超过订单项尚未发货的数量
+var ErrShipmentExceeded = errors.New("发货数量超过可发货数量")
+
+// ShipmentRepository 发货单仓库接口
+type ShipmentRepository interface {
+	Create(shipment *model.Shipment) error
+	GetByID(id model.ID[model.Shipment]) (*model.Shipment, error)
+	ListByOrder(orderID model.ID[model.Order]) ([]model.Shipment, error)
+	UpdateTracking(id model.ID[model.Shipment], carrier, trackingNumber string) error
+	RemainingQuantity(orderID model.ID[model.Order]) (int, error)
+}
+
+// shipmentRepository 发货单仓库实现
+type shipmentRepository struct {
+	db *gorm.DB
+}
+
+func NewShipmentRepository(db *gorm.DB) ShipmentRepository {
+	return &shipmentRepository{db: db}
+}
+
+// Create 在同一事务中保存发货单并累加订单项的已发货数量。已退款的数量不再发货；
+// 指定了发货仓库时，每个订单项从该仓库发出的数量不能超过分配到该仓库的数量
+func (r *shipmentRepository) Create(shipment *model.Shipment) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, line := range shipment.Items {
+			var item model.OrderItem
+			if err := tx.Where("id = ? AND order_id = ?", line.OrderItemID, shipment.OrderID).First(&item).Error; err != nil {
+				return fmt.Errorf("订单项不存在: %s", line.OrderItemID)
+			}
+
+			if shipment.WarehouseID != 0 {
+				if err := checkWarehouseShippable(tx, &item, shipment.WarehouseID, line.Quantity); err != nil {
+					return err
+				}
+			}
+
+			result := tx.Model(&model.OrderItem{}).
+				Where("id = ? AND shipped_quantity + refunded_quantity + ? <= quantity", item.ID, line.Quantity).
+				Update("shipped_quantity", gorm.Expr("shipped_quantity + ?", line.Quantity))
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return fmt.Errorf("%w: 订单项 %s", ErrShipmentExceeded, item.ID)
+			}
+		}
+
+		return tx.Create(shipment).Error
+	})
+}
+
+func (r *shipmentRepository) GetByID(id model.ID[model.Shipment]) (*model.Shipment, error) {
+	var shipment model.Shipment
+	if err := r.db.Preload("Items").First(&shipment, id).Error; err != nil {
+		return nil, err
+	}
+	return &shipment, nil
+}
+
+func (r *shipmentRepository) ListByOrder(orderID model.ID[model.Order]) ([]model.Shipment, error) {
+	var shipments []model.Shipment
+	if err := r.db.Preload("Items").Where("order_id = ?", orderID).Order("id").Find(&shipments).Error; err != nil {
+		return nil, err
+	}
+	return shipments, nil
+}
+
+// UpdateTracking 修改发货单的承运商和运单号
+func (r *shipmentRepository) UpdateTracking(id model.ID[model.Shipment], carrier, trackingNumber string) error {
+	result := r.db.Model(&model.Shipment{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"carrier":         carrier,
+		"tracking_number": trackingNumber,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// RemainingQuantity 订单中尚未发货且未退款的商品数量
+func (r *shipmentRepository) RemainingQuantity(orderID model.ID[model.Order]) (int, error) {
+	var remaining int
+	err := r.db.Model(&model.OrderItem{}).
+		Select("COALESCE(SUM(CASE WHEN quantity > shipped_quantity + refunded_quantity THEN quantity - shipped_quantity - refunded_quantity ELSE 0 END), 0)").
+		Where("order_id = ?", orderID).Scan(&remaining).Error
+	return remaining, err
+}
+
+// checkWarehouseShippable 校验订单项从指定仓库发出 quantity 件后不超过分配到该仓库的数量，
+// 没有仓库分配记录的旧订单不做校验
+func checkWarehouseShippable(tx *gorm.DB, item *model.OrderItem, warehouseID model.ID[model.Warehouse], quantity int) error {
+	var allocations []model.OrderItemAllocation
+	if err := tx.Where("order_item_id = ?", item.ID).Find(&allocations).Error; err != nil {
+		return err
+	}
+	if len(allocations) == 0 {
+		return nil
+	}
+
+	allocated := 0
+	for _, allocation := range allocations {
+		if allocation.WarehouseID == warehouseID {
+			allocated += allocation.Quantity
+		}
+	}
+
+	var shipped int
+	err := tx.Model(&model.ShipmentItem{}).
+		Joins("JOIN shipments ON shipments.id = shipment_items.shipment_id").
+		Where("shipment_items.order_item_id = ? AND shipments.warehouse_id = ?", item.ID, warehouseID).
+		Select("COALESCE(SUM(shipment_items.quantity), 0)").Scan(&shipped).Error
+	if err != nil {
+		return err
+	}
+
+	if shipped+quantity > allocated {
+		return fmt.Errorf("%w: 订单项 %s 在仓库 %s 的可发货数量为 %d", ErrShipmentExceeded, item.ID, warehouseID, allocated-shipped)
+	}
+	return nil
+}
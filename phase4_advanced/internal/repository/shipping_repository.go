@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/pkg/money"
+
+	"gorm.io/gorm"
+)
+
+// ErrShippingUnavailable 收货地区没有匹配的配送区域
+var ErrShippingUnavailable = errors.New("收货地区不在配送范围内")
+
+// ShippingZoneRepository 配送区域仓库接口
+type ShippingZoneRepository interface {
+	Create(zone *model.ShippingZone) error
+	GetByID(id model.ID[model.ShippingZone]) (*model.ShippingZone, error)
+	List() ([]model.ShippingZone, error)
+	Update(zone *model.ShippingZone) error
+	Delete(id model.ID[model.ShippingZone]) error
+}
+
+// shippingZoneRepository 配送区域仓库实现
+type shippingZoneRepository struct {
+	db *gorm.DB
+}
+
+func NewShippingZoneRepository(db *gorm.DB) ShippingZoneRepository {
+	return &shippingZoneRepository{db: db}
+}
+
+func (r *shippingZoneRepository) Create(zone *model.ShippingZone) error {
+	return r.db.Create(zone).Error
+}
+
+func (r *shippingZoneRepository) GetByID(id model.ID[model.ShippingZone]) (*model.ShippingZone, error) {
+	var zone model.ShippingZone
+	if err := r.db.First(&zone, id).Error; err != nil {
+		return nil, err
+	}
+	return &zone, nil
+}
+
+func (r *shippingZoneRepository) List() ([]model.ShippingZone, error) {
+	var zones []model.ShippingZone
+	if err := r.db.Order("id").Find(&zones).Error; err != nil {
+		return nil, err
+	}
+	return zones, nil
+}
+
+// Update 保存配送区域的全部字段
+func (r *shippingZoneRepository) Update(zone *model.ShippingZone) error {
+	result := r.db.Model(zone).Select("*").Omit("id", "created_at").Updates(zone)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *shippingZoneRepository) Delete(id model.ID[model.ShippingZone]) error {
+	result := r.db.Delete(&model.ShippingZone{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// matchShippingZone 按收货省份匹配配送区域：优先匹配 Regions 中列出该省份的区域，其次使用 Regions 为空的区域。
+// 没有配置任何配送区域时返回 nil，表示不收运费
+func matchShippingZone(tx *gorm.DB, region string) (*model.ShippingZone, error) {
+	var zones []model.ShippingZone
+	if err := tx.Order("id").Find(&zones).Error; err != nil {
+		return nil, err
+	}
+	if len(zones) == 0 {
+		return nil, nil
+	}
+
+	var fallback *model.ShippingZone
+	for i := range zones {
+		if zones[i].Regions == "" {
+			if fallback == nil {
+				fallback = &zones[i]
+			}
+			continue
+		}
+		for _, r := range strings.Split(zones[i].Regions, ",") {
+			if region != "" && strings.TrimSpace(r) == region {
+				return &zones[i], nil
+			}
+		}
+	}
+	if fallback == nil {
+		return nil, fmt.Errorf("%w: %q", ErrShippingUnavailable, region)
+	}
+	return fallback, nil
+}
+
+// shippingFee 计算订单运费并换算为订单货币，weight 为商品总重量（克），
+// merchandise 为优惠后的商品金额，用于判断是否满额免运费
+func shippingFee(tx *gorm.DB, zone *model.ShippingZone, currency string, weight int, merchandise money.Amount) (money.Amount, error) {
+	if zone == nil {
+		return 0, nil
+	}
+
+	rate, err := NewExchangeRateRepository(tx).Rate(zone.Currency, currency)
+	if err != nil {
+		return 0, err
+	}
+	if zone.FreeAbove > 0 {
+		freeAbove, err := zone.FreeAbove.MulRate(rate)
+		if err != nil {
+			return 0, err
+		}
+		if merchandise >= freeAbove {
+			return 0, nil
+		}
+	}
+
+	fee := zone.Fee
+	if zone.Method == model.ShippingMethodWeight && weight > zone.FirstWeight && zone.StepWeight > 0 {
+		steps := (weight - zone.FirstWeight + zone.StepWeight - 1) / zone.StepWeight
+		fee += zone.StepFee.Mul(steps)
+	}
+	return fee.MulRate(rate)
+}
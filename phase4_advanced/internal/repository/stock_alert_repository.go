@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"time"
+
+	"gin-learn/phase4/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// StockAlertRepository 低库存告警仓库接口
+type StockAlertRepository interface {
+	Check(productIDs []model.ID[model.Product]) ([]model.StockAlert, error)
+	List(status string, page, pageSize int) ([]model.StockAlert, int64, error)
+	Resolve(id model.ID[model.StockAlert], actorID model.ID[model.User]) error
+}
+
+// stockAlertRepository 低库存告警仓库实现
+type stockAlertRepository struct {
+	db *gorm.DB
+}
+
+func NewStockAlertRepository(db *gorm.DB) StockAlertRepository {
+	return &stockAlertRepository{db: db}
+}
+
+// Check 检查产品的可售库存：低于补货点且没有未处理告警的产品创建新告警，
+// 库存已恢复（或取消了补货点）的产品自动关闭未处理的告警。返回新创建的告警
+func (r *stockAlertRepository) Check(productIDs []model.ID[model.Product]) ([]model.StockAlert, error) {
+	if len(productIDs) == 0 {
+		return nil, nil
+	}
+
+	var opened []model.StockAlert
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var products []model.Product
+		if err := tx.Where("id IN ?", productIDs).Find(&products).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for _, product := range products {
+			if product.ReorderPoint > 0 && product.Available < product.ReorderPoint {
+				alert := model.StockAlert{
+					ProductID:    product.ID,
+					Available:    product.Available,
+					ReorderPoint: product.ReorderPoint,
+					Status:       model.StockAlertOpen,
+				}
+				// 已有未处理告警时唯一索引冲突，不重复告警
+				result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&alert)
+				if result.Error != nil {
+					return result.Error
+				}
+				if result.RowsAffected > 0 {
+					alert.Product = product
+					opened = append(opened, alert)
+				}
+				continue
+			}
+
+			err := tx.Model(&model.StockAlert{}).
+				Where("product_id = ? AND status = ?", product.ID, model.StockAlertOpen).
+				Updates(map[string]interface{}{"status": model.StockAlertResolved, "resolved_at": now}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return opened, nil
+}
+
+// List 分页获取告警，最新的在前，status 为空时返回全部状态
+func (r *stockAlertRepository) List(status string, page, pageSize int) ([]model.StockAlert, int64, error) {
+	query := r.db.Model(&model.StockAlert{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var alerts []model.StockAlert
+	offset := (page - 1) * pageSize
+	if err := query.Preload("Product").Order("id DESC").Offset(offset).Limit(pageSize).Find(&alerts).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return alerts, total, nil
+}
+
+// Resolve 手动关闭未处理的告警，告警不存在或已关闭时返回 gorm.ErrRecordNotFound
+func (r *stockAlertRepository) Resolve(id model.ID[model.StockAlert], actorID model.ID[model.User]) error {
+	result := r.db.Model(&model.StockAlert{}).
+		Where("id = ? AND status = ?", id, model.StockAlertOpen).
+		Updates(map[string]interface{}{
+			"status":      model.StockAlertResolved,
+			"resolved_by": actorID,
+			"resolved_at": time.Now(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package repository
+
+import (
+	"fmt"
+
+	"gin-learn/phase4/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// StockDrift 现有库存与库存流水合计不一致。WarehouseID 为 0 表示各仓库合计：
+// VariantID 也为 0 时为 products.stock，否则为 product_variants.stock
+type StockDrift struct {
+	ProductID   model.ID[model.Product]        `json:"product_id"`
+	VariantID   model.ID[model.ProductVariant] `json:"variant_id,omitempty"`
+	WarehouseID model.ID[model.Warehouse]      `json:"warehouse_id,omitempty"`
+	Name        string                         `json:"name"`
+	Stock       int                            `json:"stock"`        // products.stock、product_variants.stock 或 warehouse_stocks.stock
+	LedgerStock int                            `json:"ledger_stock"` // 库存流水合计
+}
+
+// StockRepository 库存流水仓库接口
+type StockRepository interface {
+	Apply(movement *model.StockMovement) error
+	ListMovements(productID model.ID[model.Product], warehouseID model.ID[model.Warehouse], page, pageSize int) ([]model.StockMovement, int64, error)
+	Reconcile() ([]StockDrift, error)
+	ResetToLedger(drift StockDrift) error
+}
+
+// stockRepository 库存流水仓库实现
+type stockRepository struct {
+	db *gorm.DB
+}
+
+func NewStockRepository(db *gorm.DB) StockRepository {
+	return &stockRepository{db: db}
+}
+
+// Apply 在事务中修改现有库存并写入流水。有规格的产品入库时必须指定规格，
+// 未指定规格时只能出库，用于清理规格创建之前的订单退回的库存
+func (r *stockRepository) Apply(movement *model.StockMovement) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if movement.VariantID == 0 && movement.Delta > 0 {
+			if _, err := productVariant(tx, movement.ProductID, 0); err != nil {
+				return err
+			}
+		}
+		return applyStockDelta(tx, movement)
+	})
+}
+
+// ListMovements 分页获取产品的库存流水，最新的在前，warehouseID 为 0 时不按仓库过滤
+func (r *stockRepository) ListMovements(productID model.ID[model.Product], warehouseID model.ID[model.Warehouse], page, pageSize int) ([]model.StockMovement, int64, error) {
+	query := r.db.Model(&model.StockMovement{}).Where("product_id = ?", productID)
+	if warehouseID > 0 {
+		query = query.Where("warehouse_id = ?", warehouseID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var movements []model.StockMovement
+	offset := (page - 1) * pageSize
+	if err := query.Order("id DESC").Offset(offset).Limit(pageSize).Find(&movements).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return movements, total, nil
+}
+
+// Reconcile 按库存流水重新计算每个产品的库存，返回 products.stock 与全部流水合计不一致的产品、
+// product_variants.stock 与该规格流水合计不一致的规格，以及 warehouse_stocks.stock 与该仓库流水合计不一致的仓库库存
+func (r *stockRepository) Reconcile() ([]StockDrift, error) {
+	const productLedger = "COALESCE((SELECT SUM(delta) FROM stock_movements WHERE stock_movements.product_id = products.id), 0)"
+	var drifts []StockDrift
+	err := r.db.Model(&model.Product{}).
+		Select("products.id AS product_id, products.name, products.stock, " + productLedger + " AS ledger_stock").
+		Where("products.stock <> " + productLedger).
+		Order("products.id").
+		Scan(&drifts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	const variantLedger = "COALESCE((SELECT SUM(delta) FROM stock_movements WHERE stock_movements.variant_id = product_variants.id), 0)"
+	var variantDrifts []StockDrift
+	err = r.db.Model(&model.ProductVariant{}).
+		Select("product_variants.product_id, product_variants.id AS variant_id, products.name, product_variants.stock, " +
+			variantLedger + " AS ledger_stock").
+		Joins("JOIN products ON products.id = product_variants.product_id").
+		Where("product_variants.stock <> " + variantLedger).
+		Order("product_variants.id").
+		Scan(&variantDrifts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	const warehouseLedger = "COALESCE((SELECT SUM(delta) FROM stock_movements WHERE stock_movements.product_id = warehouse_stocks.product_id " +
+		"AND stock_movements.variant_id = warehouse_stocks.variant_id AND stock_movements.warehouse_id = warehouse_stocks.warehouse_id), 0)"
+	var warehouseDrifts []StockDrift
+	err = r.db.Model(&model.WarehouseStock{}).
+		Select("warehouse_stocks.product_id, warehouse_stocks.variant_id, warehouse_stocks.warehouse_id, products.name, warehouse_stocks.stock, " +
+			warehouseLedger + " AS ledger_stock").
+		Joins("JOIN products ON products.id = warehouse_stocks.product_id").
+		Where("warehouse_stocks.stock <> " + warehouseLedger).
+		Order("warehouse_stocks.product_id, warehouse_stocks.variant_id, warehouse_stocks.warehouse_id").
+		Scan(&warehouseDrifts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	drifts = append(drifts, variantDrifts...)
+	return append(drifts, warehouseDrifts...), nil
+}
+
+// ResetToLedger 将现有库存修正为库存流水合计，库存在检查之后又发生变化时不做修改
+func (r *stockRepository) ResetToLedger(drift StockDrift) error {
+	query := r.db.Model(&model.Product{}).Where("id = ? AND stock = ?", drift.ProductID, drift.Stock)
+	switch {
+	case drift.WarehouseID != 0:
+		query = r.db.Model(&model.WarehouseStock{}).
+			Where("product_id = ? AND variant_id = ? AND warehouse_id = ? AND stock = ?", drift.ProductID, drift.VariantID, drift.WarehouseID, drift.Stock)
+	case drift.VariantID != 0:
+		query = r.db.Model(&model.ProductVariant{}).Where("id = ? AND stock = ?", drift.VariantID, drift.Stock)
+	}
+
+	result := query.UpdateColumn("stock", drift.LedgerStock)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("产品 %s 的库存已变化，请重新检查", drift.ProductID)
+	}
+	return nil
+}
+
+// applyStockDelta 修改产品（或规格）在仓库中的现有库存及规格、产品的库存合计，并追加一条库存流水，
+// 是修改 warehouse_stocks.stock、product_variants.stock 和 products.stock 的唯一入口。WarehouseID 为 0 时使用默认仓库。
+// 减少库存时以条件更新保证仓库的现有库存不小于保留数量，不满足时返回 ErrInsufficientStock
+func applyStockDelta(tx *gorm.DB, movement *model.StockMovement) error {
+	if movement.Delta == 0 {
+		return nil
+	}
+
+	var count int64
+	if err := tx.Model(&model.Product{}).Where("id = ?", movement.ProductID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	if movement.VariantID != 0 {
+		if _, err := productVariant(tx, movement.ProductID, movement.VariantID); err != nil {
+			return err
+		}
+	}
+
+	if movement.WarehouseID == 0 {
+		id, err := defaultWarehouseID(tx)
+		if err != nil {
+			return err
+		}
+		movement.WarehouseID = id
+	}
+
+	if movement.Delta > 0 {
+		// 产品第一次入库到该仓库时创建库存记录
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&model.WarehouseStock{WarehouseID: movement.WarehouseID, ProductID: movement.ProductID, VariantID: movement.VariantID}).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	result := tx.Model(&model.WarehouseStock{}).
+		Where("warehouse_id = ? AND product_id = ? AND variant_id = ? AND stock + ? >= reserved",
+			movement.WarehouseID, movement.ProductID, movement.VariantID, movement.Delta).
+		Update("stock", gorm.Expr("stock + ?", movement.Delta))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: 产品 %s 在仓库 %s", ErrInsufficientStock, movement.ProductID, movement.WarehouseID)
+	}
+
+	if movement.VariantID != 0 {
+		result = tx.Model(&model.ProductVariant{}).
+			Where("id = ? AND stock + ? >= reserved", movement.VariantID, movement.Delta).
+			Update("stock", gorm.Expr("stock + ?", movement.Delta))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("%w: 规格 %s", ErrInsufficientStock, movement.VariantID)
+		}
+	}
+
+	// 仓库库存满足条件时产品合计一定满足，条件更新只作为兜底
+	result = tx.Model(&model.Product{}).
+		Where("id = ? AND stock + ? >= reserved", movement.ProductID, movement.Delta).
+		Update("stock", gorm.Expr("stock + ?", movement.Delta))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: 产品 %s", ErrInsufficientStock, movement.ProductID)
+	}
+
+	return tx.Create(movement).Error
+}
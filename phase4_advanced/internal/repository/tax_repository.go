@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"errors"
+	"math/big"
+	"time"
+
+	"gin-learn/phase4/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TaxRateRepository 税率仓库接口
+type TaxRateRepository interface {
+	List() ([]model.TaxRate, error)
+	Save(rates []model.TaxRate) error
+	Delete(id model.ID[model.TaxRate]) error
+}
+
+// taxRateRepository 税率仓库实现
+type taxRateRepository struct {
+	db *gorm.DB
+}
+
+func NewTaxRateRepository(db *gorm.DB) TaxRateRepository {
+	return &taxRateRepository{db: db}
+}
+
+func (r *taxRateRepository) List() ([]model.TaxRate, error) {
+	var rates []model.TaxRate
+	if err := r.db.Order("region, tax_class").Find(&rates).Error; err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
+
+// Save 新增或更新税率，同一地区和税类只保留一条记录，全部写入在同一事务中完成
+func (r *taxRateRepository) Save(rates []model.TaxRate) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for i := range rates {
+			rates[i].UpdatedAt = time.Now()
+			err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "region"}, {Name: "tax_class"}},
+				DoUpdates: clause.AssignmentColumns([]string{"rate", "updated_at"}),
+			}).Create(&rates[i]).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *taxRateRepository) Delete(id model.ID[model.TaxRate]) error {
+	result := r.db.Delete(&model.TaxRate{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// matchTaxRate 查找适用的税率，越具体的规则优先：地区+税类、地区、税类、通用。没有匹配的规则时税率为 0
+func matchTaxRate(tx *gorm.DB, region, taxClass string) (string, error) {
+	scopes := [][2]string{{region, taxClass}, {region, ""}, {"", taxClass}, {"", ""}}
+	for _, scope := range scopes {
+		var rate model.TaxRate
+		err := tx.Where("region = ? AND tax_class = ?", scope[0], scope[1]).First(&rate).Error
+		if err == nil {
+			return rate.Rate, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", err
+		}
+	}
+	return "0", nil
+}
+
+// applyTax 按收货地区和产品税类计算每个订单项的税费，税基为扣除优惠后的金额
+func applyTax(tx *gorm.DB, region string, lines []orderLine) error {
+	for i := range lines {
+		rate, err := matchTaxRate(tx, region, lines[i].product.TaxClass)
+		if err != nil {
+			return err
+		}
+		lines[i].item.TaxRate = rate
+		lines[i].item.Tax = 0
+		if r, ok := new(big.Rat).SetString(rate); ok && r.Sign() == 0 {
+			// 零税率（免税）不需要计算
+			continue
+		}
+		tax, err := lines[i].amount().MulRate(rate)
+		if err != nil {
+			return err
+		}
+		lines[i].item.Tax = tax
+	}
+	return nil
+}
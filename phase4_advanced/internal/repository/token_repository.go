@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"time"
+
+	"gin-learn/phase4/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TokenRepository 令牌仓库接口
+type TokenRepository interface {
+	CreateRefresh(rt *model.RefreshToken) error
+	GetRefreshByHash(hash string) (*model.RefreshToken, error)
+	MarkRefreshUsed(id model.ID[model.RefreshToken]) (bool, error)
+	RevokeFamily(familyID string) error
+	IsFamilyRevoked(familyID string) (bool, error)
+	RevokeAccess(jti string, expiresAt time.Time) error
+	IsAccessRevoked(jti string) (bool, error)
+	PurgeExpired() error
+}
+
+// tokenRepository 令牌仓库实现
+type tokenRepository struct {
+	db *gorm.DB
+}
+
+func NewTokenRepository(db *gorm.DB) TokenRepository {
+	return &tokenRepository{db: db}
+}
+
+func (r *tokenRepository) CreateRefresh(rt *model.RefreshToken) error {
+	return r.db.Create(rt).Error
+}
+
+func (r *tokenRepository) GetRefreshByHash(hash string) (*model.RefreshToken, error) {
+	var rt model.RefreshToken
+	if err := r.db.Where("token_hash = ?", hash).First(&rt).Error; err != nil {
+		return nil, err
+	}
+	return &rt, nil
+}
+
+// MarkRefreshUsed 将刷新令牌标记为已使用，只有第一次调用返回 true
+func (r *tokenRepository) MarkRefreshUsed(id model.ID[model.RefreshToken]) (bool, error) {
+	result := r.db.Model(&model.RefreshToken{}).
+		Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", id).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// RevokeFamily 吊销令牌族中所有尚未吊销的刷新令牌
+func (r *tokenRepository) RevokeFamily(familyID string) error {
+	return r.db.Model(&model.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// IsFamilyRevoked 令牌族是否已被吊销。令牌族只会整体吊销，存在已吊销的刷新令牌即表示整个族已吊销
+func (r *tokenRepository) IsFamilyRevoked(familyID string) (bool, error) {
+	var count int64
+	err := r.db.Model(&model.RefreshToken{}).Where("family_id = ? AND revoked_at IS NOT NULL", familyID).Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *tokenRepository) RevokeAccess(jti string, expiresAt time.Time) error {
+	revoked := model.RevokedToken{JTI: jti, ExpiresAt: expiresAt}
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&revoked).Error
+}
+
+func (r *tokenRepository) IsAccessRevoked(jti string) (bool, error) {
+	var count int64
+	if err := r.db.Model(&model.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// PurgeExpired 清理已过期的刷新令牌和吊销记录
+func (r *tokenRepository) PurgeExpired() error {
+	now := time.Now()
+	if err := r.db.Where("expires_at < ?", now).Delete(&model.RefreshToken{}).Error; err != nil {
+		return err
+	}
+	return r.db.Where("expires_at < ?", now).Delete(&model.RevokedToken{}).Error
+}
@@ -9,11 +9,13 @@ import (
 // UserRepository 用户仓库接口
 type UserRepository interface {
 	Create(user *model.User) error
-	GetByID(id uint) (*model.User, error)
+	GetByID(id model.ID[model.User]) (*model.User, error)
 	GetByUsername(username string) (*model.User, error)
 	List(page, pageSize int, keyword string) ([]model.User, int64, error)
 	Update(user *model.User) error
-	Delete(id uint) error
+	UpdatePassword(id model.ID[model.User], password string) error
+	ListLegacyPasswords() ([]model.User, error)
+	Delete(id model.ID[model.User]) error
 }
 
 // userRepository 用户仓库实现
@@ -29,9 +31,10 @@ func (r *userRepository) Create(user *model.User) error {
 	return r.db.Create(user).Error
 }
 
-func (r *userRepository) GetByID(id uint) (*model.User, error) {
+// GetByID 查询用户及其角色和权限
+func (r *userRepository) GetByID(id model.ID[model.User]) (*model.User, error) {
 	var user model.User
-	if err := r.db.First(&user, id).Error; err != nil {
+	if err := r.db.Preload("Roles.Permissions").First(&user, id).Error; err != nil {
 		return nil, err
 	}
 	return &user, nil
@@ -67,9 +70,22 @@ func (r *userRepository) List(page, pageSize int, keyword string) ([]model.User,
 }
 
 func (r *userRepository) Update(user *model.User) error {
-	return r.db.Save(user).Error
+	return r.db.Omit("Roles").Save(user).Error
 }
 
-func (r *userRepository) Delete(id uint) error {
+func (r *userRepository) UpdatePassword(id model.ID[model.User], password string) error {
+	return r.db.Unscoped().Model(&model.User{}).Where("id = ?", id).Update("password", password).Error
+}
+
+// ListLegacyPasswords 查询密码未经 bcrypt 加密的用户（包含已删除用户）
+func (r *userRepository) ListLegacyPasswords() ([]model.User, error) {
+	var users []model.User
+	if err := r.db.Unscoped().Where("password NOT LIKE ?", "$2_$%").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *userRepository) Delete(id model.ID[model.User]) error {
 	return r.db.Delete(&model.User{}, id).Error
 }
@@ -0,0 +1,282 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gin-learn/phase4/internal/model"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrVariantRequired 有规格的产品下单、加入购物车和调整库存时必须指定规格
+	ErrVariantRequired = errors.New("该产品有多个规格，请指定规格")
+	// ErrVariantNotFound 规格不存在或不属于该产品
+	ErrVariantNotFound = errors.New("规格不存在")
+	// ErrVariantOptions 规格必须为产品的每个规格类型各选择一个已有的值
+	ErrVariantOptions = errors.New("规格组合无效")
+	// ErrVariantExists 规格组合或SKU已存在
+	ErrVariantExists = errors.New("规格组合或SKU已存在")
+	// ErrOptionsLocked 已有规格的产品不能再增加规格类型
+	ErrOptionsLocked = errors.New("产品已有规格，不能再增加规格类型")
+	// ErrProductHasStock 没有规格的产品仍有库存时不能创建规格
+	ErrProductHasStock = errors.New("产品仍有未分配到规格的库存，请先将库存调整为 0")
+)
+
+// VariantRepository 产品规格仓库接口
+type VariantRepository interface {
+	SaveOption(productID model.ID[model.Product], name string, values []string) (*model.ProductOption, error)
+	Create(variant *model.ProductVariant, options map[string]string, movement *model.StockMovement) error
+	GetByID(id model.ID[model.ProductVariant]) (*model.ProductVariant, error)
+	Update(id model.ID[model.ProductVariant], updates map[string]interface{}) error
+}
+
+// variantRepository 产品规格仓库实现
+type variantRepository struct {
+	db *gorm.DB
+}
+
+func NewVariantRepository(db *gorm.DB) VariantRepository {
+	return &variantRepository{db: db}
+}
+
+// SaveOption 新增规格类型或为已有的规格类型追加可选值，已存在的值保持不变。
+// 产品已有规格时不能新增规格类型，否则已有规格会缺少该类型的取值
+func (r *variantRepository) SaveOption(productID model.ID[model.Product], name string, values []string) (*model.ProductOption, error) {
+	var option model.ProductOption
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Select("id").First(&model.Product{}, productID).Error; err != nil {
+			return err
+		}
+
+		err := tx.Where("product_id = ? AND name = ?", productID, name).First(&option).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			var variants int64
+			if err := tx.Model(&model.ProductVariant{}).Where("product_id = ?", productID).Count(&variants).Error; err != nil {
+				return err
+			}
+			if variants > 0 {
+				return ErrOptionsLocked
+			}
+
+			var count int64
+			if err := tx.Model(&model.ProductOption{}).Where("product_id = ?", productID).Count(&count).Error; err != nil {
+				return err
+			}
+			option = model.ProductOption{ProductID: productID, Name: name, Position: int(count)}
+			err = tx.Create(&option).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		var existing []model.ProductOptionValue
+		if err := tx.Where("option_id = ?", option.ID).Find(&existing).Error; err != nil {
+			return err
+		}
+		seen := make(map[string]bool, len(existing))
+		for _, v := range existing {
+			seen[v.Value] = true
+		}
+		position := len(existing)
+		for _, value := range values {
+			if seen[value] {
+				continue
+			}
+			seen[value] = true
+			if err := tx.Create(&model.ProductOptionValue{OptionID: option.ID, Value: value, Position: position}).Error; err != nil {
+				return err
+			}
+			position++
+		}
+
+		return tx.Preload("Values", func(db *gorm.DB) *gorm.DB { return db.Order("position, id") }).First(&option, option.ID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &option, nil
+}
+
+// Create 在事务中创建规格并写入初始库存流水。options 为规格类型名称到取值的映射，
+// 必须为产品的每个规格类型各选择一个已有的值，且不能与已有规格重复。
+// 产品创建第一个规格时不能还有未分配到规格的库存
+func (r *variantRepository) Create(variant *model.ProductVariant, options map[string]string, movement *model.StockMovement) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var product model.Product
+		if err := tx.First(&product, variant.ProductID).Error; err != nil {
+			return err
+		}
+
+		var productOptions []model.ProductOption
+		if err := tx.Preload("Values").Where("product_id = ?", product.ID).Find(&productOptions).Error; err != nil {
+			return err
+		}
+		if len(productOptions) == 0 || len(options) != len(productOptions) {
+			return ErrVariantOptions
+		}
+
+		variant.Values = nil
+		for _, option := range productOptions {
+			value, ok := lookupOptionValue(option, options[option.Name])
+			if !ok {
+				return fmt.Errorf("%w: %s 没有可选值 %q", ErrVariantOptions, option.Name, options[option.Name])
+			}
+			variant.Values = append(variant.Values, value)
+		}
+
+		var existing []model.ProductVariant
+		if err := tx.Preload("Values").Where("product_id = ?", product.ID).Find(&existing).Error; err != nil {
+			return err
+		}
+		if len(existing) == 0 && (product.Stock != 0 || product.Reserved != 0) {
+			return ErrProductHasStock
+		}
+		for _, other := range existing {
+			if sameOptionValues(other.Values, variant.Values) {
+				return ErrVariantExists
+			}
+		}
+
+		var count int64
+		if err := tx.Model(&model.ProductVariant{}).Where("sku = ?", variant.SKU).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrVariantExists
+		}
+
+		stock := variant.Stock
+		variant.Stock = 0
+		if err := tx.Create(variant).Error; err != nil {
+			return err
+		}
+		variant.Stock = stock
+
+		movement.ProductID = variant.ProductID
+		movement.VariantID = variant.ID
+		movement.Delta = stock
+		return applyStockDelta(tx, movement)
+	})
+}
+
+func (r *variantRepository) GetByID(id model.ID[model.ProductVariant]) (*model.ProductVariant, error) {
+	var variant model.ProductVariant
+	if err := r.db.Preload("Values").First(&variant, id).Error; err != nil {
+		return nil, err
+	}
+	return &variant, nil
+}
+
+// Update 修改规格的SKU或价格，SKU与其他规格重复时返回 ErrVariantExists
+func (r *variantRepository) Update(id model.ID[model.ProductVariant], updates map[string]interface{}) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if sku, ok := updates["sku"]; ok {
+			var count int64
+			if err := tx.Model(&model.ProductVariant{}).Where("sku = ? AND id <> ?", sku, id).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return ErrVariantExists
+			}
+		}
+
+		result := tx.Model(&model.ProductVariant{}).Where("id = ?", id).Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// productVariant 校验下单或加入购物车时选择的规格：有规格的产品必须选择属于该产品的规格，
+// 没有规格的产品不能选择规格。没有规格时返回 nil
+func productVariant(tx *gorm.DB, productID model.ID[model.Product], variantID model.ID[model.ProductVariant]) (*model.ProductVariant, error) {
+	if variantID == 0 {
+		var count int64
+		if err := tx.Model(&model.ProductVariant{}).Where("product_id = ?", productID).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			return nil, ErrVariantRequired
+		}
+		return nil, nil
+	}
+
+	var variant model.ProductVariant
+	err := tx.Preload("Values").Where("id = ? AND product_id = ?", variantID, productID).First(&variant).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrVariantNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &variant, nil
+}
+
+// variantName 规格描述，如 "尺码: M / 颜色: 红"，按规格类型的显示顺序排列
+func variantName(tx *gorm.DB, variant *model.ProductVariant) (string, error) {
+	var options []model.ProductOption
+	if err := tx.Where("product_id = ?", variant.ProductID).Order("position, id").Find(&options).Error; err != nil {
+		return "", err
+	}
+
+	parts := make([]string, 0, len(options))
+	for _, option := range options {
+		for _, value := range variant.Values {
+			if value.OptionID == option.ID {
+				parts = append(parts, option.Name+": "+value.Value)
+			}
+		}
+	}
+	return strings.Join(parts, " / "), nil
+}
+
+// filterByOptions 只保留至少有一个规格同时匹配全部规格值的产品，options 为规格类型名称到取值的映射
+func filterByOptions(db, query *gorm.DB, options map[string]string) *gorm.DB {
+	if len(options) == 0 {
+		return query
+	}
+
+	variants := db.Model(&model.ProductVariant{}).Select("product_variants.product_id")
+	for name, value := range options {
+		matched := db.Table("product_variant_values").Select("product_variant_values.product_variant_id").
+			Joins("JOIN product_option_values ON product_option_values.id = product_variant_values.product_option_value_id").
+			Joins("JOIN product_options ON product_options.id = product_option_values.option_id").
+			Where("product_options.name = ? AND product_option_values.value = ?", name, value)
+		variants = variants.Where("product_variants.id IN (?)", matched)
+	}
+	return query.Where("products.id IN (?)", variants)
+}
+
+// lookupOptionValue 在规格类型中查找取值
+func lookupOptionValue(option model.ProductOption, value string) (model.ProductOptionValue, bool) {
+	for _, v := range option.Values {
+		if v.Value == value {
+			return v, true
+		}
+	}
+	return model.ProductOptionValue{}, false
+}
+
+// sameOptionValues 判断两个规格的取值是否相同
+func sameOptionValues(a, b []model.ProductOptionValue) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	ids := make(map[model.ID[model.ProductOptionValue]]bool, len(a))
+	for _, v := range a {
+		ids[v.ID] = true
+	}
+	for _, v := range b {
+		if !ids[v.ID] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,266 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gin-learn/phase4/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// ErrSameWarehouse 调出仓库与调入仓库相同
+var ErrSameWarehouse = errors.New("调出仓库与调入仓库不能相同")
+
+// WarehouseRepository 仓库仓库接口
+type WarehouseRepository interface {
+	Create(warehouse *model.Warehouse) error
+	GetByID(id model.ID[model.Warehouse]) (*model.Warehouse, error)
+	GetByCode(code string) (*model.Warehouse, error)
+	List() ([]model.Warehouse, error)
+	Update(warehouse *model.Warehouse) error
+	ListStocks(warehouseID model.ID[model.Warehouse], page, pageSize int) ([]model.WarehouseStock, int64, error)
+	Transfer(transfer *model.StockTransfer) error
+}
+
+// warehouseRepository 仓库仓库实现
+type warehouseRepository struct {
+	db *gorm.DB
+}
+
+func NewWarehouseRepository(db *gorm.DB) WarehouseRepository {
+	return &warehouseRepository{db: db}
+}
+
+func (r *warehouseRepository) Create(warehouse *model.Warehouse) error {
+	return r.db.Create(warehouse).Error
+}
+
+func (r *warehouseRepository) GetByID(id model.ID[model.Warehouse]) (*model.Warehouse, error) {
+	var warehouse model.Warehouse
+	if err := r.db.First(&warehouse, id).Error; err != nil {
+		return nil, err
+	}
+	return &warehouse, nil
+}
+
+func (r *warehouseRepository) GetByCode(code string) (*model.Warehouse, error) {
+	var warehouse model.Warehouse
+	if err := r.db.Where("code = ?", code).First(&warehouse).Error; err != nil {
+		return nil, err
+	}
+	return &warehouse, nil
+}
+
+// List 按分配顺序返回全部仓库
+func (r *warehouseRepository) List() ([]model.Warehouse, error) {
+	var warehouses []model.Warehouse
+	if err := r.db.Order("priority, id").Find(&warehouses).Error; err != nil {
+		return nil, err
+	}
+	return warehouses, nil
+}
+
+// Update 保存仓库信息，默认仓库标记不能修改
+func (r *warehouseRepository) Update(warehouse *model.Warehouse) error {
+	result := r.db.Model(warehouse).Select("code", "name", "priority").Updates(warehouse)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// ListStocks 分页获取仓库中各产品的库存
+func (r *warehouseRepository) ListStocks(warehouseID model.ID[model.Warehouse], page, pageSize int) ([]model.WarehouseStock, int64, error) {
+	query := r.db.Model(&model.WarehouseStock{}).Where("warehouse_id = ?", warehouseID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var stocks []model.WarehouseStock
+	offset := (page - 1) * pageSize
+	if err := query.Order("product_id").Offset(offset).Limit(pageSize).Find(&stocks).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return stocks, total, nil
+}
+
+// Transfer 在事务中保存调拨记录，并从调出仓库扣减、向调入仓库增加库存，
+// 调出仓库的可售库存不足时返回 ErrInsufficientStock
+func (r *warehouseRepository) Transfer(transfer *model.StockTransfer) error {
+	if transfer.FromWarehouseID == transfer.ToWarehouseID {
+		return ErrSameWarehouse
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if _, err := productVariant(tx, transfer.ProductID, transfer.VariantID); err != nil {
+			return err
+		}
+		if err := tx.Create(transfer).Error; err != nil {
+			return err
+		}
+
+		movements := []model.StockMovement{
+			{WarehouseID: transfer.FromWarehouseID, Delta: -transfer.Quantity},
+			{WarehouseID: transfer.ToWarehouseID, Delta: transfer.Quantity},
+		}
+		for i := range movements {
+			movements[i].ProductID = transfer.ProductID
+			movements[i].VariantID = transfer.VariantID
+			movements[i].Reason = model.StockReasonTransfer
+			movements[i].ReferenceID = uint(transfer.ID)
+			movements[i].ActorID = transfer.ActorID
+			movements[i].Note = transfer.Note
+			if err := applyStockDelta(tx, &movements[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// seedWarehouse 确保存在默认仓库
+func seedWarehouse(db *gorm.DB) error {
+	_, err := defaultWarehouseID(db)
+	return err
+}
+
+// defaultWarehouseID 返回默认仓库ID，不存在时创建
+func defaultWarehouseID(tx *gorm.DB) (model.ID[model.Warehouse], error) {
+	var warehouse model.Warehouse
+	err := tx.Where("is_default = ?", true).First(&warehouse).Error
+	if err == nil {
+		return warehouse.ID, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, err
+	}
+
+	warehouse = model.Warehouse{Code: "MAIN", Name: "默认仓库", IsDefault: true}
+	if err := tx.Create(&warehouse).Error; err != nil {
+		return 0, err
+	}
+	return warehouse.ID, nil
+}
+
+// allocateStock 为订单项分配发货仓库并保留库存，分配方式见 planStock
+func allocateStock(tx *gorm.DB, product *model.Product, item *model.OrderItem, expiresAt *time.Time) error {
+	allocations, err := planStock(tx, product, item)
+	if err != nil {
+		return err
+	}
+
+	for _, allocation := range allocations {
+		allocation.OrderItemID = item.ID
+		if err := tx.Create(&allocation).Error; err != nil {
+			return err
+		}
+
+		// 判断与保留在同一条 UPDATE 中完成，并发下不会超卖
+		result := tx.Model(&model.WarehouseStock{}).
+			Where("warehouse_id = ? AND product_id = ? AND variant_id = ? AND stock - reserved >= ?",
+				allocation.WarehouseID, product.ID, item.VariantID, allocation.Quantity).
+			Update("reserved", gorm.Expr("reserved + ?", allocation.Quantity))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("%w: %s", ErrInsufficientStock, product.Name)
+		}
+		if item.VariantID != 0 {
+			err := tx.Model(&model.ProductVariant{}).Where("id = ?", item.VariantID).
+				Update("reserved", gorm.Expr("reserved + ?", allocation.Quantity)).Error
+			if err != nil {
+				return err
+			}
+		}
+		err := tx.Model(&model.Product{}).Where("id = ?", product.ID).
+			Update("reserved", gorm.Expr("reserved + ?", allocation.Quantity)).Error
+		if err != nil {
+			return err
+		}
+
+		reservation := model.StockReservation{
+			OrderID:     item.OrderID,
+			OrderItemID: item.ID,
+			ProductID:   product.ID,
+			VariantID:   item.VariantID,
+			WarehouseID: allocation.WarehouseID,
+			Quantity:    allocation.Quantity,
+			Status:      model.ReservationActive,
+			ExpiresAt:   expiresAt,
+		}
+		if err := tx.Create(&reservation).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// planStock 计算订单项在各仓库的分配数量但不保留库存：优先选择能单独满足全部数量的仓库，
+// 没有时按仓库优先级依次拆分到多个仓库。各仓库可售库存合计不足时返回 ErrInsufficientStock
+func planStock(tx *gorm.DB, product *model.Product, item *model.OrderItem) ([]model.OrderItemAllocation, error) {
+	var stocks []model.WarehouseStock
+	err := tx.Model(&model.WarehouseStock{}).
+		Joins("JOIN warehouses ON warehouses.id = warehouse_stocks.warehouse_id").
+		Where("warehouse_stocks.product_id = ? AND warehouse_stocks.variant_id = ? AND warehouse_stocks.stock > warehouse_stocks.reserved",
+			product.ID, item.VariantID).
+		Order("warehouses.priority, warehouses.id").
+		Find(&stocks).Error
+	if err != nil {
+		return nil, err
+	}
+
+	allocations := planAllocations(stocks, item.Quantity)
+	if allocations == nil {
+		return nil, fmt.Errorf("%w: %s", ErrInsufficientStock, product.Name)
+	}
+	return allocations, nil
+}
+
+// planAllocations 按分配策略计算各仓库的发货数量，stocks 需按仓库优先级排序，库存不足时返回 nil
+func planAllocations(stocks []model.WarehouseStock, quantity int) []model.OrderItemAllocation {
+	for _, s := range stocks {
+		if s.Stock-s.Reserved >= quantity {
+			return []model.OrderItemAllocation{{WarehouseID: s.WarehouseID, Quantity: quantity}}
+		}
+	}
+
+	var allocations []model.OrderItemAllocation
+	remaining := quantity
+	for _, s := range stocks {
+		n := min(s.Stock-s.Reserved, remaining)
+		allocations = append(allocations, model.OrderItemAllocation{WarehouseID: s.WarehouseID, Quantity: n})
+		remaining -= n
+		if remaining == 0 {
+			return allocations
+		}
+	}
+	return nil
+}
+
+// releaseReserved 减少仓库、规格和产品的保留数量
+func releaseReserved(tx *gorm.DB, reservation *model.StockReservation) error {
+	err := tx.Model(&model.WarehouseStock{}).
+		Where("warehouse_id = ? AND product_id = ? AND variant_id = ?", reservation.WarehouseID, reservation.ProductID, reservation.VariantID).
+		Update("reserved", gorm.Expr("reserved - ?", reservation.Quantity)).Error
+	if err != nil {
+		return err
+	}
+	if reservation.VariantID != 0 {
+		err := tx.Model(&model.ProductVariant{}).Where("id = ?", reservation.VariantID).
+			Update("reserved", gorm.Expr("reserved - ?", reservation.Quantity)).Error
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Model(&model.Product{}).Where("id = ?", reservation.ProductID).
+		Update("reserved", gorm.Expr("reserved - ?", reservation.Quantity)).Error
+}
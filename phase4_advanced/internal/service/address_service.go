@@ -0,0 +1,84 @@
+package service
+
+import (
+	"errors"
+
+	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/internal/repository"
+)
+
+var ErrAddressNotFound = errors.New("收货地址不存在")
+
+// AddressService 收货地址服务接口
+type AddressService interface {
+	CreateAddress(userID model.ID[model.User], info model.AddressInfo, isDefault bool) (*model.Address, error)
+	GetAddress(userID model.ID[model.User], id model.ID[model.Address]) (*model.Address, error)
+	ListAddresses(userID model.ID[model.User]) ([]model.Address, error)
+	UpdateAddress(userID model.ID[model.User], id model.ID[model.Address], info model.AddressInfo) (*model.Address, error)
+	DeleteAddress(userID model.ID[model.User], id model.ID[model.Address]) error
+	SetDefault(userID model.ID[model.User], id model.ID[model.Address]) error
+}
+
+// addressService 收货地址服务实现
+type addressService struct {
+	repo repository.AddressRepository
+}
+
+func NewAddressService(repo repository.AddressRepository) AddressService {
+	return &addressService{repo: repo}
+}
+
+func (s *addressService) CreateAddress(userID model.ID[model.User], info model.AddressInfo, isDefault bool) (*model.Address, error) {
+	address := &model.Address{
+		UserID:      userID,
+		AddressInfo: info,
+		IsDefault:   isDefault,
+	}
+
+	if err := s.repo.Create(address); err != nil {
+		return nil, err
+	}
+
+	return address, nil
+}
+
+func (s *addressService) GetAddress(userID model.ID[model.User], id model.ID[model.Address]) (*model.Address, error) {
+	address, err := s.repo.GetByID(userID, id)
+	if err != nil {
+		return nil, ErrAddressNotFound
+	}
+	return address, nil
+}
+
+func (s *addressService) ListAddresses(userID model.ID[model.User]) ([]model.Address, error) {
+	return s.repo.List(userID)
+}
+
+// UpdateAddress 修改地址内容，已创建订单上的地址快照不受影响
+func (s *addressService) UpdateAddress(userID model.ID[model.User], id model.ID[model.Address], info model.AddressInfo) (*model.Address, error) {
+	address, err := s.GetAddress(userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	address.AddressInfo = info
+	if err := s.repo.Update(address); err != nil {
+		return nil, err
+	}
+
+	return address, nil
+}
+
+func (s *addressService) DeleteAddress(userID model.ID[model.User], id model.ID[model.Address]) error {
+	if _, err := s.GetAddress(userID, id); err != nil {
+		return err
+	}
+	return s.repo.Delete(userID, id)
+}
+
+func (s *addressService) SetDefault(userID model.ID[model.User], id model.ID[model.Address]) error {
+	if _, err := s.GetAddress(userID, id); err != nil {
+		return err
+	}
+	return s.repo.SetDefault(userID, id)
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/internal/repository"
+	"gin-learn/phase4/pkg/logger"
+	"gin-learn/phase4/pkg/token"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("用户名或密码错误")
+	ErrUserDisabled       = errors.New("用户已被禁用")
+	ErrTokenRevoked       = errors.New("Token已被吊销")
+	ErrTokenReused        = errors.New("Refresh Token 被重复使用，已吊销该登录会话")
+)
+
+// LoginResult 登录结果
+type LoginResult struct {
+	User *model.User `json:"user"`
+	*token.Pair
+}
+
+// AuthService 认证服务接口
+type AuthService interface {
+	Login(username, password string) (*LoginResult, error)
+	Refresh(refreshToken string) (*token.Pair, error)
+	Logout(claims *token.Claims) error
+	Authenticate(accessToken string) (*model.User, *token.Claims, error)
+}
+
+// authService 认证服务实现
+type authService struct {
+	users     UserService
+	userRepo  repository.UserRepository
+	tokenRepo repository.TokenRepository
+}
+
+func NewAuthService(users UserService, userRepo repository.UserRepository, tokenRepo repository.TokenRepository) AuthService {
+	return &authService{users: users, userRepo: userRepo, tokenRepo: tokenRepo}
+}
+
+func (s *authService) Login(username, password string) (*LoginResult, error) {
+	user, err := s.users.Login(username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Status != 1 {
+		return nil, ErrUserDisabled
+	}
+
+	// 每次登录开启一个新的令牌族
+	pair, err := s.issue(user, token.NewID())
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoginResult{User: user, Pair: pair}, nil
+}
+
+// Refresh 轮换刷新令牌：旧令牌只能使用一次，重复使用会吊销整个令牌族
+func (s *authService) Refresh(refreshToken string) (*token.Pair, error) {
+	rt, err := s.tokenRepo.GetRefreshByHash(token.Hash(refreshToken))
+	if err != nil {
+		return nil, token.ErrInvalidToken
+	}
+
+	if rt.RevokedAt != nil {
+		return nil, ErrTokenRevoked
+	}
+
+	if rt.UsedAt != nil {
+		return nil, s.reuseDetected(rt)
+	}
+
+	if time.Now().After(rt.ExpiresAt) {
+		return nil, token.ErrInvalidToken
+	}
+
+	ok, err := s.tokenRepo.MarkRefreshUsed(rt.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		// 并发请求已抢先使用了该令牌
+		return nil, s.reuseDetected(rt)
+	}
+
+	user, err := s.activeUser(rt.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.issue(user, rt.FamilyID)
+}
+
+// Logout 吊销当前 Access Token 及其所属的整个令牌族
+func (s *authService) Logout(claims *token.Claims) error {
+	if err := s.tokenRepo.RevokeAccess(claims.ID, claims.ExpiresAt.Time); err != nil {
+		return err
+	}
+
+	return s.tokenRepo.RevokeFamily(claims.FamilyID)
+}
+
+func (s *authService) Authenticate(accessToken string) (*model.User, *token.Claims, error) {
+	claims, err := token.Parse(accessToken)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	revoked, err := s.tokenRepo.IsAccessRevoked(claims.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if revoked {
+		return nil, nil, ErrTokenRevoked
+	}
+
+	// 令牌族被吊销（退出登录或检测到刷新令牌重用）后，该族签发的所有 Access Token 立即失效
+	revoked, err = s.tokenRepo.IsFamilyRevoked(claims.FamilyID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if revoked {
+		return nil, nil, ErrTokenRevoked
+	}
+
+	user, err := s.activeUser(model.ID[model.User](claims.UserID))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return user, claims, nil
+}
+
+// issue 签发 Access Token 和新的 Refresh Token，Refresh Token 只保存哈希
+func (s *authService) issue(user *model.User, familyID string) (*token.Pair, error) {
+	accessToken, err := token.GenerateAccess(uint(user.ID), user.Username, familyID)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, hash, err := token.NewRefresh()
+	if err != nil {
+		return nil, err
+	}
+
+	rt := &model.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(token.RefreshExpire()),
+	}
+	if err := s.tokenRepo.CreateRefresh(rt); err != nil {
+		return nil, err
+	}
+
+	return &token.Pair{
+		AccessToken:  accessToken,
+		RefreshToken: raw,
+		ExpiresIn:    int64(token.AccessExpire() / time.Second),
+	}, nil
+}
+
+// reuseDetected 已使用的刷新令牌再次出现，说明令牌可能泄露，吊销整个令牌族
+func (s *authService) reuseDetected(rt *model.RefreshToken) error {
+	logger.Warn("Refresh token reuse detected",
+		logger.Int("user_id", int(rt.UserID)),
+		logger.String("family_id", rt.FamilyID),
+	)
+
+	if err := s.tokenRepo.RevokeFamily(rt.FamilyID); err != nil {
+		return err
+	}
+	return ErrTokenReused
+}
+
+// activeUser 加载用户并确认其未被删除或禁用
+func (s *authService) activeUser(id model.ID[model.User]) (*model.User, error) {
+	user, err := s.userRepo.GetByID(id)
+	if err != nil {
+		return nil, token.ErrInvalidToken
+	}
+
+	if user.Status != 1 {
+		return nil, ErrUserDisabled
+	}
+
+	return user, nil
+}
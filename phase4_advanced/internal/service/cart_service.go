@@ -0,0 +1,231 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/internal/repository"
+	"gin-learn/phase4/pkg/money"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCartItemNotFound = errors.New("购物车商品不存在")
+	ErrProductNotFound  = errors.New("产品不存在")
+	ErrStockNotEnough   = errors.New("库存不足")
+)
+
+// CartLine 购物车行，包含读取时重新校验的价格和库存
+// Price 和 CurrentPrice 为产品基础货币，Subtotal 为购物车货币
+type CartLine struct {
+	model.CartItem
+	CurrentPrice money.Amount `json:"current_price"`
+	PriceChanged bool         `json:"price_changed"`
+	Subtotal     money.Amount `json:"subtotal"`
+	Available    bool         `json:"available"`
+	Message      string       `json:"message,omitempty"`
+}
+
+// Cart 购物车，Total 只统计可购买的商品
+type Cart struct {
+	Items    []CartLine   `json:"items"`
+	Currency string       `json:"currency"`
+	Total    money.Amount `json:"total"`
+}
+
+// CartService 购物车服务接口
+type CartService interface {
+	GetCart(userID model.ID[model.User], currency string) (*Cart, error)
+	AddItem(userID model.ID[model.User], productID model.ID[model.Product], variantID model.ID[model.ProductVariant], quantity int) error
+	UpdateQuantity(userID model.ID[model.User], itemID model.ID[model.CartItem], quantity int) error
+	RemoveItem(userID model.ID[model.User], itemID model.ID[model.CartItem]) error
+	Clear(userID model.ID[model.User]) error
+	Checkout(userID model.ID[model.User], addressID model.ID[model.Address], currency, couponCode string, itemIDs []model.ID[model.CartItem]) (*model.Order, error)
+}
+
+// cartService 购物车服务实现
+type cartService struct {
+	repo           *repository.Repository
+	reservationTTL time.Duration
+	alerts         StockAlertService
+}
+
+// NewCartService 创建购物车服务，结算需要在同一事务中操作购物车和订单，因此依赖完整的仓库。
+// reservationTTL 为结算时保留库存的时间
+func NewCartService(repo *repository.Repository, reservationTTL time.Duration, alerts StockAlertService) CartService {
+	return &cartService{repo: repo, reservationTTL: reservationTTL, alerts: alerts}
+}
+
+// GetCart 读取购物车，按当前产品（或规格）价格和库存重新校验每一行，并按当前汇率换算为 currency
+func (s *cartService) GetCart(userID model.ID[model.User], currency string) (*Cart, error) {
+	if currency == "" {
+		currency = model.DefaultCurrency
+	}
+	if !money.ValidCurrency(currency) {
+		return nil, ErrInvalidCurrency
+	}
+
+	items, err := s.repo.Cart.List(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	cart := &Cart{Items: make([]CartLine, 0, len(items)), Currency: currency}
+	for _, item := range items {
+		line := CartLine{CartItem: item}
+		price, available := item.Product.Price, item.Product.Available
+		if item.Variant != nil {
+			price, available = item.Variant.UnitPrice(&item.Product), item.Variant.Available
+		}
+		switch {
+		case item.Product.ID == 0:
+			line.Message = "产品已下架"
+		case item.VariantID != 0 && item.Variant == nil:
+			line.Message = "规格已下架"
+		case available < item.Quantity:
+			line.CurrentPrice = price
+			line.Message = fmt.Sprintf("库存不足，当前可售库存 %d", available)
+		default:
+			line.CurrentPrice = price
+			line.Available = true
+		}
+
+		if line.CurrentPrice != 0 && line.CurrentPrice != item.Price {
+			line.PriceChanged = true
+			if line.Message == "" {
+				line.Message = fmt.Sprintf("价格已由 %s 变为 %s", item.Price, line.CurrentPrice)
+			}
+		}
+
+		if line.Available {
+			if err := s.convertLine(&line, currency); err != nil {
+				return nil, err
+			}
+		}
+		if line.Available {
+			cart.Total += line.Subtotal
+		}
+		cart.Items = append(cart.Items, line)
+	}
+
+	return cart, nil
+}
+
+// convertLine 按当前汇率计算购物车行小计，缺少汇率的商品标记为不可购买
+func (s *cartService) convertLine(line *CartLine, currency string) error {
+	rate, err := s.repo.ExchangeRate.Rate(line.Product.Currency, currency)
+	if errors.Is(err, repository.ErrRateNotFound) {
+		line.Available = false
+		line.Message = err.Error()
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	price, err := line.CurrentPrice.MulRate(rate)
+	if err != nil {
+		return err
+	}
+	line.Subtotal = price.Mul(line.Quantity)
+	return nil
+}
+
+// AddItem 加入购物车，已存在的商品累加数量，累加后不能超过可售库存。有规格的产品必须指定规格，价格和库存以规格为准
+func (s *cartService) AddItem(userID model.ID[model.User], productID model.ID[model.Product], variantID model.ID[model.ProductVariant], quantity int) error {
+	product, err := s.repo.Product.GetByID(productID)
+	if err != nil {
+		return ErrProductNotFound
+	}
+
+	variant, err := selectVariant(product, variantID)
+	if err != nil {
+		return err
+	}
+	price, available := product.Price, product.Available
+	if variant != nil {
+		price, available = variant.UnitPrice(product), variant.Available
+	}
+
+	if quantity > available {
+		return ErrStockNotEnough
+	}
+
+	ok, err := s.repo.Cart.AddItem(&model.CartItem{
+		UserID:    userID,
+		ProductID: productID,
+		VariantID: variantID,
+		Quantity:  quantity,
+		Price:     price,
+	}, available)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrStockNotEnough
+	}
+	return nil
+}
+
+// UpdateQuantity 修改购物车商品数量
+func (s *cartService) UpdateQuantity(userID model.ID[model.User], itemID model.ID[model.CartItem], quantity int) error {
+	err := s.repo.Cart.UpdateQuantity(userID, itemID, quantity)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrCartItemNotFound
+	}
+	return err
+}
+
+func (s *cartService) RemoveItem(userID model.ID[model.User], itemID model.ID[model.CartItem]) error {
+	return s.repo.Cart.Remove(userID, []model.ID[model.CartItem]{itemID})
+}
+
+func (s *cartService) Clear(userID model.ID[model.User]) error {
+	return s.repo.Cart.Clear(userID)
+}
+
+// Checkout 将选中的购物车商品下单，创建订单和移除购物车商品在同一事务中完成
+func (s *cartService) Checkout(userID model.ID[model.User], addressID model.ID[model.Address], currency, couponCode string, itemIDs []model.ID[model.CartItem]) (*model.Order, error) {
+	if currency != "" && !money.ValidCurrency(currency) {
+		return nil, ErrInvalidCurrency
+	}
+
+	var order *model.Order
+	err := s.repo.Transaction(func(tx *repository.Repository) error {
+		items, err := tx.Cart.GetByIDs(userID, itemIDs)
+		if err != nil {
+			return err
+		}
+		if len(items) == 0 || len(items) != len(unique(itemIDs)) {
+			return ErrCartItemNotFound
+		}
+
+		inputs := make([]repository.OrderItemInput, len(items))
+		for i, item := range items {
+			inputs[i] = repository.OrderItemInput{ProductID: item.ProductID, VariantID: item.VariantID, Quantity: item.Quantity}
+		}
+
+		order, err = tx.Order.CreateOrder(repository.CreateOrderInput{
+			UserID:         userID,
+			AddressID:      addressID,
+			Currency:       currency,
+			CouponCode:     NormalizeCouponCode(couponCode),
+			Items:          inputs,
+			ReservationTTL: s.reservationTTL,
+		})
+		if err != nil {
+			return err
+		}
+
+		return tx.Cart.Remove(userID, itemIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.alerts.Check(orderProductIDs(order)...)
+	return order, nil
+}
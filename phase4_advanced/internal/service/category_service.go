@@ -8,7 +8,7 @@ import (
 // CategoryService 分类服务接口
 type CategoryService interface {
 	CreateCategory(name, description string) (*model.Category, error)
-	GetCategory(id uint) (*model.Category, error)
+	GetCategory(id model.ID[model.Category]) (*model.Category, error)
 	ListCategories() ([]model.Category, error)
 }
 
@@ -34,7 +34,7 @@ func (s *categoryService) CreateCategory(name, description string) (*model.Categ
 	return category, nil
 }
 
-func (s *categoryService) GetCategory(id uint) (*model.Category, error) {
+func (s *categoryService) GetCategory(id model.ID[model.Category]) (*model.Category, error) {
 	return s.repo.GetByID(id)
 }
 
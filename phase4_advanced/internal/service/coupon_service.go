@@ -0,0 +1,130 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/internal/repository"
+	"gin-learn/phase4/pkg/money"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCouponNotFound = errors.New("优惠券不存在")
+	ErrCouponExists   = errors.New("优惠券码已存在")
+	ErrInvalidCoupon  = errors.New("无效的优惠券")
+)
+
+// CouponService 优惠券服务接口
+type CouponService interface {
+	CreateCoupon(coupon model.Coupon) (*model.Coupon, error)
+	GetCoupon(id model.ID[model.Coupon]) (*model.Coupon, error)
+	ListCoupons() ([]model.Coupon, error)
+	UpdateCoupon(id model.ID[model.Coupon], coupon model.Coupon) (*model.Coupon, error)
+	DeleteCoupon(id model.ID[model.Coupon]) error
+}
+
+// couponService 优惠券服务实现
+type couponService struct {
+	repo repository.CouponRepository
+}
+
+func NewCouponService(repo repository.CouponRepository) CouponService {
+	return &couponService{repo: repo}
+}
+
+func (s *couponService) CreateCoupon(coupon model.Coupon) (*model.Coupon, error) {
+	if err := s.validate(0, &coupon); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Create(&coupon); err != nil {
+		return nil, err
+	}
+	return &coupon, nil
+}
+
+func (s *couponService) GetCoupon(id model.ID[model.Coupon]) (*model.Coupon, error) {
+	coupon, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, ErrCouponNotFound
+	}
+	return coupon, nil
+}
+
+func (s *couponService) ListCoupons() ([]model.Coupon, error) {
+	return s.repo.List()
+}
+
+// UpdateCoupon 修改优惠券规则，只影响之后的订单
+func (s *couponService) UpdateCoupon(id model.ID[model.Coupon], coupon model.Coupon) (*model.Coupon, error) {
+	if _, err := s.GetCoupon(id); err != nil {
+		return nil, err
+	}
+	if err := s.validate(id, &coupon); err != nil {
+		return nil, err
+	}
+
+	coupon.ID = id
+	if err := s.repo.Update(&coupon); err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(id)
+}
+
+func (s *couponService) DeleteCoupon(id model.ID[model.Coupon]) error {
+	err := s.repo.Delete(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrCouponNotFound
+	}
+	return err
+}
+
+// validate 规范化优惠券码和货币并校验规则，id 为正在修改的优惠券
+func (s *couponService) validate(id model.ID[model.Coupon], coupon *model.Coupon) error {
+	coupon.Code = NormalizeCouponCode(coupon.Code)
+	if coupon.Currency == "" {
+		coupon.Currency = model.DefaultCurrency
+	}
+	if !money.ValidCurrency(coupon.Currency) {
+		return ErrInvalidCurrency
+	}
+
+	switch coupon.Type {
+	case model.CouponTypePercent:
+		if coupon.Percent < 1 || coupon.Percent > 100 {
+			return fmt.Errorf("%w: 折扣百分比必须在 1-100 之间", ErrInvalidCoupon)
+		}
+	case model.CouponTypeFixed:
+		if coupon.Amount <= 0 {
+			return fmt.Errorf("%w: 减免金额必须大于 0", ErrInvalidCoupon)
+		}
+	case model.CouponTypeFreeShipping:
+	default:
+		return fmt.Errorf("%w: 未知的优惠券类型 %q", ErrInvalidCoupon, coupon.Type)
+	}
+
+	if coupon.MinSpend < 0 || coupon.UsageLimit < 0 || coupon.PerUserLimit < 0 {
+		return fmt.Errorf("%w: 最低消费和使用次数上限不能为负数", ErrInvalidCoupon)
+	}
+	if coupon.StartsAt != nil && coupon.EndsAt != nil && !coupon.EndsAt.After(*coupon.StartsAt) {
+		return fmt.Errorf("%w: 结束时间必须晚于开始时间", ErrInvalidCoupon)
+	}
+
+	existing, err := s.repo.GetByCode(coupon.Code)
+	if err == nil && existing.ID != id {
+		return ErrCouponExists
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	return nil
+}
+
+// NormalizeCouponCode 优惠券码不区分大小写，统一保存为大写
+func NormalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/internal/repository"
+	"gin-learn/phase4/pkg/money"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidCurrency = errors.New("无效的货币代码")
+	ErrRateNotFound    = errors.New("汇率不存在")
+)
+
+// CurrencyService 货币和汇率服务接口
+type CurrencyService interface {
+	ListRates() ([]model.ExchangeRate, error)
+	SetRate(base, quote, rate string) (*model.ExchangeRate, error)
+	DeleteRate(id model.ID[model.ExchangeRate]) error
+	ImportCSV(r io.Reader) (int, error)
+	LocalizeProducts(products []model.Product, currency string) error
+}
+
+// currencyService 货币和汇率服务实现
+type currencyService struct {
+	repo repository.ExchangeRateRepository
+}
+
+func NewCurrencyService(repo repository.ExchangeRateRepository) CurrencyService {
+	return &currencyService{repo: repo}
+}
+
+func (s *currencyService) ListRates() ([]model.ExchangeRate, error) {
+	return s.repo.List()
+}
+
+// SetRate 新增或更新汇率，1 base = rate quote
+func (s *currencyService) SetRate(base, quote, rate string) (*model.ExchangeRate, error) {
+	r, err := newExchangeRate(base, quote, rate)
+	if err != nil {
+		return nil, err
+	}
+
+	rates := []model.ExchangeRate{*r}
+	if err := s.repo.Save(rates); err != nil {
+		return nil, err
+	}
+	return &rates[0], nil
+}
+
+func (s *currencyService) DeleteRate(id model.ID[model.ExchangeRate]) error {
+	err := s.repo.Delete(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrRateNotFound
+	}
+	return err
+}
+
+// ImportCSV 从 CSV 批量导入汇率，每行为 base,quote,rate，可以包含表头。
+// 所有行校验通过后在同一事务中写入，任一行错误则全部不导入
+func (s *currencyService) ImportCSV(r io.Reader) (int, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 3
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return 0, err
+	}
+
+	rates := make([]model.ExchangeRate, 0, len(records))
+	for i, record := range records {
+		if i == 0 && strings.EqualFold(record[0], "base") {
+			continue
+		}
+
+		rate, err := newExchangeRate(record[0], record[1], record[2])
+		if err != nil {
+			return 0, fmt.Errorf("第 %d 行: %w", i+1, err)
+		}
+		rates = append(rates, *rate)
+	}
+
+	if err := s.repo.Save(rates); err != nil {
+		return 0, err
+	}
+	return len(rates), nil
+}
+
+// LocalizeProducts 将产品价格按当前汇率换算为指定货币，缺少汇率时返回 repository.ErrRateNotFound
+func (s *currencyService) LocalizeProducts(products []model.Product, currency string) error {
+	if !money.ValidCurrency(currency) {
+		return ErrInvalidCurrency
+	}
+
+	rates := make(map[string]string)
+	for i := range products {
+		p := &products[i]
+		rate, ok := rates[p.Currency]
+		if !ok {
+			var err error
+			if rate, err = s.repo.Rate(p.Currency, currency); err != nil {
+				return err
+			}
+			rates[p.Currency] = rate
+		}
+
+		price, err := p.Price.MulRate(rate)
+		if err != nil {
+			return err
+		}
+		p.Price = price
+		p.Currency = currency
+
+		// 单独定价的规格一并换算，未定价的规格沿用产品价格
+		for j := range p.Variants {
+			if p.Variants[j].Price == 0 {
+				continue
+			}
+			if p.Variants[j].Price, err = p.Variants[j].Price.MulRate(rate); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// newExchangeRate 校验并创建汇率记录
+func newExchangeRate(base, quote, rate string) (*model.ExchangeRate, error) {
+	base = strings.ToUpper(strings.TrimSpace(base))
+	quote = strings.ToUpper(strings.TrimSpace(quote))
+	if !money.ValidCurrency(base) || !money.ValidCurrency(quote) {
+		return nil, fmt.Errorf("%w: %s/%s", ErrInvalidCurrency, base, quote)
+	}
+	if base == quote {
+		return nil, fmt.Errorf("%w: 基础货币和报价货币不能相同", ErrInvalidCurrency)
+	}
+
+	rate = strings.TrimSpace(rate)
+	if _, err := money.ParseRate(rate); err != nil {
+		return nil, err
+	}
+
+	return &model.ExchangeRate{Base: base, Quote: quote, Rate: rate}, nil
+}
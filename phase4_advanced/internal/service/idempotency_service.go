@@ -0,0 +1,71 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/internal/repository"
+)
+
+var (
+	ErrIdempotencyInProgress = errors.New("相同 Idempotency-Key 的请求正在处理中")
+	ErrIdempotencyMismatch   = errors.New("Idempotency-Key 已用于不同的请求内容")
+)
+
+// IdempotencyService 幂等请求服务接口
+type IdempotencyService interface {
+	Begin(key string, userID model.ID[model.User], method, path, requestHash string) (record *model.IdempotencyKey, replay bool, err error)
+	Complete(id model.ID[model.IdempotencyKey], code int, body string) error
+	Release(id model.ID[model.IdempotencyKey]) error
+}
+
+// idempotencyService 幂等请求服务实现
+type idempotencyService struct {
+	repo repository.IdempotencyRepository
+	ttl  time.Duration
+}
+
+func NewIdempotencyService(repo repository.IdempotencyRepository, ttl time.Duration) IdempotencyService {
+	return &idempotencyService{repo: repo, ttl: ttl}
+}
+
+// Begin 登记一次幂等请求
+// 首次请求返回新记录且 replay 为 false；已完成的请求返回原记录且 replay 为 true，
+// 仍在处理中或请求内容不一致时返回错误
+func (s *idempotencyService) Begin(key string, userID model.ID[model.User], method, path, requestHash string) (*model.IdempotencyKey, bool, error) {
+	record := &model.IdempotencyKey{
+		Key:         key,
+		UserID:      userID,
+		Method:      method,
+		Path:        path,
+		RequestHash: requestHash,
+		Status:      model.IdempotencyProcessing,
+		ExpiresAt:   time.Now().Add(s.ttl),
+	}
+
+	existing, acquired, err := s.repo.Acquire(record)
+	if err != nil {
+		return nil, false, err
+	}
+	if acquired {
+		return existing, false, nil
+	}
+
+	if existing.RequestHash != requestHash {
+		return nil, false, ErrIdempotencyMismatch
+	}
+	if existing.Status != model.IdempotencyCompleted {
+		return nil, false, ErrIdempotencyInProgress
+	}
+
+	return existing, true, nil
+}
+
+func (s *idempotencyService) Complete(id model.ID[model.IdempotencyKey], code int, body string) error {
+	return s.repo.Complete(id, code, body)
+}
+
+func (s *idempotencyService) Release(id model.ID[model.IdempotencyKey]) error {
+	return s.repo.Release(id)
+}
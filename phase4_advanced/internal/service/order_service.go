@@ -1,54 +1,326 @@
 package service
 
 import (
+	"errors"
+	"fmt"
+	"time"
+
 	"gin-learn/phase4/internal/model"
 	"gin-learn/phase4/internal/repository"
+	"gin-learn/phase4/pkg/logger"
+	"gin-learn/phase4/pkg/money"
+)
+
+var (
+	ErrOrderNotFound     = errors.New("订单不存在")
+	ErrOrderForbidden    = errors.New("无权操作该订单")
+	ErrInvalidTransition = errors.New("订单状态不允许该操作")
 )
 
+// orderTransitions 订单状态机：当前状态 -> 允许流转到的状态。
+// 已支付的订单不能直接取消，必须通过退款退还货款
+var orderTransitions = map[string][]string{
+	model.OrderStatusPending:          {model.OrderStatusPaid, model.OrderStatusCancelled},
+	model.OrderStatusPaid:             {model.OrderStatusPartiallyShipped, model.OrderStatusShipped, model.OrderStatusRefunded},
+	model.OrderStatusPartiallyShipped: {model.OrderStatusShipped, model.OrderStatusRefunded},
+	model.OrderStatusShipped:          {model.OrderStatusDelivered},
+	model.OrderStatusDelivered:        {model.OrderStatusCompleted, model.OrderStatusRefunded},
+	model.OrderStatusCompleted:        {model.OrderStatusRefunded},
+}
+
+// canTransition 判断订单能否从 from 状态流转到 to 状态
+func canTransition(from, to string) bool {
+	for _, next := range orderTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
 // OrderItemInput 订单项输入
 type OrderItemInput struct {
-	ProductID uint `json:"product_id" binding:"required"`
-	Quantity  int  `json:"quantity" binding:"required,min=1"`
+	ProductID model.ID[model.Product]        `json:"product_id" binding:"required"`
+	VariantID model.ID[model.ProductVariant] `json:"variant_id"` // 有规格的产品必须指定
+	Quantity  int                            `json:"quantity" binding:"required,min=1"`
+}
+
+// OrderQuote 订单报价，按下单流程计算的金额明细
+type OrderQuote struct {
+	Currency     string                `json:"currency"`
+	Subtotal     money.Amount          `json:"subtotal"`
+	Discount     money.Amount          `json:"discount"`
+	Shipping     money.Amount          `json:"shipping"`
+	Tax          money.Amount          `json:"tax"`
+	Total        money.Amount          `json:"total"`
+	ShippingZone string                `json:"shipping_zone,omitempty"`
+	Items        []OrderQuoteItem      `json:"items"`
+	Discounts    []model.OrderDiscount `json:"discounts"`
+}
+
+// OrderQuoteItem 订单报价中的订单项
+type OrderQuoteItem struct {
+	ProductID   model.ID[model.Product]        `json:"product_id"`
+	VariantID   model.ID[model.ProductVariant] `json:"variant_id,omitempty"`
+	Name        string                         `json:"name"`
+	SKU         string                         `json:"sku,omitempty"`
+	VariantName string                         `json:"variant_name,omitempty"`
+	Quantity    int                            `json:"quantity"`
+	Price       money.Amount                   `json:"price"`
+	Discount    money.Amount                   `json:"discount"`
+	Tax         money.Amount                   `json:"tax"`
+	TaxRate     string                         `json:"tax_rate"`
 }
 
 // OrderService 订单服务接口
 type OrderService interface {
-	CreateOrder(userID uint, items []OrderItemInput) (*model.Order, error)
-	GetOrder(id uint) (*model.Order, error)
-	ListOrders(page, pageSize int) ([]model.Order, int64, error)
-	CancelOrder(id uint) error
+	CreateOrder(userID model.ID[model.User], addressID model.ID[model.Address], currency, couponCode string, items []OrderItemInput) (*model.Order, error)
+	QuoteOrder(userID model.ID[model.User], addressID model.ID[model.Address], currency, couponCode string, items []OrderItemInput) (*OrderQuote, error)
+	GetOrder(id model.ID[model.Order], userID model.ID[model.User], canManage bool) (*model.Order, error)
+	GetOrderByNumber(orderNo string, userID model.ID[model.User], canManage bool) (*model.Order, error)
+	ListOrders(page, pageSize int, userID model.ID[model.User]) ([]model.Order, int64, error)
+	CancelOrder(id model.ID[model.Order], userID model.ID[model.User], canManage bool, reason string) error
+	DeliverOrder(id model.ID[model.Order], actorID model.ID[model.User], reason string) error
+	CompleteOrder(id model.ID[model.Order], userID model.ID[model.User], canManage bool, reason string) error
+	CancelExpired(ttl time.Duration, batchSize int) (int, error)
+	ReleaseExpiredReservations(batchSize int) (int, error)
 }
 
 // orderService 订单服务实现
 type orderService struct {
-	repo repository.OrderRepository
+	repo           repository.OrderRepository
+	reservationTTL time.Duration
+	alerts         StockAlertService
+}
+
+// NewOrderService 创建订单服务，reservationTTL 为下单时保留库存的时间，0 表示不过期
+func NewOrderService(repo repository.OrderRepository, reservationTTL time.Duration, alerts StockAlertService) OrderService {
+	return &orderService{repo: repo, reservationTTL: reservationTTL, alerts: alerts}
+}
+
+// CreateOrder 创建订单并保留库存，支付后才从库存中扣减。addressID 为 0 时使用默认收货地址，
+// currency 为空时使用默认货币，自动促销、优惠券、运费和税费在创建订单的同一事务中计算
+func (s *orderService) CreateOrder(userID model.ID[model.User], addressID model.ID[model.Address], currency, couponCode string, items []OrderItemInput) (*model.Order, error) {
+	input, err := s.createInput(userID, addressID, currency, couponCode, items)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := s.repo.CreateOrder(input)
+	if err != nil {
+		return nil, err
+	}
+
+	// 下单保留库存后可售库存减少，检查是否低于补货点
+	s.alerts.Check(orderProductIDs(order)...)
+	return order, nil
 }
 
-func NewOrderService(repo repository.OrderRepository) OrderService {
-	return &orderService{repo: repo}
+// QuoteOrder 按与 CreateOrder 相同的流程计算订单金额，不创建订单、不保留库存、不使用优惠券
+func (s *orderService) QuoteOrder(userID model.ID[model.User], addressID model.ID[model.Address], currency, couponCode string, items []OrderItemInput) (*OrderQuote, error) {
+	input, err := s.createInput(userID, addressID, currency, couponCode, items)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := s.repo.QuoteOrder(input)
+	if err != nil {
+		return nil, err
+	}
+
+	quote := &OrderQuote{
+		Currency:     order.Currency,
+		Subtotal:     order.Subtotal,
+		Discount:     order.Discount,
+		Shipping:     order.Shipping,
+		Tax:          order.Tax,
+		Total:        order.Total,
+		ShippingZone: order.ShippingZone,
+		Items:        make([]OrderQuoteItem, len(order.Items)),
+		Discounts:    order.Discounts,
+	}
+	for i, item := range order.Items {
+		quote.Items[i] = OrderQuoteItem{
+			ProductID:   item.ProductID,
+			VariantID:   item.VariantID,
+			Name:        item.Product.Name,
+			SKU:         item.SKU,
+			VariantName: item.VariantName,
+			Quantity:    item.Quantity,
+			Price:       item.Price,
+			Discount:    item.Discount,
+			Tax:         item.Tax,
+			TaxRate:     item.TaxRate,
+		}
+	}
+	// 报价没有保存，订单项没有ID
+	for i := range quote.Discounts {
+		quote.Discounts[i].OrderItemID = nil
+	}
+	return quote, nil
 }
 
-func (s *orderService) CreateOrder(userID uint, items []OrderItemInput) (*model.Order, error) {
-	// 转换输入
+// createInput 校验并转换下单输入
+func (s *orderService) createInput(userID model.ID[model.User], addressID model.ID[model.Address], currency, couponCode string, items []OrderItemInput) (repository.CreateOrderInput, error) {
+	if currency != "" && !money.ValidCurrency(currency) {
+		return repository.CreateOrderInput{}, ErrInvalidCurrency
+	}
+
 	repoItems := make([]repository.OrderItemInput, len(items))
 	for i, item := range items {
 		repoItems[i] = repository.OrderItemInput{
 			ProductID: item.ProductID,
+			VariantID: item.VariantID,
 			Quantity:  item.Quantity,
 		}
 	}
 
-	return s.repo.CreateOrder(userID, repoItems)
+	return repository.CreateOrderInput{
+		UserID:         userID,
+		AddressID:      addressID,
+		Currency:       currency,
+		CouponCode:     NormalizeCouponCode(couponCode),
+		Items:          repoItems,
+		ReservationTTL: s.reservationTTL,
+	}, nil
+}
+
+// GetOrder 获取订单，仅订单所有者或拥有订单管理权限的用户可访问
+func (s *orderService) GetOrder(id model.ID[model.Order], userID model.ID[model.User], canManage bool) (*model.Order, error) {
+	order, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, ErrOrderNotFound
+	}
+
+	if !canManage && order.UserID != userID {
+		return nil, ErrOrderForbidden
+	}
+
+	return order, nil
+}
+
+// GetOrderByNumber 按订单号获取订单，访问权限同 GetOrder
+func (s *orderService) GetOrderByNumber(orderNo string, userID model.ID[model.User], canManage bool) (*model.Order, error) {
+	order, err := s.repo.GetByOrderNo(orderNo)
+	if err != nil {
+		return nil, ErrOrderNotFound
+	}
+
+	if !canManage && order.UserID != userID {
+		return nil, ErrOrderForbidden
+	}
+
+	return order, nil
+}
+
+// ListOrders 分页查询订单，userID 为 0 时查询全部订单
+func (s *orderService) ListOrders(page, pageSize int, userID model.ID[model.User]) ([]model.Order, int64, error) {
+	return s.repo.List(page, pageSize, userID)
+}
+
+// CancelOrder 取消待支付订单并释放保留的库存，拥有订单管理权限的用户可以取消其他用户的订单。
+// 已支付的订单需要通过 RefundService 全额退款，货款和库存在退款时一并退回
+func (s *orderService) CancelOrder(id model.ID[model.Order], userID model.ID[model.User], canManage bool, reason string) error {
+	order, err := s.GetOrder(id, userID, canManage)
+	if err != nil {
+		return err
+	}
+
+	if order.Status != model.OrderStatusPending {
+		return fmt.Errorf("%w: 只能取消待支付的订单，已支付的订单请申请退款", ErrInvalidTransition)
+	}
+
+	return s.transition(order, model.OrderStatusCancelled, userID, reason, true)
+}
+
+// DeliverOrder 订单送达，调用方需拥有订单管理权限
+func (s *orderService) DeliverOrder(id model.ID[model.Order], actorID model.ID[model.User], reason string) error {
+	order, err := s.GetOrder(id, actorID, true)
+	if err != nil {
+		return err
+	}
+
+	return s.transition(order, model.OrderStatusDelivered, actorID, reason, false)
+}
+
+// CompleteOrder 确认收货，完成订单
+func (s *orderService) CompleteOrder(id model.ID[model.Order], userID model.ID[model.User], canManage bool, reason string) error {
+	order, err := s.GetOrder(id, userID, canManage)
+	if err != nil {
+		return err
+	}
+
+	return s.transition(order, model.OrderStatusCompleted, userID, reason, false)
+}
+
+// CancelExpired 取消创建时间超过 ttl 仍未支付的全部订单并恢复库存，每次查询 batchSize 个，返回取消的订单数
+// 取消使用与 CancelOrder 相同的条件更新，多个实例同时执行时每个订单只会被取消一次
+func (s *orderService) CancelExpired(ttl time.Duration, batchSize int) (int, error) {
+	before := time.Now().Add(-ttl)
+	return s.cancelPending(func(afterID model.ID[model.Order]) ([]model.Order, error) {
+		return s.repo.ListPendingBefore(before, afterID, batchSize)
+	}, batchSize, "超时未支付，自动取消")
+}
+
+// ReleaseExpiredReservations 取消库存保留已过期的全部待支付订单并释放保留的库存，每次查询 batchSize 个，返回取消的订单数
+func (s *orderService) ReleaseExpiredReservations(batchSize int) (int, error) {
+	now := time.Now()
+	return s.cancelPending(func(afterID model.ID[model.Order]) ([]model.Order, error) {
+		return s.repo.ListReservationExpired(now, afterID, batchSize)
+	}, batchSize, "库存保留已过期，自动取消")
 }
 
-func (s *orderService) GetOrder(id uint) (*model.Order, error) {
-	return s.repo.GetByID(id)
+// cancelPending 按ID分页遍历 list 返回的待支付订单并由系统取消，已被支付或已被取消的订单会被跳过。
+// 取消失败的订单记录日志后跳过，分页从上一页最后一个订单之后继续，持续失败的订单不会阻塞其后的订单
+func (s *orderService) cancelPending(list func(afterID model.ID[model.Order]) ([]model.Order, error), batchSize int, reason string) (int, error) {
+	var (
+		cancelled int
+		afterID   model.ID[model.Order]
+	)
+	for {
+		orders, err := list(afterID)
+		if err != nil {
+			return cancelled, err
+		}
+
+		for i := range orders {
+			err := s.transition(&orders[i], model.OrderStatusCancelled, 0, reason, true)
+			if errors.Is(err, repository.ErrStatusConflict) {
+				// 订单已被支付或被其他实例取消
+				continue
+			}
+			if err != nil {
+				logger.Error("Failed to cancel pending order",
+					logger.Int("order_id", int(orders[i].ID)),
+					logger.String("reason", reason),
+					logger.ErrorField(err),
+				)
+				continue
+			}
+			cancelled++
+		}
+
+		if len(orders) < batchSize {
+			return cancelled, nil
+		}
+		afterID = orders[len(orders)-1].ID
+	}
 }
 
-func (s *orderService) ListOrders(page, pageSize int) ([]model.Order, int64, error) {
-	return s.repo.List(page, pageSize)
+// orderProductIDs 返回订单中的产品ID
+func orderProductIDs(order *model.Order) []model.ID[model.Product] {
+	ids := make([]model.ID[model.Product], len(order.Items))
+	for i, item := range order.Items {
+		ids[i] = item.ProductID
+	}
+	return ids
 }
 
-func (s *orderService) CancelOrder(id uint) error {
-	return s.repo.CancelOrder(id)
+// transition 校验状态机并执行状态变更
+func (s *orderService) transition(order *model.Order, to string, actorID model.ID[model.User], reason string, restock bool) error {
+	if !canTransition(order.Status, to) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, order.Status, to)
+	}
+
+	return s.repo.TransitionStatus(order.ID, order.Status, to, actorID, reason, restock)
 }
@@ -0,0 +1,248 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/internal/repository"
+	"gin-learn/phase4/pkg/logger"
+)
+
+var (
+	ErrPaymentNotFound         = errors.New("支付记录不存在")
+	ErrPaymentProviderNotFound = errors.New("支付渠道不存在")
+)
+
+// PaymentService 支付服务接口
+type PaymentService interface {
+	Pay(orderID model.ID[model.Order], userID model.ID[model.User]) (*model.Payment, error)
+	ListPayments(orderID model.ID[model.Order], userID model.ID[model.User], canManage bool) ([]model.Payment, error)
+	HandleWebhook(provider string, payload []byte, signature string) error
+	SimulatePayment(intentID string, userID model.ID[model.User], canManage bool, status string, delay time.Duration) error
+	ListOrphanedPayments() ([]model.Payment, error)
+	RefundOrphanedPayment(id model.ID[model.Payment]) (*model.Payment, error)
+}
+
+// paymentService 支付服务实现
+type paymentService struct {
+	repo      *repository.Repository
+	orders    OrderService
+	providers map[string]PaymentProvider
+	provider  PaymentProvider
+}
+
+// NewPaymentService 创建支付服务，defaultProvider 为新建支付使用的渠道
+func NewPaymentService(repo *repository.Repository, orders OrderService, defaultProvider string, providers ...PaymentProvider) PaymentService {
+	s := &paymentService{
+		repo:      repo,
+		orders:    orders,
+		providers: make(map[string]PaymentProvider, len(providers)),
+	}
+	for _, p := range providers {
+		s.providers[p.Name()] = p
+	}
+	s.provider = s.providers[defaultProvider]
+	return s
+}
+
+// Pay 为待支付订单创建支付意图，订单已有未完成的支付时直接返回该支付
+func (s *paymentService) Pay(orderID model.ID[model.Order], userID model.ID[model.User]) (*model.Payment, error) {
+	order, err := s.orders.GetOrder(orderID, userID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if order.Status != model.OrderStatusPending {
+		return nil, fmt.Errorf("%w: 只能支付待支付的订单", ErrInvalidTransition)
+	}
+
+	if payment, err := s.repo.Payment.GetPendingByOrder(order.ID); err == nil {
+		return payment, nil
+	}
+
+	if s.provider == nil {
+		return nil, ErrPaymentProviderNotFound
+	}
+
+	payment := &model.Payment{
+		OrderID:  order.ID,
+		UserID:   order.UserID,
+		Provider: s.provider.Name(),
+		Amount:   order.Total,
+		Currency: order.Currency,
+		Status:   model.PaymentStatusPending,
+	}
+	payment.IntentID, err = s.provider.CreateIntent(payment)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Payment.Create(payment); err != nil {
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+// ListPayments 获取订单的支付记录
+func (s *paymentService) ListPayments(orderID model.ID[model.Order], userID model.ID[model.User], canManage bool) ([]model.Payment, error) {
+	if _, err := s.orders.GetOrder(orderID, userID, canManage); err != nil {
+		return nil, err
+	}
+	return s.repo.Payment.ListByOrder(orderID)
+}
+
+// HandleWebhook 校验签名后处理支付回调，重复的回调会被忽略
+func (s *paymentService) HandleWebhook(provider string, payload []byte, signature string) error {
+	p, ok := s.providers[provider]
+	if !ok {
+		return ErrPaymentProviderNotFound
+	}
+
+	event, err := p.ParseWebhook(payload, signature)
+	if err != nil {
+		return err
+	}
+
+	payment, err := s.repo.Payment.GetByIntent(provider, event.IntentID)
+	if err != nil {
+		return ErrPaymentNotFound
+	}
+
+	switch event.Status {
+	case model.PaymentStatusSucceeded:
+		return s.succeed(payment)
+	case model.PaymentStatusFailed:
+		_, err := s.repo.Payment.MarkFailed(payment.ID, event.Reason)
+		return err
+	default:
+		return fmt.Errorf("未知的支付状态: %s", event.Status)
+	}
+}
+
+// succeed 在同一事务中标记支付成功并将订单流转为已支付。
+// 支付期间订单已被取消等，钱已收到但订单无法变为已支付时，支付标记为孤立支付并在事务外原路退回
+func (s *paymentService) succeed(payment *model.Payment) error {
+	orphaned := false
+	err := s.repo.Transaction(func(tx *repository.Repository) error {
+		ok, err := tx.Payment.MarkSucceeded(payment.ID)
+		if err != nil || !ok {
+			return err
+		}
+
+		order, err := tx.Order.GetByID(payment.OrderID)
+		if err != nil {
+			return err
+		}
+
+		if order.Status == model.OrderStatusPending {
+			err = tx.Order.TransitionStatus(order.ID, model.OrderStatusPending, model.OrderStatusPaid, 0,
+				"支付成功: "+payment.IntentID, false)
+			if !errors.Is(err, repository.ErrStatusConflict) {
+				return err
+			}
+		}
+
+		orphaned = true
+		_, err = tx.Payment.MarkOrphaned(payment.ID)
+		return err
+	})
+	if err != nil || !orphaned {
+		return err
+	}
+
+	// 退款失败时支付保持孤立状态，由运营人员重试，回调本身已处理完成
+	if _, err := s.refundOrphaned(payment); err != nil {
+		logger.Error("Failed to refund orphaned payment",
+			logger.Int("payment_id", int(payment.ID)),
+			logger.Int("order_id", int(payment.OrderID)),
+			logger.String("intent_id", payment.IntentID),
+			logger.ErrorField(err),
+		)
+	}
+	return nil
+}
+
+// ListOrphanedPayments 获取等待原路退回的孤立支付
+func (s *paymentService) ListOrphanedPayments() ([]model.Payment, error) {
+	return s.repo.Payment.ListByStatus(model.PaymentStatusOrphaned)
+}
+
+// RefundOrphanedPayment 重新将孤立支付原路退回
+func (s *paymentService) RefundOrphanedPayment(id model.ID[model.Payment]) (*model.Payment, error) {
+	payment, err := s.repo.Payment.GetByID(id)
+	if err != nil {
+		return nil, ErrPaymentNotFound
+	}
+	if payment.Status != model.PaymentStatusOrphaned {
+		return nil, fmt.Errorf("%w: 只能退回孤立的支付", ErrInvalidTransition)
+	}
+	return s.refundOrphaned(payment)
+}
+
+// refundOrphaned 通过支付渠道全额退回孤立支付并记录退款单号
+func (s *paymentService) refundOrphaned(payment *model.Payment) (*model.Payment, error) {
+	provider, ok := s.providers[payment.Provider]
+	if !ok {
+		return nil, ErrPaymentProviderNotFound
+	}
+
+	ref, err := provider.Refund(payment, payment.Amount)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err = s.repo.Payment.MarkRefunded(payment.ID, ref)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: 支付已被退回", ErrInvalidTransition)
+	}
+
+	return s.repo.Payment.GetByID(payment.ID)
+}
+
+// SimulatePayment 使用模拟渠道生成一条签名回调并走正常的回调处理流程，delay 大于 0 时异步回调
+func (s *paymentService) SimulatePayment(intentID string, userID model.ID[model.User], canManage bool, status string, delay time.Duration) error {
+	mock, ok := s.providers["mock"].(*MockProvider)
+	if !ok {
+		return ErrPaymentProviderNotFound
+	}
+
+	payment, err := s.repo.Payment.GetByIntent(mock.Name(), intentID)
+	if err != nil {
+		return ErrPaymentNotFound
+	}
+	if !canManage && payment.UserID != userID {
+		return ErrOrderForbidden
+	}
+
+	event := PaymentEvent{IntentID: intentID, Status: status}
+	if status == model.PaymentStatusFailed {
+		event.Reason = "模拟支付失败"
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	signature := mock.Sign(payload)
+
+	if delay <= 0 {
+		return s.HandleWebhook(mock.Name(), payload, signature)
+	}
+
+	go func() {
+		time.Sleep(delay)
+		if err := s.HandleWebhook(mock.Name(), payload, signature); err != nil {
+			logger.Error("Failed to deliver simulated payment webhook",
+				logger.String("intent_id", intentID),
+				logger.ErrorField(err),
+			)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,258 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"gin-learn/phase4/config"
+	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/internal/repository"
+	"gin-learn/phase4/pkg/logger"
+	"gin-learn/phase4/pkg/money"
+)
+
+const testWebhookSecret = "test-webhook-secret"
+
+// paymentFixture 使用临时 SQLite 数据库和模拟支付渠道，创建一个待支付订单
+type paymentFixture struct {
+	repo     *repository.Repository
+	orders   OrderService
+	payments PaymentService
+	mock     *MockProvider
+	product  *model.Product
+	order    *model.Order
+	payment  *model.Payment
+}
+
+func newPaymentFixture(t *testing.T) *paymentFixture {
+	t.Helper()
+
+	logger.Init("error")
+	config.C.DB = config.DBConfig{Type: "sqlite", Database: filepath.Join(t.TempDir(), "test.db")}
+	db, err := repository.InitDB()
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	f := &paymentFixture{repo: repository.NewRepository(db), mock: NewMockProvider(testWebhookSecret)}
+	f.orders = NewOrderService(f.repo.Order, 0, NewStockAlertService(f.repo.StockAlert))
+	f.payments = NewPaymentService(f.repo, f.orders, f.mock.Name(), f.mock)
+
+	user := &model.User{Username: "payer", Email: "payer@example.com", Password: "-", Status: 1}
+	if err := f.repo.User.Create(user); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.repo.Address.Create(&model.Address{UserID: user.ID, AddressInfo: model.AddressInfo{Receiver: "payer", Province: "广东省", City: "深圳市", Detail: "1号"}}); err != nil {
+		t.Fatal(err)
+	}
+	zone := &model.ShippingZone{Name: "全国", Method: model.ShippingMethodFlat, Currency: model.DefaultCurrency, Fee: money.Amount(500)}
+	if err := f.repo.ShippingZone.Create(zone); err != nil {
+		t.Fatal(err)
+	}
+	f.product = &model.Product{Name: "widget", Price: money.Amount(1999), Stock: 5}
+	if err := f.repo.Product.Create(f.product); err != nil {
+		t.Fatal(err)
+	}
+
+	f.order, err = f.orders.CreateOrder(user.ID, 0, "", "", []OrderItemInput{{ProductID: f.product.ID, Quantity: 2}})
+	if err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+	f.payment, err = f.payments.Pay(f.order.ID, user.ID)
+	if err != nil {
+		t.Fatalf("Pay: %v", err)
+	}
+	return f
+}
+
+// event 生成模拟渠道的回调内容及其签名
+func (f *paymentFixture) event(t *testing.T, status string) ([]byte, string) {
+	t.Helper()
+
+	payload, err := json.Marshal(PaymentEvent{IntentID: f.payment.IntentID, Status: status})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return payload, f.mock.Sign(payload)
+}
+
+func (f *paymentFixture) reload(t *testing.T) *model.Order {
+	t.Helper()
+
+	order, err := f.repo.Order.GetByID(f.order.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return order
+}
+
+func TestPaymentWebhookMarksOrderPaid(t *testing.T) {
+	f := newPaymentFixture(t)
+
+	if f.payment.Status != model.PaymentStatusPending || f.payment.Amount != f.order.Total {
+		t.Fatalf("payment status %s amount %s, want pending %s", f.payment.Status, f.payment.Amount, f.order.Total)
+	}
+
+	payload, signature := f.event(t, model.PaymentStatusSucceeded)
+	if err := f.payments.HandleWebhook("mock", payload, signature); err != nil {
+		t.Fatalf("HandleWebhook: %v", err)
+	}
+
+	if order := f.reload(t); order.Status != model.OrderStatusPaid {
+		t.Errorf("order status %s, want %s", order.Status, model.OrderStatusPaid)
+	}
+	payment, err := f.repo.Payment.GetByIntent("mock", f.payment.IntentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payment.Status != model.PaymentStatusSucceeded || payment.PaidAt == nil {
+		t.Errorf("payment status %s paid_at %v, want succeeded with paid_at", payment.Status, payment.PaidAt)
+	}
+	product, err := f.repo.Product.GetByID(f.product.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if product.Stock != 3 || product.Reserved != 0 {
+		t.Errorf("product stock %d reserved %d, want 3 and 0", product.Stock, product.Reserved)
+	}
+}
+
+func TestPaymentWebhookRejectsBadSignature(t *testing.T) {
+	f := newPaymentFixture(t)
+
+	payload, _ := f.event(t, model.PaymentStatusSucceeded)
+	forged := NewMockProvider("wrong-secret").Sign(payload)
+
+	for name, signature := range map[string]string{
+		"wrong secret": forged,
+		"not hex":      "not-a-signature",
+		"empty":        "",
+	} {
+		err := f.payments.HandleWebhook("mock", payload, signature)
+		if !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%s: err = %v, want ErrInvalidSignature", name, err)
+		}
+	}
+
+	// 签名正确但内容被篡改
+	_, signature := f.event(t, model.PaymentStatusFailed)
+	if err := f.payments.HandleWebhook("mock", payload, signature); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("tampered payload: err = %v, want ErrInvalidSignature", err)
+	}
+
+	if order := f.reload(t); order.Status != model.OrderStatusPending {
+		t.Errorf("order status %s, want %s", order.Status, model.OrderStatusPending)
+	}
+}
+
+func TestPaymentWebhookReplayIsIgnored(t *testing.T) {
+	f := newPaymentFixture(t)
+
+	payload, signature := f.event(t, model.PaymentStatusSucceeded)
+	for i := 0; i < 3; i++ {
+		if err := f.payments.HandleWebhook("mock", payload, signature); err != nil {
+			t.Fatalf("delivery %d: %v", i+1, err)
+		}
+	}
+
+	// 成功之后到达的失败回调同样被忽略
+	payload, signature = f.event(t, model.PaymentStatusFailed)
+	if err := f.payments.HandleWebhook("mock", payload, signature); err != nil {
+		t.Fatalf("late failure: %v", err)
+	}
+
+	order := f.reload(t)
+	if order.Status != model.OrderStatusPaid {
+		t.Errorf("order status %s, want %s", order.Status, model.OrderStatusPaid)
+	}
+	paid := 0
+	for _, h := range order.History {
+		if h.ToStatus == model.OrderStatusPaid {
+			paid++
+		}
+	}
+	if paid != 1 {
+		t.Errorf("order moved to paid %d times, want 1", paid)
+	}
+
+	payment, err := f.repo.Payment.GetByIntent("mock", f.payment.IntentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payment.Status != model.PaymentStatusSucceeded {
+		t.Errorf("payment status %s, want %s", payment.Status, model.PaymentStatusSucceeded)
+	}
+	product, err := f.repo.Product.GetByID(f.product.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if product.Stock != 3 {
+		t.Errorf("product stock %d, want 3 (deducted once)", product.Stock)
+	}
+}
+
+// cancelledPayment 取消订单后再发送支付成功回调
+func (f *paymentFixture) cancelledPayment(t *testing.T, payments PaymentService) *model.Payment {
+	t.Helper()
+
+	if err := f.orders.CancelOrder(f.order.ID, f.order.UserID, false, "不想要了"); err != nil {
+		t.Fatalf("CancelOrder: %v", err)
+	}
+	payload, signature := f.event(t, model.PaymentStatusSucceeded)
+	if err := payments.HandleWebhook("mock", payload, signature); err != nil {
+		t.Fatalf("HandleWebhook: %v", err)
+	}
+
+	payment, err := f.repo.Payment.GetByID(f.payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return payment
+}
+
+func TestPaymentForCancelledOrderIsRefunded(t *testing.T) {
+	f := newPaymentFixture(t)
+
+	payment := f.cancelledPayment(t, f.payments)
+	if payment.Status != model.PaymentStatusRefunded || payment.RefundRef == "" {
+		t.Errorf("payment status %s refund_ref %q, want refunded with ref", payment.Status, payment.RefundRef)
+	}
+	if order := f.reload(t); order.Status != model.OrderStatusCancelled {
+		t.Errorf("order status %s, want %s", order.Status, model.OrderStatusCancelled)
+	}
+}
+
+func TestOrphanedPaymentRefundCanBeRetried(t *testing.T) {
+	f := newPaymentFixture(t)
+
+	payment := f.cancelledPayment(t, NewPaymentService(f.repo, f.orders, "mock", failingProvider{f.mock}))
+	if payment.Status != model.PaymentStatusOrphaned {
+		t.Fatalf("payment status %s, want %s", payment.Status, model.PaymentStatusOrphaned)
+	}
+	orphaned, err := f.payments.ListOrphanedPayments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orphaned) != 1 || orphaned[0].ID != payment.ID {
+		t.Errorf("orphaned payments %+v, want only %s", orphaned, payment.ID)
+	}
+
+	// 渠道恢复后重试退回
+	payment, err = f.payments.RefundOrphanedPayment(payment.ID)
+	if err != nil {
+		t.Fatalf("RefundOrphanedPayment: %v", err)
+	}
+	if payment.Status != model.PaymentStatusRefunded || payment.RefundRef == "" {
+		t.Errorf("payment status %s refund_ref %q, want refunded with ref", payment.Status, payment.RefundRef)
+	}
+	if _, err := f.payments.RefundOrphanedPayment(payment.ID); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("second refund err = %v, want ErrInvalidTransition", err)
+	}
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/internal/repository"
+	"gin-learn/phase4/pkg/money"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrShippingZoneNotFound = errors.New("配送区域不存在")
+	ErrInvalidShippingZone  = errors.New("无效的配送区域")
+	ErrTaxRateNotFound      = errors.New("税率不存在")
+	ErrInvalidTaxRate       = errors.New("税率必须是 0 到 1 之间的小数")
+	ErrInvalidTaxClass      = errors.New("无效的税类")
+)
+
+// PricingService 运费和税率配置服务接口
+type PricingService interface {
+	ListShippingZones() ([]model.ShippingZone, error)
+	CreateShippingZone(zone model.ShippingZone) (*model.ShippingZone, error)
+	UpdateShippingZone(id model.ID[model.ShippingZone], zone model.ShippingZone) (*model.ShippingZone, error)
+	DeleteShippingZone(id model.ID[model.ShippingZone]) error
+	ListTaxRates() ([]model.TaxRate, error)
+	SetTaxRate(region, taxClass, rate string) (*model.TaxRate, error)
+	DeleteTaxRate(id model.ID[model.TaxRate]) error
+}
+
+// pricingService 运费和税率配置服务实现
+type pricingService struct {
+	zones repository.ShippingZoneRepository
+	taxes repository.TaxRateRepository
+}
+
+func NewPricingService(zones repository.ShippingZoneRepository, taxes repository.TaxRateRepository) PricingService {
+	return &pricingService{zones: zones, taxes: taxes}
+}
+
+func (s *pricingService) ListShippingZones() ([]model.ShippingZone, error) {
+	return s.zones.List()
+}
+
+func (s *pricingService) CreateShippingZone(zone model.ShippingZone) (*model.ShippingZone, error) {
+	if err := validateShippingZone(&zone); err != nil {
+		return nil, err
+	}
+
+	zone.ID = 0
+	if err := s.zones.Create(&zone); err != nil {
+		return nil, err
+	}
+	return &zone, nil
+}
+
+func (s *pricingService) UpdateShippingZone(id model.ID[model.ShippingZone], zone model.ShippingZone) (*model.ShippingZone, error) {
+	if err := validateShippingZone(&zone); err != nil {
+		return nil, err
+	}
+
+	zone.ID = id
+	err := s.zones.Update(&zone)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrShippingZoneNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.zones.GetByID(id)
+}
+
+func (s *pricingService) DeleteShippingZone(id model.ID[model.ShippingZone]) error {
+	err := s.zones.Delete(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrShippingZoneNotFound
+	}
+	return err
+}
+
+func (s *pricingService) ListTaxRates() ([]model.TaxRate, error) {
+	return s.taxes.List()
+}
+
+// SetTaxRate 新增或更新税率，region 和 taxClass 为空表示不限
+func (s *pricingService) SetTaxRate(region, taxClass, rate string) (*model.TaxRate, error) {
+	region = strings.TrimSpace(region)
+	taxClass = NormalizeTaxClass(taxClass)
+	rate = strings.TrimSpace(rate)
+	if !validTaxRate(rate) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidTaxRate, rate)
+	}
+
+	rates := []model.TaxRate{{Region: region, TaxClass: taxClass, Rate: rate}}
+	if err := s.taxes.Save(rates); err != nil {
+		return nil, err
+	}
+	return &rates[0], nil
+}
+
+func (s *pricingService) DeleteTaxRate(id model.ID[model.TaxRate]) error {
+	err := s.taxes.Delete(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrTaxRateNotFound
+	}
+	return err
+}
+
+// NormalizeTaxClass 统一税类格式：去除首尾空白并转为小写
+func NormalizeTaxClass(taxClass string) string {
+	return strings.ToLower(strings.TrimSpace(taxClass))
+}
+
+// validTaxRate 判断税率是否为 [0, 1) 之间的十进制小数
+func validTaxRate(rate string) bool {
+	r, ok := new(big.Rat).SetString(rate)
+	return ok && !strings.ContainsAny(rate, "/eE") && r.Sign() >= 0 && r.Cmp(big.NewRat(1, 1)) < 0
+}
+
+// validateShippingZone 校验并规范化配送区域，地区列表去除空白和空项
+func validateShippingZone(zone *model.ShippingZone) error {
+	zone.Name = strings.TrimSpace(zone.Name)
+	if zone.Name == "" {
+		return fmt.Errorf("%w: 名称不能为空", ErrInvalidShippingZone)
+	}
+
+	var regions []string
+	for _, region := range strings.Split(zone.Regions, ",") {
+		if region = strings.TrimSpace(region); region != "" {
+			regions = append(regions, region)
+		}
+	}
+	zone.Regions = strings.Join(regions, ",")
+
+	zone.Currency = strings.ToUpper(strings.TrimSpace(zone.Currency))
+	if zone.Currency == "" {
+		zone.Currency = model.DefaultCurrency
+	}
+	if !money.ValidCurrency(zone.Currency) {
+		return ErrInvalidCurrency
+	}
+
+	if zone.Fee < 0 || zone.StepFee < 0 || zone.FreeAbove < 0 {
+		return fmt.Errorf("%w: 金额不能为负数", ErrInvalidShippingZone)
+	}
+	switch zone.Method {
+	case model.ShippingMethodFlat:
+		zone.FirstWeight, zone.StepWeight, zone.StepFee = 0, 0, 0
+	case model.ShippingMethodWeight:
+		if zone.FirstWeight < 0 || zone.StepWeight <= 0 {
+			return fmt.Errorf("%w: 首重不能为负数，续重单位必须大于 0", ErrInvalidShippingZone)
+		}
+	default:
+		return fmt.Errorf("%w: 未知的计费方式 %q", ErrInvalidShippingZone, zone.Method)
+	}
+	return nil
+}
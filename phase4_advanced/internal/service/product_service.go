@@ -1,54 +1,106 @@
 package service
 
 import (
+	"errors"
+	"math"
+	"strings"
+
 	"gin-learn/phase4/internal/model"
 	"gin-learn/phase4/internal/repository"
+	"gin-learn/phase4/pkg/money"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidReorderPoint = errors.New("补货点必须是非负整数")
+	ErrInvalidWeight       = errors.New("重量必须是非负整数")
+	ErrInvalidCategoryID   = errors.New("无效的分类ID")
+	ErrInvalidOption       = errors.New("规格类型名称和取值不能为空")
+	ErrInvalidSKU          = errors.New("SKU不能为空")
 )
 
+// VariantInput 创建规格的输入，Options 为规格类型名称到取值的映射，
+// Price 为 0 时使用产品价格，初始库存入库到 WarehouseID 指定的仓库，为 0 时使用默认仓库
+type VariantInput struct {
+	SKU         string
+	Price       money.Amount
+	Stock       int
+	WarehouseID model.ID[model.Warehouse]
+	Options     map[string]string
+}
+
 // ProductService 产品服务接口
 type ProductService interface {
-	CreateProduct(name, description string, price float64, stock int, categoryID uint) (*model.Product, error)
-	GetProduct(id uint) (*model.Product, error)
-	ListProducts(page, pageSize int, categoryID uint, keyword string) ([]model.Product, int64, error)
-	UpdateProduct(id uint, updates map[string]interface{}) error
-	DeleteProduct(id uint) error
-	SearchProducts(minPrice, maxPrice float64, categoryID uint, keyword, sortBy, sortOrder string, page, pageSize int) ([]model.Product, int64, error)
+	CreateProduct(name, description string, price money.Amount, currency string, stock, reorderPoint, weight int, taxClass string, categoryID model.ID[model.Category]) (*model.Product, error)
+	GetProduct(id model.ID[model.Product]) (*model.Product, error)
+	ListProducts(page, pageSize int, categoryID model.ID[model.Category], keyword string, options map[string]string) ([]model.Product, int64, error)
+	UpdateProduct(id model.ID[model.Product], updates map[string]interface{}) error
+	DeleteProduct(id model.ID[model.Product]) error
+	SearchProducts(minPrice, maxPrice money.Amount, categoryID model.ID[model.Category], keyword string, options map[string]string, sortBy, sortOrder string, page, pageSize int) ([]model.Product, int64, error)
+	SaveOption(productID model.ID[model.Product], name string, values []string) (*model.ProductOption, error)
+	CreateVariant(productID model.ID[model.Product], actorID model.ID[model.User], input VariantInput) (*model.ProductVariant, error)
+	UpdateVariant(productID model.ID[model.Product], variantID model.ID[model.ProductVariant], sku *string, price *money.Amount) (*model.ProductVariant, error)
 }
 
 // productService 产品服务实现
 type productService struct {
-	repo repository.ProductRepository
+	repo       repository.ProductRepository
+	variants   repository.VariantRepository
+	warehouses repository.WarehouseRepository
+	alerts     StockAlertService
 }
 
-func NewProductService(repo repository.ProductRepository) ProductService {
-	return &productService{repo: repo}
+func NewProductService(repo repository.ProductRepository, variants repository.VariantRepository, warehouses repository.WarehouseRepository, alerts StockAlertService) ProductService {
+	return &productService{repo: repo, variants: variants, warehouses: warehouses, alerts: alerts}
 }
 
-func (s *productService) CreateProduct(name, description string, price float64, stock int, categoryID uint) (*model.Product, error) {
+// CreateProduct 创建产品，初始库存低于补货点时立即产生低库存告警
+func (s *productService) CreateProduct(name, description string, price money.Amount, currency string, stock, reorderPoint, weight int, taxClass string, categoryID model.ID[model.Category]) (*model.Product, error) {
+	if currency == "" {
+		currency = model.DefaultCurrency
+	}
+	if !money.ValidCurrency(currency) {
+		return nil, ErrInvalidCurrency
+	}
+	if taxClass = NormalizeTaxClass(taxClass); taxClass == "" {
+		taxClass = model.DefaultTaxClass
+	}
+
 	product := &model.Product{
-		Name:        name,
-		Description: description,
-		Price:       price,
-		Stock:       stock,
-		CategoryID:  categoryID,
+		Name:         name,
+		Description:  description,
+		Price:        price,
+		Currency:     currency,
+		Stock:        stock,
+		ReorderPoint: reorderPoint,
+		Weight:       weight,
+		TaxClass:     taxClass,
+		CategoryID:   categoryID,
 	}
 
 	if err := s.repo.Create(product); err != nil {
 		return nil, err
 	}
+	s.alerts.Check(product.ID)
 
 	return s.repo.GetByID(product.ID)
 }
 
-func (s *productService) GetProduct(id uint) (*model.Product, error) {
+func (s *productService) GetProduct(id model.ID[model.Product]) (*model.Product, error) {
 	return s.repo.GetByID(id)
 }
 
-func (s *productService) ListProducts(page, pageSize int, categoryID uint, keyword string) ([]model.Product, int64, error) {
-	return s.repo.List(page, pageSize, categoryID, keyword)
+func (s *productService) ListProducts(page, pageSize int, categoryID model.ID[model.Category], keyword string, options map[string]string) ([]model.Product, int64, error) {
+	return s.repo.List(page, pageSize, categoryID, keyword, options)
 }
 
-func (s *productService) UpdateProduct(id uint, updates map[string]interface{}) error {
+// UpdateProduct 更新产品信息，库存需要通过 StockService 调整
+func (s *productService) UpdateProduct(id model.ID[model.Product], updates map[string]interface{}) error {
+	if _, ok := updates["stock"]; ok {
+		return ErrStockNotEditable
+	}
+
 	product, err := s.repo.GetByID(id)
 	if err != nil {
 		return err
@@ -61,23 +113,187 @@ func (s *productService) UpdateProduct(id uint, updates map[string]interface{})
 	if desc, ok := updates["description"].(string); ok {
 		product.Description = desc
 	}
-	if price, ok := updates["price"].(float64); ok {
+	if v, ok := updates["price"]; ok {
+		price, err := parsePrice(v)
+		if err != nil {
+			return err
+		}
 		product.Price = price
 	}
-	if stock, ok := updates["stock"].(float64); ok {
-		product.Stock = int(stock)
+	if currency, ok := updates["currency"].(string); ok {
+		if !money.ValidCurrency(currency) {
+			return ErrInvalidCurrency
+		}
+		product.Currency = currency
+	}
+	if v, ok := updates["category_id"]; ok {
+		raw, _ := v.(string)
+		categoryID, err := model.ParseID[model.Category](raw)
+		if err != nil || (v != nil && raw == "") {
+			return ErrInvalidCategoryID
+		}
+		product.CategoryID = categoryID
+	}
+	if v, ok := updates["reorder_point"]; ok {
+		point, ok := v.(float64)
+		if !ok || point < 0 || point != math.Trunc(point) {
+			return ErrInvalidReorderPoint
+		}
+		product.ReorderPoint = int(point)
 	}
-	if catID, ok := updates["category_id"].(float64); ok {
-		product.CategoryID = uint(catID)
+	if v, ok := updates["weight"]; ok {
+		weight, ok := v.(float64)
+		if !ok || weight < 0 || weight != math.Trunc(weight) {
+			return ErrInvalidWeight
+		}
+		product.Weight = int(weight)
+	}
+	if taxClass, ok := updates["tax_class"].(string); ok {
+		if taxClass = NormalizeTaxClass(taxClass); taxClass == "" {
+			return ErrInvalidTaxClass
+		}
+		product.TaxClass = taxClass
 	}
 
-	return s.repo.Update(product)
+	if err := s.repo.Update(product); err != nil {
+		return err
+	}
+	// 调整补货点后重新检查告警
+	s.alerts.Check(product.ID)
+	return nil
 }
 
-func (s *productService) DeleteProduct(id uint) error {
+func (s *productService) DeleteProduct(id model.ID[model.Product]) error {
 	return s.repo.Delete(id)
 }
 
-func (s *productService) SearchProducts(minPrice, maxPrice float64, categoryID uint, keyword, sortBy, sortOrder string, page, pageSize int) ([]model.Product, int64, error) {
-	return s.repo.Search(minPrice, maxPrice, categoryID, keyword, sortBy, sortOrder, page, pageSize)
+func (s *productService) SearchProducts(minPrice, maxPrice money.Amount, categoryID model.ID[model.Category], keyword string, options map[string]string, sortBy, sortOrder string, page, pageSize int) ([]model.Product, int64, error) {
+	return s.repo.Search(minPrice, maxPrice, categoryID, keyword, options, sortBy, sortOrder, page, pageSize)
+}
+
+// SaveOption 新增规格类型（如尺码、颜色）或为已有的规格类型追加可选值
+func (s *productService) SaveOption(productID model.ID[model.Product], name string, values []string) (*model.ProductOption, error) {
+	name = strings.TrimSpace(name)
+	trimmed := make([]string, 0, len(values))
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			trimmed = append(trimmed, value)
+		}
+	}
+	if name == "" || len(trimmed) == 0 {
+		return nil, ErrInvalidOption
+	}
+
+	option, err := s.variants.SaveOption(productID, name, trimmed)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrProductNotFound
+	}
+	return option, err
+}
+
+// CreateVariant 为产品创建规格，初始库存写入库存流水。规格创建后产品库存为全部规格库存之和
+func (s *productService) CreateVariant(productID model.ID[model.Product], actorID model.ID[model.User], input VariantInput) (*model.ProductVariant, error) {
+	sku := strings.TrimSpace(input.SKU)
+	if sku == "" {
+		return nil, ErrInvalidSKU
+	}
+	if input.WarehouseID != 0 {
+		if _, err := s.warehouses.GetByID(input.WarehouseID); err != nil {
+			return nil, ErrWarehouseNotFound
+		}
+	}
+
+	variant := &model.ProductVariant{
+		ProductID: productID,
+		SKU:       sku,
+		Price:     input.Price,
+		Stock:     input.Stock,
+	}
+	err := s.variants.Create(variant, input.Options, &model.StockMovement{
+		WarehouseID: input.WarehouseID,
+		Reason:      model.StockReasonImport,
+		ActorID:     actorID,
+		Note:        "规格初始库存",
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrProductNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.alerts.Check(productID)
+
+	return s.variants.GetByID(variant.ID)
+}
+
+// UpdateVariant 修改规格的SKU或价格，price 为 0 时恢复使用产品价格。库存需要通过 StockService 调整
+func (s *productService) UpdateVariant(productID model.ID[model.Product], variantID model.ID[model.ProductVariant], sku *string, price *money.Amount) (*model.ProductVariant, error) {
+	variant, err := s.variants.GetByID(variantID)
+	if err != nil || variant.ProductID != productID {
+		return nil, repository.ErrVariantNotFound
+	}
+
+	updates := map[string]interface{}{}
+	if sku != nil {
+		trimmed := strings.TrimSpace(*sku)
+		if trimmed == "" {
+			return nil, ErrInvalidSKU
+		}
+		updates["sku"] = trimmed
+	}
+	if price != nil {
+		if *price < 0 {
+			return nil, money.ErrInvalidAmount
+		}
+		updates["price"] = *price
+	}
+	if len(updates) == 0 {
+		return variant, nil
+	}
+
+	err = s.variants.Update(variantID, updates)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, repository.ErrVariantNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.variants.GetByID(variantID)
+}
+
+// selectVariant 在产品的规格中查找 variantID，有规格的产品必须指定规格，没有规格的产品返回 nil
+func selectVariant(product *model.Product, variantID model.ID[model.ProductVariant]) (*model.ProductVariant, error) {
+	if variantID == 0 {
+		if len(product.Variants) > 0 {
+			return nil, repository.ErrVariantRequired
+		}
+		return nil, nil
+	}
+	for i := range product.Variants {
+		if product.Variants[i].ID == variantID {
+			return &product.Variants[i], nil
+		}
+	}
+	return nil, repository.ErrVariantNotFound
+}
+
+// parsePrice 解析更新请求中的价格，支持 JSON 数字和字符串，价格必须大于 0
+func parsePrice(v interface{}) (money.Amount, error) {
+	var price money.Amount
+	switch p := v.(type) {
+	case float64:
+		price = money.FromFloat(p)
+	case string:
+		var err error
+		if price, err = money.Parse(p); err != nil {
+			return 0, err
+		}
+	default:
+		return 0, money.ErrInvalidAmount
+	}
+
+	if price <= 0 {
+		return 0, errors.New("价格必须大于0")
+	}
+	return price, nil
 }
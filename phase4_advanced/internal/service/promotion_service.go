@@ -0,0 +1,91 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrPromotionNotFound = errors.New("促销活动不存在")
+	ErrInvalidPromotion  = errors.New("无效的促销活动")
+)
+
+// PromotionService 促销活动服务接口
+type PromotionService interface {
+	CreatePromotion(promotion model.Promotion) (*model.Promotion, error)
+	GetPromotion(id model.ID[model.Promotion]) (*model.Promotion, error)
+	ListPromotions() ([]model.Promotion, error)
+	UpdatePromotion(id model.ID[model.Promotion], promotion model.Promotion) (*model.Promotion, error)
+	DeletePromotion(id model.ID[model.Promotion]) error
+}
+
+// promotionService 促销活动服务实现
+type promotionService struct {
+	repo repository.PromotionRepository
+}
+
+func NewPromotionService(repo repository.PromotionRepository) PromotionService {
+	return &promotionService{repo: repo}
+}
+
+func (s *promotionService) CreatePromotion(promotion model.Promotion) (*model.Promotion, error) {
+	if err := validatePromotion(&promotion); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Create(&promotion); err != nil {
+		return nil, err
+	}
+	return &promotion, nil
+}
+
+func (s *promotionService) GetPromotion(id model.ID[model.Promotion]) (*model.Promotion, error) {
+	promotion, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, ErrPromotionNotFound
+	}
+	return promotion, nil
+}
+
+func (s *promotionService) ListPromotions() ([]model.Promotion, error) {
+	return s.repo.List()
+}
+
+func (s *promotionService) UpdatePromotion(id model.ID[model.Promotion], promotion model.Promotion) (*model.Promotion, error) {
+	if err := validatePromotion(&promotion); err != nil {
+		return nil, err
+	}
+
+	promotion.ID = id
+	err := s.repo.Update(&promotion)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPromotionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(id)
+}
+
+func (s *promotionService) DeletePromotion(id model.ID[model.Promotion]) error {
+	err := s.repo.Delete(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrPromotionNotFound
+	}
+	return err
+}
+
+func validatePromotion(promotion *model.Promotion) error {
+	if promotion.BuyQuantity < 1 || promotion.FreeQuantity < 1 {
+		return fmt.Errorf("%w: 购买数量和赠送数量必须大于 0", ErrInvalidPromotion)
+	}
+	if promotion.StartsAt != nil && promotion.EndsAt != nil && !promotion.EndsAt.After(*promotion.StartsAt) {
+		return fmt.Errorf("%w: 结束时间必须晚于开始时间", ErrInvalidPromotion)
+	}
+	return nil
+}
@@ -0,0 +1,269 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/internal/repository"
+	"gin-learn/phase4/pkg/logger"
+	"gin-learn/phase4/pkg/money"
+
+	"gorm.io/gorm"
+)
+
+// refundableStatuses 允许退款的订单状态，已取消的订单只有在支付成功过时才能退款
+var refundableStatuses = map[string]bool{
+	model.OrderStatusPaid:             true,
+	model.OrderStatusPartiallyShipped: true,
+	model.OrderStatusShipped:          true,
+	model.OrderStatusDelivered:        true,
+	model.OrderStatusCompleted:        true,
+	model.OrderStatusCancelled:        true,
+}
+
+// RefundItemInput 退款明细输入
+type RefundItemInput struct {
+	OrderItemID model.ID[model.OrderItem] `json:"order_item_id" binding:"required"`
+	Quantity    int                       `json:"quantity" binding:"required,min=1"`
+	Restock     bool                      `json:"restock"` // 已发货的商品是否退回库存，未发货的商品总是退回
+}
+
+// RefundInput 退款输入，Items 为空时退还订单剩余的全部金额和商品
+type RefundInput struct {
+	Items   []RefundItemInput
+	Restock bool // 全额退款时已发货的商品是否退回库存，未发货的商品总是退回
+	Reason  string
+}
+
+// RefundService 退款服务接口
+type RefundService interface {
+	CreateRefund(orderID model.ID[model.Order], actorID model.ID[model.User], input RefundInput) (*model.Refund, error)
+	ListRefunds(orderID model.ID[model.Order], userID model.ID[model.User], canManage bool) ([]model.Refund, error)
+}
+
+// refundService 退款服务实现
+type refundService struct {
+	repo      *repository.Repository
+	orders    OrderService
+	providers map[string]PaymentProvider
+}
+
+func NewRefundService(repo *repository.Repository, orders OrderService, providers ...PaymentProvider) RefundService {
+	s := &refundService{
+		repo:      repo,
+		orders:    orders,
+		providers: make(map[string]PaymentProvider, len(providers)),
+	}
+	for _, p := range providers {
+		s.providers[p.Name()] = p
+	}
+	return s
+}
+
+// CreateRefund 创建退款，订单全部退款后流转为已退款状态。
+// 先在事务中以待处理状态记录退款并占用可退数量和金额，提交后再调用支付渠道，
+// 渠道调用期间不持有数据库写锁；渠道成功后标记完成并退回库存，失败时标记失败并释放占用
+func (s *refundService) CreateRefund(orderID model.ID[model.Order], actorID model.ID[model.User], input RefundInput) (*model.Refund, error) {
+	if _, err := s.orders.GetOrder(orderID, actorID, true); err != nil {
+		return nil, err
+	}
+
+	var (
+		refund  *model.Refund
+		payment *model.Payment
+	)
+	err := s.repo.Transaction(func(tx *repository.Repository) error {
+		order, err := tx.Order.GetByID(orderID)
+		if err != nil {
+			return err
+		}
+		if !refundableStatuses[order.Status] {
+			return fmt.Errorf("%w: %s 状态的订单不能退款", ErrInvalidTransition, order.Status)
+		}
+
+		payment, err = tx.Payment.GetSucceededByOrder(order.ID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if payment == nil && order.Status == model.OrderStatusCancelled {
+			return fmt.Errorf("%w: 订单未支付", ErrInvalidTransition)
+		}
+
+		refund, err = newRefund(order, actorID, input)
+		if err != nil {
+			return err
+		}
+		return tx.Refund.Create(refund)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		paymentID *model.ID[model.Payment]
+		ref       string
+	)
+	if payment != nil {
+		provider, ok := s.providers[payment.Provider]
+		if !ok {
+			return nil, errors.Join(ErrPaymentProviderNotFound, s.repo.Refund.Fail(refund))
+		}
+		ref, err = provider.Refund(payment, refund.Amount)
+		if err != nil {
+			return nil, errors.Join(err, s.repo.Refund.Fail(refund))
+		}
+		paymentID = &payment.ID
+	}
+
+	err = s.repo.Transaction(func(tx *repository.Repository) error {
+		if err := tx.Refund.Complete(refund, paymentID, ref); err != nil {
+			return err
+		}
+		return s.settleOrder(tx, orderID, actorID, input.Reason)
+	})
+	if err != nil {
+		// 渠道已经退款，退款记录保持待处理状态，由人工根据渠道退款单号处理
+		logger.Error("Failed to complete refund after provider refund",
+			logger.Int("refund_id", int(refund.ID)),
+			logger.String("refund_ref", ref),
+			logger.ErrorField(err),
+		)
+		return nil, err
+	}
+
+	return refund, nil
+}
+
+// newRefund 按订单的当前数据计算退款明细和金额。退款后全部商品都已退款时退还订单剩余的全部金额，包括运费
+func newRefund(order *model.Order, actorID model.ID[model.User], input RefundInput) (*model.Refund, error) {
+	refund := &model.Refund{
+		OrderID: order.ID,
+		ActorID: actorID,
+		Reason:  input.Reason,
+	}
+	if len(input.Items) == 0 {
+		refund.Items = remainingRefundItems(order, input.Restock)
+		refund.Amount = order.Total - order.Refunded
+	} else {
+		var err error
+		refund.Items, err = refundItems(order, input.Items)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range refund.Items {
+			refund.Amount += item.Amount
+		}
+		if refundsAllItems(order, refund.Items) {
+			refund.Amount = order.Total - order.Refunded
+		}
+	}
+
+	if refund.Amount <= 0 {
+		return nil, fmt.Errorf("%w: 没有可退款的金额", repository.ErrRefundExceeded)
+	}
+	return refund, nil
+}
+
+// settleOrder 退款完成后更新订单状态：全部退款的订单流转为已退款，
+// 部分发货的订单退掉尚未发货的商品后流转为已发货
+func (s *refundService) settleOrder(tx *repository.Repository, orderID model.ID[model.Order], actorID model.ID[model.User], reason string) error {
+	order, err := tx.Order.GetByID(orderID)
+	if err != nil {
+		return err
+	}
+
+	if order.Refunded >= order.Total && canTransition(order.Status, model.OrderStatusRefunded) {
+		if reason == "" {
+			reason = "全额退款"
+		}
+		return tx.Order.TransitionStatus(order.ID, order.Status, model.OrderStatusRefunded, actorID, reason, false)
+	}
+
+	if order.Status == model.OrderStatusPartiallyShipped {
+		remaining, err := tx.Shipment.RemainingQuantity(order.ID)
+		if err != nil {
+			return err
+		}
+		if remaining == 0 {
+			return tx.Order.TransitionStatus(order.ID, order.Status, model.OrderStatusShipped, actorID, "未发货商品已退款", false)
+		}
+	}
+	return nil
+}
+
+// ListRefunds 获取订单的退款记录
+func (s *refundService) ListRefunds(orderID model.ID[model.Order], userID model.ID[model.User], canManage bool) ([]model.Refund, error) {
+	if _, err := s.orders.GetOrder(orderID, userID, canManage); err != nil {
+		return nil, err
+	}
+	return s.repo.Refund.ListByOrder(orderID)
+}
+
+// refundsAllItems 本次退款后订单的全部商品是否都已退款
+func refundsAllItems(order *model.Order, items []model.RefundItem) bool {
+	refunding := make(map[model.ID[model.OrderItem]]int, len(items))
+	for _, item := range items {
+		refunding[item.OrderItemID] += item.Quantity
+	}
+	for _, item := range order.Items {
+		if item.RefundedQuantity+refunding[item.ID] < item.Quantity {
+			return false
+		}
+	}
+	return true
+}
+
+// remainingRefundItems 订单中尚未退款的全部商品，restock 表示已发货的商品是否退回库存
+func remainingRefundItems(order *model.Order, restock bool) []model.RefundItem {
+	var items []model.RefundItem
+	for _, item := range order.Items {
+		quantity := item.Quantity - item.RefundedQuantity
+		if quantity <= 0 {
+			continue
+		}
+		items = append(items, model.RefundItem{
+			OrderItemID: item.ID,
+			Quantity:    quantity,
+			Amount:      refundAmount(item, quantity),
+			Restock:     restock,
+		})
+	}
+	return items
+}
+
+// refundItems 校验部分退款的订单项并计算每行退款金额
+func refundItems(order *model.Order, inputs []RefundItemInput) ([]model.RefundItem, error) {
+	orderItems := make(map[model.ID[model.OrderItem]]model.OrderItem, len(order.Items))
+	for _, item := range order.Items {
+		orderItems[item.ID] = item
+	}
+
+	items := make([]model.RefundItem, 0, len(inputs))
+	for _, input := range inputs {
+		item, ok := orderItems[input.OrderItemID]
+		if !ok {
+			return nil, fmt.Errorf("订单项不存在: %s", input.OrderItemID)
+		}
+		items = append(items, model.RefundItem{
+			OrderItemID: item.ID,
+			Quantity:    input.Quantity,
+			Amount:      refundAmount(item, input.Quantity),
+			Restock:     input.Restock,
+		})
+	}
+	return items, nil
+}
+
+// refundAmount 退还订单项 quantity 件商品的金额，订单项的优惠按数量比例扣除、税费按数量比例退还，
+// 按累计数量计算分摊，全部退完时正好扣除该订单项的全部优惠并退还全部税费
+func refundAmount(item model.OrderItem, quantity int) money.Amount {
+	return item.Price.Mul(quantity) - itemShare(item, item.Discount, quantity) + itemShare(item, item.Tax, quantity)
+}
+
+// itemShare 按累计退款数量计算本次 quantity 件商品分摊的订单项金额
+func itemShare(item model.OrderItem, amount money.Amount, quantity int) money.Amount {
+	before := amount.Mul(item.RefundedQuantity) / money.Amount(item.Quantity)
+	after := amount.Mul(item.RefundedQuantity+quantity) / money.Amount(item.Quantity)
+	return after - before
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/pkg/money"
+)
+
+// failingProvider 退款总是失败的模拟渠道
+type failingProvider struct {
+	*MockProvider
+}
+
+var errProviderDown = errors.New("provider unavailable")
+
+func (p failingProvider) Refund(payment *model.Payment, amount money.Amount) (string, error) {
+	return "", errProviderDown
+}
+
+// paid 通过回调将订单标记为已支付
+func (f *paymentFixture) paid(t *testing.T) {
+	t.Helper()
+
+	payload, signature := f.event(t, model.PaymentStatusSucceeded)
+	if err := f.payments.HandleWebhook("mock", payload, signature); err != nil {
+		t.Fatalf("HandleWebhook: %v", err)
+	}
+}
+
+func (f *paymentFixture) stock(t *testing.T) int {
+	t.Helper()
+
+	product, err := f.repo.Product.GetByID(f.product.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return product.Stock
+}
+
+func TestRefundUnshippedOrderRestocks(t *testing.T) {
+	f := newPaymentFixture(t)
+	f.paid(t)
+	refunds := NewRefundService(f.repo, f.orders, f.mock)
+
+	refund, err := refunds.CreateRefund(f.order.ID, f.order.UserID, RefundInput{})
+	if err != nil {
+		t.Fatalf("CreateRefund: %v", err)
+	}
+	if refund.Status != model.RefundStatusSucceeded || refund.RefundRef == "" || refund.Amount != f.order.Total {
+		t.Errorf("refund status %s ref %q amount %s, want succeeded with ref and %s", refund.Status, refund.RefundRef, refund.Amount, f.order.Total)
+	}
+	if order := f.reload(t); order.Status != model.OrderStatusRefunded {
+		t.Errorf("order status %s, want %s", order.Status, model.OrderStatusRefunded)
+	}
+	// 未发货的商品不需要 restock 也退回库存
+	if stock := f.stock(t); stock != 5 {
+		t.Errorf("product stock %d, want 5", stock)
+	}
+}
+
+func TestRefundAllItemsRefundsShipping(t *testing.T) {
+	f := newPaymentFixture(t)
+	f.paid(t)
+	refunds := NewRefundService(f.repo, f.orders, f.mock)
+
+	item := f.order.Items[0]
+	for i := 0; i < item.Quantity; i++ {
+		if _, err := refunds.CreateRefund(f.order.ID, f.order.UserID, RefundInput{
+			Items: []RefundItemInput{{OrderItemID: item.ID, Quantity: 1}},
+		}); err != nil {
+			t.Fatalf("refund %d: %v", i+1, err)
+		}
+	}
+
+	order := f.reload(t)
+	if order.Status != model.OrderStatusRefunded || order.Refunded != order.Total {
+		t.Errorf("order status %s refunded %s, want %s and %s", order.Status, order.Refunded, model.OrderStatusRefunded, order.Total)
+	}
+	if order.Shipping == 0 {
+		t.Error("fixture order has no shipping fee")
+	}
+}
+
+func TestRefundProviderFailureReleasesRefund(t *testing.T) {
+	f := newPaymentFixture(t)
+	f.paid(t)
+	refunds := NewRefundService(f.repo, f.orders, failingProvider{f.mock})
+
+	if _, err := refunds.CreateRefund(f.order.ID, f.order.UserID, RefundInput{}); !errors.Is(err, errProviderDown) {
+		t.Fatalf("CreateRefund err = %v, want provider error", err)
+	}
+
+	order := f.reload(t)
+	if order.Status != model.OrderStatusPaid || order.Refunded != 0 || order.Items[0].RefundedQuantity != 0 {
+		t.Errorf("order status %s refunded %s item refunded %d, want paid with nothing refunded",
+			order.Status, order.Refunded, order.Items[0].RefundedQuantity)
+	}
+	list, err := f.repo.Refund.ListByOrder(f.order.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Status != model.RefundStatusFailed {
+		t.Errorf("refunds %+v, want one failed refund", list)
+	}
+	if stock := f.stock(t); stock != 3 {
+		t.Errorf("product stock %d, want 3", stock)
+	}
+
+	// 渠道恢复后可以重新退款
+	refunds = NewRefundService(f.repo, f.orders, f.mock)
+	if _, err := refunds.CreateRefund(f.order.ID, f.order.UserID, RefundInput{}); err != nil {
+		t.Fatalf("retry CreateRefund: %v", err)
+	}
+	if stock := f.stock(t); stock != 5 {
+		t.Errorf("product stock after retry %d, want 5", stock)
+	}
+}
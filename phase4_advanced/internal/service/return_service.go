@@ -0,0 +1,212 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/internal/repository"
+	"gin-learn/phase4/pkg/money"
+)
+
+var (
+	ErrReturnNotFound   = errors.New("退货申请不存在")
+	ErrReturnNotAllowed = errors.New("订单当前状态不能申请退货")
+	ErrReturnTransition = errors.New("退货申请状态不允许该操作")
+)
+
+// returnableStatuses 允许申请退货的订单状态
+var returnableStatuses = map[string]bool{
+	model.OrderStatusDelivered: true,
+	model.OrderStatusCompleted: true,
+}
+
+// ReturnItemInput 退货明细输入
+type ReturnItemInput struct {
+	OrderItemID model.ID[model.OrderItem] `json:"order_item_id" binding:"required"`
+	Quantity    int                       `json:"quantity" binding:"required,min=1"`
+}
+
+// ReturnService 退货服务接口
+type ReturnService interface {
+	CreateReturn(orderID model.ID[model.Order], userID model.ID[model.User], canManage bool, reasonCode, description string, items []ReturnItemInput) (*model.ReturnRequest, error)
+	GetReturn(orderID model.ID[model.Order], returnID model.ID[model.ReturnRequest], userID model.ID[model.User], canManage bool) (*model.ReturnRequest, error)
+	ListReturns(orderID model.ID[model.Order], userID model.ID[model.User], canManage bool) ([]model.ReturnRequest, error)
+	ApproveReturn(orderID model.ID[model.Order], returnID model.ID[model.ReturnRequest], actorID model.ID[model.User], note string) (*model.ReturnRequest, error)
+	RejectReturn(orderID model.ID[model.Order], returnID model.ID[model.ReturnRequest], actorID model.ID[model.User], note string) (*model.ReturnRequest, error)
+	ReceiveReturn(orderID model.ID[model.Order], returnID model.ID[model.ReturnRequest], actorID model.ID[model.User], restock bool) (*model.ReturnRequest, error)
+}
+
+// returnService 退货服务实现
+type returnService struct {
+	repo    *repository.Repository
+	orders  OrderService
+	refunds RefundService
+}
+
+func NewReturnService(repo *repository.Repository, orders OrderService, refunds RefundService) ReturnService {
+	return &returnService{repo: repo, orders: orders, refunds: refunds}
+}
+
+// CreateReturn 为已送达或已完成的订单申请退货，仅订单所有者或拥有订单管理权限的用户可申请
+func (s *returnService) CreateReturn(orderID model.ID[model.Order], userID model.ID[model.User], canManage bool, reasonCode, description string, items []ReturnItemInput) (*model.ReturnRequest, error) {
+	order, err := s.orders.GetOrder(orderID, userID, canManage)
+	if err != nil {
+		return nil, err
+	}
+	if !returnableStatuses[order.Status] {
+		return nil, fmt.Errorf("%w: %s", ErrReturnNotAllowed, order.Status)
+	}
+
+	ret := &model.ReturnRequest{
+		OrderID:     order.ID,
+		UserID:      order.UserID,
+		ReasonCode:  reasonCode,
+		Description: description,
+		Status:      model.ReturnStatusRequested,
+	}
+	ret.Items = mergeReturnItems(items)
+
+	if err := s.repo.Return.Create(ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// mergeReturnItems 合并同一订单项的多行退货，保证按订单项累计数量校验可退货数量
+func mergeReturnItems(items []ReturnItemInput) []model.ReturnItem {
+	merged := make([]model.ReturnItem, 0, len(items))
+	index := make(map[model.ID[model.OrderItem]]int, len(items))
+	for _, item := range items {
+		if i, ok := index[item.OrderItemID]; ok {
+			merged[i].Quantity += item.Quantity
+			continue
+		}
+		index[item.OrderItemID] = len(merged)
+		merged = append(merged, model.ReturnItem{OrderItemID: item.OrderItemID, Quantity: item.Quantity})
+	}
+	return merged
+}
+
+// GetReturn 获取退货申请，仅订单所有者或拥有订单管理权限的用户可访问
+func (s *returnService) GetReturn(orderID model.ID[model.Order], returnID model.ID[model.ReturnRequest], userID model.ID[model.User], canManage bool) (*model.ReturnRequest, error) {
+	if _, err := s.orders.GetOrder(orderID, userID, canManage); err != nil {
+		return nil, err
+	}
+	return s.getReturn(orderID, returnID)
+}
+
+// ListReturns 获取订单的退货申请
+func (s *returnService) ListReturns(orderID model.ID[model.Order], userID model.ID[model.User], canManage bool) ([]model.ReturnRequest, error) {
+	if _, err := s.orders.GetOrder(orderID, userID, canManage); err != nil {
+		return nil, err
+	}
+	return s.repo.Return.ListByOrder(orderID)
+}
+
+// ApproveReturn 同意退货申请并按申请的商品计算退款金额，调用方需拥有订单管理权限
+func (s *returnService) ApproveReturn(orderID model.ID[model.Order], returnID model.ID[model.ReturnRequest], actorID model.ID[model.User], note string) (*model.ReturnRequest, error) {
+	order, err := s.orders.GetOrder(orderID, actorID, true)
+	if err != nil {
+		return nil, err
+	}
+	ret, err := s.getReturn(orderID, returnID)
+	if err != nil {
+		return nil, err
+	}
+	if ret.Status != model.ReturnStatusRequested {
+		return nil, fmt.Errorf("%w: %s 状态不能审核", ErrReturnTransition, ret.Status)
+	}
+
+	orderItems := make(map[model.ID[model.OrderItem]]model.OrderItem, len(order.Items))
+	for _, item := range order.Items {
+		orderItems[item.ID] = item
+	}
+	var amount money.Amount
+	for _, line := range ret.Items {
+		amount += refundAmount(orderItems[line.OrderItemID], line.Quantity)
+	}
+
+	err = s.repo.Return.Transition(ret.ID, model.ReturnStatusRequested, model.ReturnStatusApproved, map[string]interface{}{
+		"refund_amount": amount,
+		"reviewer_id":   actorID,
+		"review_note":   note,
+		"reviewed_at":   time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.getReturn(orderID, returnID)
+}
+
+// RejectReturn 拒绝尚未收货的退货申请，调用方需拥有订单管理权限
+func (s *returnService) RejectReturn(orderID model.ID[model.Order], returnID model.ID[model.ReturnRequest], actorID model.ID[model.User], note string) (*model.ReturnRequest, error) {
+	ret, err := s.getReturn(orderID, returnID)
+	if err != nil {
+		return nil, err
+	}
+	if ret.Status != model.ReturnStatusRequested && ret.Status != model.ReturnStatusApproved {
+		return nil, fmt.Errorf("%w: %s 状态不能拒绝", ErrReturnTransition, ret.Status)
+	}
+
+	err = s.repo.Return.Transition(ret.ID, ret.Status, model.ReturnStatusRejected, map[string]interface{}{
+		"reviewer_id": actorID,
+		"review_note": note,
+		"reviewed_at": time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.getReturn(orderID, returnID)
+}
+
+// ReceiveReturn 确认收到退回的商品并通过退款流程退款，restock 为 true 时商品通过库存流水退回库存。
+// 先以条件更新标记为已收货，保证同一申请只退款一次；退款失败时恢复为已同意
+func (s *returnService) ReceiveReturn(orderID model.ID[model.Order], returnID model.ID[model.ReturnRequest], actorID model.ID[model.User], restock bool) (*model.ReturnRequest, error) {
+	ret, err := s.getReturn(orderID, returnID)
+	if err != nil {
+		return nil, err
+	}
+	if ret.Status != model.ReturnStatusApproved {
+		return nil, fmt.Errorf("%w: %s 状态不能确认收货", ErrReturnTransition, ret.Status)
+	}
+
+	err = s.repo.Return.Transition(ret.ID, model.ReturnStatusApproved, model.ReturnStatusReceived, map[string]interface{}{
+		"restock":     restock,
+		"received_at": time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	input := RefundInput{Reason: fmt.Sprintf("退货申请 %s", ret.ID)}
+	for _, line := range ret.Items {
+		input.Items = append(input.Items, RefundItemInput{OrderItemID: line.OrderItemID, Quantity: line.Quantity, Restock: restock})
+	}
+	refund, err := s.refunds.CreateRefund(orderID, actorID, input)
+	if err != nil {
+		rollback := s.repo.Return.Transition(ret.ID, model.ReturnStatusReceived, model.ReturnStatusApproved, map[string]interface{}{
+			"received_at": nil,
+		})
+		return nil, errors.Join(err, rollback)
+	}
+
+	err = s.repo.Return.Transition(ret.ID, model.ReturnStatusReceived, model.ReturnStatusReceived, map[string]interface{}{
+		"refund_id":     refund.ID,
+		"refund_amount": refund.Amount,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.getReturn(orderID, returnID)
+}
+
+// getReturn 获取属于该订单的退货申请
+func (s *returnService) getReturn(orderID model.ID[model.Order], returnID model.ID[model.ReturnRequest]) (*model.ReturnRequest, error) {
+	ret, err := s.repo.Return.GetByID(returnID)
+	if err != nil || ret.OrderID != orderID {
+		return nil, ErrReturnNotFound
+	}
+	return ret, nil
+}
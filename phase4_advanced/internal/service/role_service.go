@@ -0,0 +1,181 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/internal/repository"
+)
+
+var (
+	ErrRoleNotFound   = errors.New("角色不存在")
+	ErrBuiltinRole    = errors.New("内置角色不能删除")
+	ErrRoleNameExists = errors.New("角色名已存在")
+	ErrAdminRoleLock  = errors.New("admin 角色必须保留角色管理权限")
+)
+
+// RoleService 角色服务接口
+type RoleService interface {
+	CreateRole(name, description string, permissions []string) (*model.Role, error)
+	UpdateRole(id model.ID[model.Role], description string, permissions []string) (*model.Role, error)
+	DeleteRole(id model.ID[model.Role]) error
+	ListRoles() ([]model.Role, error)
+	ListPermissions() ([]model.Permission, error)
+	AssignRoles(userID model.ID[model.User], roleNames []string) error
+	GrantRole(username, roleName string) error
+}
+
+// roleService 角色服务实现
+type roleService struct {
+	repo     repository.RoleRepository
+	userRepo repository.UserRepository
+}
+
+func NewRoleService(repo repository.RoleRepository, userRepo repository.UserRepository) RoleService {
+	return &roleService{repo: repo, userRepo: userRepo}
+}
+
+func (s *roleService) CreateRole(name, description string, permissions []string) (*model.Role, error) {
+	if roles, err := s.repo.GetByNames([]string{name}); err == nil && len(roles) > 0 {
+		return nil, ErrRoleNameExists
+	}
+
+	perms, err := s.resolvePermissions(permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	role := &model.Role{
+		Name:        name,
+		Description: description,
+		Permissions: perms,
+	}
+	if err := s.repo.Create(role); err != nil {
+		return nil, err
+	}
+
+	return role, nil
+}
+
+func (s *roleService) UpdateRole(id model.ID[model.Role], description string, permissions []string) (*model.Role, error) {
+	role, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, ErrRoleNotFound
+	}
+
+	// admin 角色失去 role:manage 后将无人能再修改权限
+	if role.Name == model.RoleAdmin && !slices.Contains(permissions, model.PermRoleManage) {
+		return nil, ErrAdminRoleLock
+	}
+
+	perms, err := s.resolvePermissions(permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	role.Description = description
+	role.Permissions = perms
+	if err := s.repo.Update(role); err != nil {
+		return nil, err
+	}
+
+	return role, nil
+}
+
+func (s *roleService) DeleteRole(id model.ID[model.Role]) error {
+	role, err := s.repo.GetByID(id)
+	if err != nil {
+		return ErrRoleNotFound
+	}
+
+	if role.Builtin {
+		return ErrBuiltinRole
+	}
+
+	return s.repo.Delete(id)
+}
+
+func (s *roleService) ListRoles() ([]model.Role, error) {
+	return s.repo.List()
+}
+
+func (s *roleService) ListPermissions() ([]model.Permission, error) {
+	return s.repo.ListPermissions()
+}
+
+// AssignRoles 用指定角色替换用户原有角色
+func (s *roleService) AssignRoles(userID model.ID[model.User], roleNames []string) error {
+	if _, err := s.userRepo.GetByID(userID); err != nil {
+		return errors.New("用户不存在")
+	}
+
+	roles, err := s.repo.GetByNames(roleNames)
+	if err != nil {
+		return err
+	}
+	if len(roles) != len(unique(roleNames)) {
+		return ErrRoleNotFound
+	}
+
+	return s.repo.SetUserRoles(userID, roles)
+}
+
+// GrantRole 为指定用户名追加角色，用于启动时初始化管理员
+func (s *roleService) GrantRole(username, roleName string) error {
+	user, err := s.userRepo.GetByUsername(username)
+	if err != nil {
+		return fmt.Errorf("用户不存在: %s", username)
+	}
+
+	roles, err := s.repo.GetByNames([]string{roleName})
+	if err != nil {
+		return err
+	}
+	if len(roles) == 0 {
+		return ErrRoleNotFound
+	}
+
+	return s.repo.AddUserRoles(user.ID, roles)
+}
+
+// resolvePermissions 根据权限编码查询权限，存在未知编码时返回错误
+func (s *roleService) resolvePermissions(codes []string) ([]model.Permission, error) {
+	if len(codes) == 0 {
+		return nil, nil
+	}
+
+	codes = unique(codes)
+	perms, err := s.repo.GetPermissionsByCodes(codes)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(perms) != len(codes) {
+		known := make(map[string]bool, len(perms))
+		for _, p := range perms {
+			known[p.Code] = true
+		}
+		for _, code := range codes {
+			if !known[code] {
+				return nil, fmt.Errorf("未知的权限: %s", code)
+			}
+		}
+	}
+
+	return perms, nil
+}
+
+// unique 去除重复值，保持原有顺序
+func unique[T comparable](values []T) []T {
+	seen := make(map[T]bool, len(values))
+	result := make([]T, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+	return result
+}
@@ -1,23 +1,65 @@
 package service
 
 import (
+	"time"
+
+	"gin-learn/phase4/config"
 	"gin-learn/phase4/internal/repository"
 )
 
 // Service 服务层入口
 type Service struct {
-	User     UserService
-	Product  ProductService
-	Category CategoryService
-	Order    OrderService
+	User        UserService
+	Product     ProductService
+	Category    CategoryService
+	Order       OrderService
+	Auth        AuthService
+	Role        RoleService
+	Idempotency IdempotencyService
+	Cart        CartService
+	Address     AddressService
+	Payment     PaymentService
+	Refund      RefundService
+	Currency    CurrencyService
+	Coupon      CouponService
+	Promotion   PromotionService
+	Stock       StockService
+	Warehouse   WarehouseService
+	StockAlert  StockAlertService
+	Pricing     PricingService
+	Shipment    ShipmentService
+	Return      ReturnService
 }
 
 // NewService 创建服务实例
 func NewService(repo *repository.Repository) *Service {
+	userService := NewUserService(repo.User, repo.Role)
+	reservationTTL := time.Duration(config.C.Order.ReservationTTL) * time.Second
+	alertService := NewStockAlertService(repo.StockAlert, newStockAlertNotifiers(config.C.Alert)...)
+	orderService := NewOrderService(repo.Order, reservationTTL, alertService)
+	providers := []PaymentProvider{NewMockProvider(config.C.Payment.WebhookSecret)}
+	refundService := NewRefundService(repo, orderService, providers...)
+
 	return &Service{
-		User:     NewUserService(repo.User),
-		Product:  NewProductService(repo.Product),
-		Category: NewCategoryService(repo.Category),
-		Order:    NewOrderService(repo.Order),
+		User:        userService,
+		Product:     NewProductService(repo.Product, repo.Variant, repo.Warehouse, alertService),
+		Category:    NewCategoryService(repo.Category),
+		Order:       orderService,
+		Auth:        NewAuthService(userService, repo.User, repo.Token),
+		Role:        NewRoleService(repo.Role, repo.User),
+		Idempotency: NewIdempotencyService(repo.Idempotency, time.Duration(config.C.Idempotency.TTL)*time.Second),
+		Cart:        NewCartService(repo, reservationTTL, alertService),
+		Address:     NewAddressService(repo.Address),
+		Payment:     NewPaymentService(repo, orderService, config.C.Payment.Provider, providers...),
+		Refund:      refundService,
+		Currency:    NewCurrencyService(repo.ExchangeRate),
+		Coupon:      NewCouponService(repo.Coupon),
+		Promotion:   NewPromotionService(repo.Promotion),
+		Stock:       NewStockService(repo.Stock, repo.Warehouse, alertService),
+		Warehouse:   NewWarehouseService(repo),
+		StockAlert:  alertService,
+		Pricing:     NewPricingService(repo.ShippingZone, repo.TaxRate),
+		Shipment:    NewShipmentService(repo, orderService),
+		Return:      NewReturnService(repo, orderService, refundService),
 	}
 }
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+var ErrShipmentNotFound = errors.New("发货单不存在")
+
+// shippableStatuses 允许发货的订单状态
+var shippableStatuses = map[string]bool{
+	model.OrderStatusPaid:             true,
+	model.OrderStatusPartiallyShipped: true,
+}
+
+// ShipmentItemInput 发货明细输入
+type ShipmentItemInput struct {
+	OrderItemID model.ID[model.OrderItem] `json:"order_item_id" binding:"required"`
+	Quantity    int                       `json:"quantity" binding:"required,min=1"`
+}
+
+// ShipmentInput 发货输入，Items 为空时发出全部尚未发货的商品
+type ShipmentInput struct {
+	WarehouseID    model.ID[model.Warehouse] // 发货仓库，0 表示不指定
+	Carrier        string
+	TrackingNumber string
+	Note           string
+	Items          []ShipmentItemInput
+}
+
+// ShipmentService 发货服务接口
+type ShipmentService interface {
+	CreateShipment(orderID model.ID[model.Order], actorID model.ID[model.User], input ShipmentInput) (*model.Shipment, error)
+	ListShipments(orderID model.ID[model.Order], userID model.ID[model.User], canManage bool) ([]model.Shipment, error)
+	UpdateTracking(orderID model.ID[model.Order], shipmentID model.ID[model.Shipment], carrier, trackingNumber string) (*model.Shipment, error)
+}
+
+// shipmentService 发货服务实现
+type shipmentService struct {
+	repo   *repository.Repository
+	orders OrderService
+}
+
+func NewShipmentService(repo *repository.Repository, orders OrderService) ShipmentService {
+	return &shipmentService{repo: repo, orders: orders}
+}
+
+// CreateShipment 创建发货单，调用方需拥有订单管理权限。
+// 全部商品发出后订单流转为已发货，否则流转为部分发货
+func (s *shipmentService) CreateShipment(orderID model.ID[model.Order], actorID model.ID[model.User], input ShipmentInput) (*model.Shipment, error) {
+	order, err := s.orders.GetOrder(orderID, actorID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if !shippableStatuses[order.Status] {
+		return nil, fmt.Errorf("%w: %s 状态的订单不能发货", ErrInvalidTransition, order.Status)
+	}
+	if input.WarehouseID != 0 {
+		if _, err := s.repo.Warehouse.GetByID(input.WarehouseID); err != nil {
+			return nil, ErrWarehouseNotFound
+		}
+	}
+
+	shipment := &model.Shipment{
+		OrderID:        order.ID,
+		WarehouseID:    input.WarehouseID,
+		Carrier:        strings.TrimSpace(input.Carrier),
+		TrackingNumber: strings.TrimSpace(input.TrackingNumber),
+		ActorID:        actorID,
+		Note:           input.Note,
+	}
+	if len(input.Items) == 0 {
+		shipment.Items = remainingShipmentItems(order, input.WarehouseID)
+	} else {
+		shipment.Items = mergeShipmentItems(input.Items)
+	}
+	if len(shipment.Items) == 0 {
+		return nil, fmt.Errorf("%w: 没有可发货的商品", repository.ErrShipmentExceeded)
+	}
+
+	err = s.repo.Transaction(func(tx *repository.Repository) error {
+		if err := tx.Shipment.Create(shipment); err != nil {
+			return err
+		}
+
+		remaining, err := tx.Shipment.RemainingQuantity(order.ID)
+		if err != nil {
+			return err
+		}
+		to, reason := model.OrderStatusShipped, "全部发货"
+		if remaining > 0 {
+			to, reason = model.OrderStatusPartiallyShipped, "部分发货"
+		}
+		if input.Note != "" {
+			reason = input.Note
+		}
+		if to == order.Status {
+			return nil
+		}
+		return tx.Order.TransitionStatus(order.ID, order.Status, to, actorID, reason, false)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return shipment, nil
+}
+
+// ListShipments 获取订单的发货单，仅订单所有者或拥有订单管理权限的用户可访问
+func (s *shipmentService) ListShipments(orderID model.ID[model.Order], userID model.ID[model.User], canManage bool) ([]model.Shipment, error) {
+	if _, err := s.orders.GetOrder(orderID, userID, canManage); err != nil {
+		return nil, err
+	}
+	return s.repo.Shipment.ListByOrder(orderID)
+}
+
+// UpdateTracking 补充或修改发货单的承运商和运单号
+func (s *shipmentService) UpdateTracking(orderID model.ID[model.Order], shipmentID model.ID[model.Shipment], carrier, trackingNumber string) (*model.Shipment, error) {
+	shipment, err := s.repo.Shipment.GetByID(shipmentID)
+	if err != nil || shipment.OrderID != orderID {
+		return nil, ErrShipmentNotFound
+	}
+
+	err = s.repo.Shipment.UpdateTracking(shipmentID, strings.TrimSpace(carrier), strings.TrimSpace(trackingNumber))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrShipmentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.Shipment.GetByID(shipmentID)
+}
+
+// mergeShipmentItems 合并同一订单项的多行发货，保证按订单项累计数量校验仓库分配和可发货数量
+func mergeShipmentItems(items []ShipmentItemInput) []model.ShipmentItem {
+	merged := make([]model.ShipmentItem, 0, len(items))
+	index := make(map[model.ID[model.OrderItem]]int, len(items))
+	for _, item := range items {
+		if i, ok := index[item.OrderItemID]; ok {
+			merged[i].Quantity += item.Quantity
+			continue
+		}
+		index[item.OrderItemID] = len(merged)
+		merged = append(merged, model.ShipmentItem{OrderItemID: item.OrderItemID, Quantity: item.Quantity})
+	}
+	return merged
+}
+
+// remainingShipmentItems 订单中尚未发货且未退款的全部商品。指定了仓库时只发出分配到该仓库的数量，
+// 没有仓库分配记录的旧订单不受限制
+func remainingShipmentItems(order *model.Order, warehouseID model.ID[model.Warehouse]) []model.ShipmentItem {
+	var items []model.ShipmentItem
+	for _, item := range order.Items {
+		quantity := item.Quantity - item.ShippedQuantity - item.RefundedQuantity
+		if warehouseID != 0 && len(item.Allocations) > 0 {
+			quantity = min(quantity, warehouseShippable(order, item, warehouseID))
+		}
+		if quantity <= 0 {
+			continue
+		}
+		items = append(items, model.ShipmentItem{OrderItemID: item.ID, Quantity: quantity})
+	}
+	return items
+}
+
+// warehouseShippable 订单项分配到指定仓库且尚未从该仓库发出的数量
+func warehouseShippable(order *model.Order, item model.OrderItem, warehouseID model.ID[model.Warehouse]) int {
+	quantity := 0
+	for _, allocation := range item.Allocations {
+		if allocation.WarehouseID == warehouseID {
+			quantity += allocation.Quantity
+		}
+	}
+	for _, shipment := range order.Shipments {
+		if shipment.WarehouseID != warehouseID {
+			continue
+		}
+		for _, line := range shipment.Items {
+			if line.OrderItemID == item.ID {
+				quantity -= line.Quantity
+			}
+		}
+	}
+	return quantity
+}
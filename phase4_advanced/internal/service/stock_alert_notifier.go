@@ -0,0 +1,151 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gin-learn/phase4/config"
+	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/pkg/logger"
+)
+
+// StockAlertNotifier 低库存告警通知方式，接入新的通知方式只需实现该接口并在 newStockAlertNotifiers 中注册
+type StockAlertNotifier interface {
+	// Name 通知方式名称，对应配置 alert.notifiers 中的值
+	Name() string
+	// Notify 发送告警，alert.Product 为触发告警的产品
+	Notify(alert *model.StockAlert) error
+}
+
+// newStockAlertNotifiers 按配置创建通知方式，未知的名称会被忽略并记录警告
+func newStockAlertNotifiers(cfg config.AlertConfig) []StockAlertNotifier {
+	var notifiers []StockAlertNotifier
+	for _, name := range cfg.Notifiers {
+		switch name {
+		case "log":
+			notifiers = append(notifiers, LogNotifier{})
+		case "webhook":
+			notifiers = append(notifiers, NewWebhookNotifier(cfg.WebhookURL, cfg.WebhookSecret, time.Duration(cfg.WebhookTimeout)*time.Second))
+		case "file":
+			notifiers = append(notifiers, NewFileNotifier(cfg.EmailFile, cfg.EmailTo))
+		default:
+			logger.Warn("Unknown stock alert notifier", logger.String("name", name))
+		}
+	}
+	return notifiers
+}
+
+// alertSubject 告警的一句话描述
+func alertSubject(alert *model.StockAlert) string {
+	return fmt.Sprintf("产品 %s（ID %s）可售库存 %d，低于补货点 %d",
+		alert.Product.Name, alert.ProductID, alert.Available, alert.ReorderPoint)
+}
+
+// LogNotifier 将告警写入应用日志
+type LogNotifier struct{}
+
+func (LogNotifier) Name() string {
+	return "log"
+}
+
+func (LogNotifier) Notify(alert *model.StockAlert) error {
+	logger.Warn("Low stock",
+		logger.Int("alert_id", int(alert.ID)),
+		logger.Int("product_id", int(alert.ProductID)),
+		logger.String("product", alert.Product.Name),
+		logger.Int("available", alert.Available),
+		logger.Int("reorder_point", alert.ReorderPoint),
+	)
+	return nil
+}
+
+// WebhookNotifier 以 JSON 形式 POST 告警，配置了密钥时在 X-Signature 头中携带请求体的 HMAC-SHA256 签名
+type WebhookNotifier struct {
+	url    string
+	secret []byte
+	client *http.Client
+}
+
+func NewWebhookNotifier(url, secret string, timeout time.Duration) *WebhookNotifier {
+	return &WebhookNotifier{url: url, secret: []byte(secret), client: &http.Client{Timeout: timeout}}
+}
+
+func (n *WebhookNotifier) Name() string {
+	return "webhook"
+}
+
+func (n *WebhookNotifier) Notify(alert *model.StockAlert) error {
+	if n.url == "" {
+		return errors.New("未配置 webhook 地址")
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{"event": "stock.low", "alert": alert})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, n.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(n.secret) > 0 {
+		mac := hmac.New(sha256.New, n.secret)
+		mac.Write(payload)
+		req.Header.Set("X-Signature", hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook 返回状态码 %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// FileNotifier 将告警以邮件格式追加写入文件，用于在没有邮件服务的环境中代替发送邮件
+type FileNotifier struct {
+	path string
+	to   string
+	mu   sync.Mutex
+}
+
+func NewFileNotifier(path, to string) *FileNotifier {
+	return &FileNotifier{path: path, to: to}
+}
+
+func (n *FileNotifier) Name() string {
+	return "file"
+}
+
+func (n *FileNotifier) Notify(alert *model.StockAlert) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(n.path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(n.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "To: %s\nSubject: [低库存告警] %s\nDate: %s\n\n%s，请及时补货。\n告警ID：%s\n\n",
+		n.to, alert.Product.Name, alert.CreatedAt.Format(time.RFC1123Z), alertSubject(alert), alert.ID)
+	return err
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"errors"
+
+	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/internal/repository"
+	"gin-learn/phase4/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+var ErrStockAlertNotFound = errors.New("告警不存在或已处理")
+
+// StockAlertService 低库存告警服务接口
+type StockAlertService interface {
+	Check(productIDs ...model.ID[model.Product])
+	ListAlerts(status string, page, pageSize int) ([]model.StockAlert, int64, error)
+	ResolveAlert(id model.ID[model.StockAlert], actorID model.ID[model.User]) error
+}
+
+// stockAlertService 低库存告警服务实现
+type stockAlertService struct {
+	repo      repository.StockAlertRepository
+	notifiers []StockAlertNotifier
+}
+
+func NewStockAlertService(repo repository.StockAlertRepository, notifiers ...StockAlertNotifier) StockAlertService {
+	return &stockAlertService{repo: repo, notifiers: notifiers}
+}
+
+// Check 在库存减少后检查产品是否低于补货点，新产生的告警在后台发送通知。
+// 检查失败只记录日志，不影响已经完成的下单或库存调整
+func (s *stockAlertService) Check(productIDs ...model.ID[model.Product]) {
+	alerts, err := s.repo.Check(productIDs)
+	if err != nil {
+		logger.Error("Failed to check stock alerts", logger.ErrorField(err))
+		return
+	}
+
+	for i := range alerts {
+		go s.notify(&alerts[i])
+	}
+}
+
+// ListAlerts 分页获取告警，status 为空时返回全部状态
+func (s *stockAlertService) ListAlerts(status string, page, pageSize int) ([]model.StockAlert, int64, error) {
+	return s.repo.List(status, page, pageSize)
+}
+
+// ResolveAlert 手动关闭告警，例如已经下了采购单
+func (s *stockAlertService) ResolveAlert(id model.ID[model.StockAlert], actorID model.ID[model.User]) error {
+	err := s.repo.Resolve(id, actorID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrStockAlertNotFound
+	}
+	return err
+}
+
+// notify 依次调用各通知方式，单个通知失败不影响其他通知
+func (s *stockAlertService) notify(alert *model.StockAlert) {
+	for _, n := range s.notifiers {
+		if err := n.Notify(alert); err != nil {
+			logger.Error("Failed to send stock alert",
+				logger.String("notifier", n.Name()),
+				logger.Int("alert_id", int(alert.ID)),
+				logger.ErrorField(err),
+			)
+		}
+	}
+}
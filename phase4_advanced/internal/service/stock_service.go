@@ -0,0 +1,66 @@
+package service
+
+import (
+	"errors"
+
+	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+var ErrStockNotEditable = errors.New("库存不能直接修改，请使用库存调整接口")
+
+// StockService 库存服务接口
+type StockService interface {
+	AdjustStock(productID model.ID[model.Product], variantID model.ID[model.ProductVariant], warehouseID model.ID[model.Warehouse], actorID model.ID[model.User], delta int, note string) (*model.StockMovement, error)
+	ListMovements(productID model.ID[model.Product], warehouseID model.ID[model.Warehouse], page, pageSize int) ([]model.StockMovement, int64, error)
+}
+
+// stockService 库存服务实现
+type stockService struct {
+	repo       repository.StockRepository
+	warehouses repository.WarehouseRepository
+	alerts     StockAlertService
+}
+
+func NewStockService(repo repository.StockRepository, warehouses repository.WarehouseRepository, alerts StockAlertService) StockService {
+	return &stockService{repo: repo, warehouses: warehouses, alerts: alerts}
+}
+
+// AdjustStock 手动调整产品（或规格）在仓库中的现有库存，delta 为正数时入库、负数时出库，调整后的库存不能小于保留数量。
+// warehouseID 为 0 时调整默认仓库，有规格的产品入库时必须指定 variantID
+func (s *stockService) AdjustStock(productID model.ID[model.Product], variantID model.ID[model.ProductVariant], warehouseID model.ID[model.Warehouse], actorID model.ID[model.User], delta int, note string) (*model.StockMovement, error) {
+	if warehouseID != 0 {
+		if _, err := s.warehouses.GetByID(warehouseID); err != nil {
+			return nil, ErrWarehouseNotFound
+		}
+	}
+
+	movement := &model.StockMovement{
+		ProductID:   productID,
+		VariantID:   variantID,
+		WarehouseID: warehouseID,
+		Delta:       delta,
+		Reason:      model.StockReasonAdjustment,
+		ActorID:     actorID,
+		Note:        note,
+	}
+
+	err := s.repo.Apply(movement)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrProductNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 出库后可能低于补货点，入库后可能恢复，两种情况都需要检查
+	s.alerts.Check(productID)
+	return movement, nil
+}
+
+// ListMovements 分页获取产品的库存流水，warehouseID 为 0 时返回全部仓库的流水
+func (s *stockService) ListMovements(productID model.ID[model.Product], warehouseID model.ID[model.Warehouse], page, pageSize int) ([]model.StockMovement, int64, error) {
+	return s.repo.ListMovements(productID, warehouseID, page, pageSize)
+}
@@ -1,28 +1,45 @@
 package service
 
 import (
+	"crypto/subtle"
 	"errors"
 
 	"gin-learn/phase4/internal/model"
 	"gin-learn/phase4/internal/repository"
+	"gin-learn/phase4/pkg/logger"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrWrongPassword   = errors.New("原密码错误")
+	ErrPasswordInBody  = errors.New("请通过修改密码接口更新密码")
+	ErrPasswordTooLong = errors.New("密码长度不能超过72字节")
 )
 
+// dummyHash 用户不存在时参与比较，使响应时间与用户存在时一致
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+
 // UserService 用户服务接口
 type UserService interface {
 	Register(username, email, password string, age int) (*model.User, error)
-	GetUser(id uint) (*model.User, error)
+	Login(username, password string) (*model.User, error)
+	ChangePassword(id model.ID[model.User], oldPassword, newPassword string) error
+	MigrateLegacyPasswords() (int, error)
+	GetUser(id model.ID[model.User]) (*model.User, error)
 	ListUsers(page, pageSize int, keyword string) ([]model.User, int64, error)
-	UpdateUser(id uint, updates map[string]interface{}) error
-	DeleteUser(id uint) error
+	UpdateUser(id model.ID[model.User], updates map[string]interface{}) error
+	DeleteUser(id model.ID[model.User]) error
 }
 
 // userService 用户服务实现
 type userService struct {
-	repo repository.UserRepository
+	repo     repository.UserRepository
+	roleRepo repository.RoleRepository
 }
 
-func NewUserService(repo repository.UserRepository) UserService {
-	return &userService{repo: repo}
+func NewUserService(repo repository.UserRepository, roleRepo repository.RoleRepository) UserService {
+	return &userService{repo: repo, roleRepo: roleRepo}
 }
 
 func (s *userService) Register(username, email, password string, age int) (*model.User, error) {
@@ -31,12 +48,24 @@ func (s *userService) Register(username, email, password string, age int) (*mode
 		return nil, errors.New("用户名已存在")
 	}
 
+	hash, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	// 新用户默认为普通用户角色
+	roles, err := s.roleRepo.GetByNames([]string{model.RoleCustomer})
+	if err != nil {
+		return nil, err
+	}
+
 	user := &model.User{
 		Username: username,
 		Email:    email,
-		Password: password, // 实际应该加密
+		Password: hash,
 		Age:      age,
 		Status:   1,
+		Roles:    roles,
 	}
 
 	if err := s.repo.Create(user); err != nil {
@@ -46,7 +75,80 @@ func (s *userService) Register(username, email, password string, age int) (*mode
 	return user, nil
 }
 
-func (s *userService) GetUser(id uint) (*model.User, error) {
+func (s *userService) Login(username, password string) (*model.User, error) {
+	user, err := s.repo.GetByUsername(username)
+	if err != nil {
+		_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+
+	if !isHashed(user.Password) {
+		// 历史明文密码：校验通过后立即重新加密
+		if subtle.ConstantTimeCompare([]byte(user.Password), []byte(password)) != 1 {
+			return nil, ErrInvalidCredentials
+		}
+		s.rehash(user.ID, password)
+		return user, nil
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	return user, nil
+}
+
+func (s *userService) ChangePassword(id model.ID[model.User], oldPassword, newPassword string) error {
+	user, err := s.repo.GetByID(id)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.Login(user.Username, oldPassword); err != nil {
+		return ErrWrongPassword
+	}
+
+	hash, err := hashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	return s.repo.UpdatePassword(id, hash)
+}
+
+// MigrateLegacyPasswords 将历史明文密码全部替换为 bcrypt 哈希，返回处理的用户数
+// 已加密的记录不会被查询到，因此重复执行是安全的
+func (s *userService) MigrateLegacyPasswords() (int, error) {
+	users, err := s.repo.ListLegacyPasswords()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, user := range users {
+		hash, err := hashPassword(user.Password)
+		if err != nil {
+			return 0, err
+		}
+		if err := s.repo.UpdatePassword(user.ID, hash); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(users), nil
+}
+
+// rehash 登录成功后替换明文密码，失败只记录日志，不影响本次登录
+func (s *userService) rehash(id model.ID[model.User], password string) {
+	hash, err := hashPassword(password)
+	if err == nil {
+		err = s.repo.UpdatePassword(id, hash)
+	}
+	if err != nil {
+		logger.Error("Failed to rehash legacy password", logger.Int("user_id", int(id)), logger.ErrorField(err))
+	}
+}
+
+func (s *userService) GetUser(id model.ID[model.User]) (*model.User, error) {
 	return s.repo.GetByID(id)
 }
 
@@ -54,9 +156,11 @@ func (s *userService) ListUsers(page, pageSize int, keyword string) ([]model.Use
 	return s.repo.List(page, pageSize, keyword)
 }
 
-func (s *userService) UpdateUser(id uint, updates map[string]interface{}) error {
-	// 不允许更新密码（简化处理）
-	delete(updates, "password")
+func (s *userService) UpdateUser(id model.ID[model.User], updates map[string]interface{}) error {
+	// 密码只能通过 ChangePassword 修改
+	if _, ok := updates["password"]; ok {
+		return ErrPasswordInBody
+	}
 
 	user, err := s.repo.GetByID(id)
 	if err != nil {
@@ -77,6 +181,22 @@ func (s *userService) UpdateUser(id uint, updates map[string]interface{}) error
 	return s.repo.Update(user)
 }
 
-func (s *userService) DeleteUser(id uint) error {
+func (s *userService) DeleteUser(id model.ID[model.User]) error {
 	return s.repo.Delete(id)
 }
+
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		return "", ErrPasswordTooLong
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func isHashed(password string) bool {
+	_, err := bcrypt.Cost([]byte(password))
+	return err == nil
+}
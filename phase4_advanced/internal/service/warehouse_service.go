@@ -0,0 +1,128 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gin-learn/phase4/internal/model"
+	"gin-learn/phase4/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrWarehouseNotFound = errors.New("仓库不存在")
+	ErrWarehouseExists   = errors.New("仓库编码已存在")
+	ErrInvalidWarehouse  = errors.New("无效的仓库")
+)
+
+// WarehouseService 仓库服务接口
+type WarehouseService interface {
+	CreateWarehouse(warehouse model.Warehouse) (*model.Warehouse, error)
+	GetWarehouse(id model.ID[model.Warehouse]) (*model.Warehouse, error)
+	ListWarehouses() ([]model.Warehouse, error)
+	UpdateWarehouse(id model.ID[model.Warehouse], warehouse model.Warehouse) (*model.Warehouse, error)
+	ListStocks(warehouseID model.ID[model.Warehouse], page, pageSize int) ([]model.WarehouseStock, int64, error)
+	Transfer(transfer model.StockTransfer) (*model.StockTransfer, error)
+}
+
+// warehouseService 仓库服务实现
+type warehouseService struct {
+	repo *repository.Repository
+}
+
+func NewWarehouseService(repo *repository.Repository) WarehouseService {
+	return &warehouseService{repo: repo}
+}
+
+func (s *warehouseService) CreateWarehouse(warehouse model.Warehouse) (*model.Warehouse, error) {
+	warehouse.Code = NormalizeWarehouseCode(warehouse.Code)
+	if err := s.validate(0, &warehouse); err != nil {
+		return nil, err
+	}
+
+	// 默认仓库只有一个，在初始化数据库时创建
+	warehouse.IsDefault = false
+	if err := s.repo.Warehouse.Create(&warehouse); err != nil {
+		return nil, err
+	}
+	return &warehouse, nil
+}
+
+func (s *warehouseService) GetWarehouse(id model.ID[model.Warehouse]) (*model.Warehouse, error) {
+	warehouse, err := s.repo.Warehouse.GetByID(id)
+	if err != nil {
+		return nil, ErrWarehouseNotFound
+	}
+	return warehouse, nil
+}
+
+func (s *warehouseService) ListWarehouses() ([]model.Warehouse, error) {
+	return s.repo.Warehouse.List()
+}
+
+func (s *warehouseService) UpdateWarehouse(id model.ID[model.Warehouse], warehouse model.Warehouse) (*model.Warehouse, error) {
+	warehouse.Code = NormalizeWarehouseCode(warehouse.Code)
+	if err := s.validate(id, &warehouse); err != nil {
+		return nil, err
+	}
+
+	warehouse.ID = id
+	err := s.repo.Warehouse.Update(&warehouse)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrWarehouseNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.Warehouse.GetByID(id)
+}
+
+// ListStocks 分页获取仓库中各产品的库存
+func (s *warehouseService) ListStocks(warehouseID model.ID[model.Warehouse], page, pageSize int) ([]model.WarehouseStock, int64, error) {
+	if _, err := s.GetWarehouse(warehouseID); err != nil {
+		return nil, 0, err
+	}
+	return s.repo.Warehouse.ListStocks(warehouseID, page, pageSize)
+}
+
+// Transfer 将产品库存从一个仓库调拨到另一个仓库，只能调拨未被待支付订单保留的库存
+func (s *warehouseService) Transfer(transfer model.StockTransfer) (*model.StockTransfer, error) {
+	if transfer.Quantity < 1 {
+		return nil, fmt.Errorf("%w: 调拨数量必须大于 0", ErrInvalidWarehouse)
+	}
+	if _, err := s.repo.Product.GetByID(transfer.ProductID); err != nil {
+		return nil, ErrProductNotFound
+	}
+	for _, id := range []model.ID[model.Warehouse]{transfer.FromWarehouseID, transfer.ToWarehouseID} {
+		if _, err := s.GetWarehouse(id); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.repo.Warehouse.Transfer(&transfer); err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+func (s *warehouseService) validate(id model.ID[model.Warehouse], warehouse *model.Warehouse) error {
+	if warehouse.Code == "" || strings.TrimSpace(warehouse.Name) == "" {
+		return fmt.Errorf("%w: 编码和名称不能为空", ErrInvalidWarehouse)
+	}
+
+	existing, err := s.repo.Warehouse.GetByCode(warehouse.Code)
+	if err == nil && existing.ID != id {
+		return ErrWarehouseExists
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	return nil
+}
+
+// NormalizeWarehouseCode 仓库编码不区分大小写，统一保存为大写
+func NormalizeWarehouseCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
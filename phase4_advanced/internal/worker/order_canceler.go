@@ -0,0 +1,75 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"gin-learn/phase4/internal/service"
+	"gin-learn/phase4/pkg/logger"
+)
+
+// batchSize 每次查询的订单数量
+const batchSize = 100
+
+// OrderCanceler 定期取消超时未支付和库存保留已过期的订单，释放其占用的库存
+type OrderCanceler struct {
+	orders   service.OrderService
+	ttl      time.Duration
+	interval time.Duration
+}
+
+// NewOrderCanceler 创建订单取消任务，ttl 为 0 时只处理库存保留已过期的订单
+func NewOrderCanceler(orders service.OrderService, ttl, interval time.Duration) *OrderCanceler {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	return &OrderCanceler{orders: orders, ttl: ttl, interval: interval}
+}
+
+// Run 每隔 interval 检查一次超时订单，直到 ctx 被取消
+func (w *OrderCanceler) Run(ctx context.Context) {
+	logger.Info("Order canceler started",
+		logger.String("ttl", w.ttl.String()),
+		logger.String("interval", w.interval.String()),
+	)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.sweep(ctx)
+
+		select {
+		case <-ctx.Done():
+			logger.Info("Order canceler stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sweep 先取消库存保留已过期的订单，再取消超时未支付的订单
+func (w *OrderCanceler) sweep(ctx context.Context) {
+	w.cancel(ctx, "reservation expired", w.orders.ReleaseExpiredReservations)
+
+	if w.ttl > 0 {
+		w.cancel(ctx, "payment timeout", func(n int) (int, error) {
+			return w.orders.CancelExpired(w.ttl, n)
+		})
+	}
+}
+
+// cancel 执行一次取消，服务按ID分页处理全部到期订单，取消失败的订单跳过，下次检查时重试
+func (w *OrderCanceler) cancel(ctx context.Context, reason string, cancel func(batchSize int) (int, error)) {
+	if ctx.Err() != nil {
+		return
+	}
+
+	cancelled, err := cancel(batchSize)
+	if err != nil {
+		logger.Error("Failed to cancel expired orders", logger.String("reason", reason), logger.ErrorField(err))
+	}
+	if cancelled > 0 {
+		logger.Info("Expired orders cancelled", logger.String("reason", reason), logger.Int("count", cancelled))
+	}
+}
@@ -1,13 +1,24 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"gin-learn/phase4/config"
 	"gin-learn/phase4/internal/api"
+	"gin-learn/phase4/internal/model"
 	"gin-learn/phase4/internal/repository"
 	"gin-learn/phase4/internal/service"
+	"gin-learn/phase4/internal/worker"
 	"gin-learn/phase4/pkg/logger"
+	"gin-learn/phase4/pkg/publicid"
+	"gin-learn/phase4/pkg/snowflake"
+	"gin-learn/phase4/pkg/token"
 )
 
 func main() {
@@ -25,6 +36,29 @@ func main() {
 		logger.String("version", config.C.App.Version),
 	)
 
+	// 初始化JWT
+	if config.C.JWT.Secret == "" {
+		logger.Fatal("jwt.secret is required")
+	}
+	token.Init(
+		config.C.JWT.Secret,
+		time.Duration(config.C.JWT.Expire)*time.Second,
+		time.Duration(config.C.JWT.RefreshExpire)*time.Second,
+	)
+
+	if config.C.Payment.WebhookSecret == "" {
+		logger.Fatal("payment.webhook_secret is required")
+	}
+
+	// 初始化对外ID编码和订单号生成器
+	if config.C.PublicID.Secret == "" {
+		logger.Fatal("public_id.secret is required")
+	}
+	publicid.Init(config.C.PublicID.Secret)
+	if err := snowflake.Init(int64(config.C.Order.NodeID)); err != nil {
+		logger.Fatal("Invalid order.node_id", logger.ErrorField(err))
+	}
+
 	// 初始化数据库
 	db, err := repository.InitDB()
 	if err != nil {
@@ -34,12 +68,58 @@ func main() {
 	// 初始化仓库
 	repo := repository.NewRepository(db)
 
+	// 清理过期的令牌和幂等键记录
+	if err := repo.Token.PurgeExpired(); err != nil {
+		logger.Error("Failed to purge expired tokens", logger.ErrorField(err))
+	}
+	if err := repo.Idempotency.PurgeExpired(); err != nil {
+		logger.Error("Failed to purge expired idempotency keys", logger.ErrorField(err))
+	}
+
 	// 初始化服务
 	svc := service.NewService(repo)
 
+	// 迁移历史明文密码
+	migrated, err := svc.User.MigrateLegacyPasswords()
+	if err != nil {
+		logger.Fatal("Failed to migrate legacy passwords", logger.ErrorField(err))
+	}
+	if migrated > 0 {
+		logger.Info("Legacy passwords rehashed", logger.Int("count", migrated))
+	}
+
+	// 初始化管理员
+	if name := config.C.RBAC.AdminUsername; name != "" {
+		if err := svc.Role.GrantRole(name, model.RoleAdmin); err != nil {
+			logger.Error("Failed to grant admin role", logger.String("username", name), logger.ErrorField(err))
+		}
+	}
+
+	// 收到退出信号时停止后台任务和HTTP服务器
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// 启动超时订单取消任务，同时释放过期的库存保留
+	var wg sync.WaitGroup
+	if config.C.Order.PendingTTL > 0 || config.C.Order.ReservationTTL > 0 {
+		canceler := worker.NewOrderCanceler(svc.Order,
+			time.Duration(config.C.Order.PendingTTL)*time.Second,
+			time.Duration(config.C.Order.SweepInterval)*time.Second,
+		)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			canceler.Run(ctx)
+		}()
+	}
+
 	// 启动HTTP服务器
 	server := api.NewServer(svc)
-	if err := server.Run(config.C.Server.Port); err != nil {
+	if err := server.Run(ctx, config.C.Server.Port); err != nil {
 		logger.Fatal("Server failed to start", logger.ErrorField(err))
 	}
+
+	stop()
+	wg.Wait()
+	logger.Info("Application stopped")
 }
@@ -52,6 +52,10 @@ func Info(msg string, fields ...zap.Field) {
 	log.Info(msg, fields...)
 }
 
+func Warn(msg string, fields ...zap.Field) {
+	log.Warn(msg, fields...)
+}
+
 func Error(msg string, fields ...zap.Field) {
 	log.Error(msg, fields...)
 }
@@ -0,0 +1,174 @@
+// Package money 金额类型
+//
+// Amount 以最小货币单位（分）的整数保存金额，加减和乘以数量都是精确的整数运算，
+// 不会出现 0.1 + 0.2 != 0.3 这类浮点误差。
+//
+// 舍入规则：
+//   - 解析字符串或转换 float64 时保留两位小数，第三位起按四舍五入（远离零方向）处理，如 1.005 -> 1.01、-1.005 -> -1.01
+//   - 乘以数量是精确运算，不涉及舍入
+//   - 按比例计算（折扣、汇率等）使用 MulRate 或 MulPercent，结果同样按四舍五入到分
+//   - 汇率的倒数由 InvertRate 计算，保留 10 位小数
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Amount 金额，单位为分
+type Amount int64
+
+// Scale 每个货币单位包含的最小单位数量
+const Scale = 100
+
+var (
+	// ErrInvalidAmount 金额格式错误
+	ErrInvalidAmount = errors.New("无效的金额")
+	// ErrInvalidRate 比例或汇率格式错误
+	ErrInvalidRate = errors.New("无效的汇率")
+)
+
+var (
+	// currencyPattern ISO 4217 货币代码
+	currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+	// decimalPattern 普通十进制数，不接受分数、指数和十六进制等 big.Rat 支持的其他写法
+	decimalPattern = regexp.MustCompile(`^-?\d+(\.\d+)?$`)
+)
+
+// ValidCurrency 判断是否为三位大写字母的货币代码，如 CNY、USD
+func ValidCurrency(code string) bool {
+	return currencyPattern.MatchString(code)
+}
+
+// FromFloat 将浮点数金额转换为 Amount，按四舍五入保留两位小数，仅用于兼容旧数据和松散类型的输入
+func FromFloat(f float64) Amount {
+	// 先格式化为十进制字符串再解析，避免 1.005 这类在二进制中略小于真实值的数被舍去
+	a, err := Parse(strconv.FormatFloat(f, 'f', -1, 64))
+	if err != nil {
+		return Amount(math.Round(f * Scale))
+	}
+	return a
+}
+
+// Parse 解析十进制金额字符串，如 "12"、"12.5"、"-0.01"
+func Parse(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	if !decimalPattern.MatchString(s) {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+	return fromRat(r.Mul(r, big.NewRat(Scale, 1)))
+}
+
+// fromRat 将以分为单位的有理数四舍五入为整数
+func fromRat(r *big.Rat) (Amount, error) {
+	num := new(big.Int).Abs(r.Num())
+	den := r.Denom()
+
+	// |r| + 1/2 向下取整即为远离零方向的四舍五入
+	q, m := new(big.Int).QuoRem(num, den, new(big.Int))
+	if new(big.Int).Mul(m, big.NewInt(2)).Cmp(den) >= 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	if !q.IsInt64() {
+		return 0, fmt.Errorf("%w: 金额超出范围", ErrInvalidAmount)
+	}
+
+	if r.Sign() < 0 {
+		return Amount(-q.Int64()), nil
+	}
+	return Amount(q.Int64()), nil
+}
+
+// Mul 金额乘以数量
+func (a Amount) Mul(quantity int) Amount {
+	return a * Amount(quantity)
+}
+
+// MulRate 金额乘以比例（如 "0.85"、汇率 "7.1234"），结果四舍五入到分
+func (a Amount) MulRate(rate string) (Amount, error) {
+	r, err := ParseRate(rate)
+	if err != nil {
+		return 0, err
+	}
+	return fromRat(r.Mul(r, new(big.Rat).SetInt64(int64(a))))
+}
+
+// MulPercent 金额乘以百分比（0-100，如 15 表示 15%），结果四舍五入到分
+func (a Amount) MulPercent(percent int) Amount {
+	v, _ := fromRat(big.NewRat(int64(a)*int64(percent), 100))
+	return v
+}
+
+// ParseRate 解析比例或汇率，必须是大于 0 的十进制数
+func ParseRate(rate string) (*big.Rat, error) {
+	rate = strings.TrimSpace(rate)
+	if !decimalPattern.MatchString(rate) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidRate, rate)
+	}
+	r, ok := new(big.Rat).SetString(rate)
+	if !ok || r.Sign() <= 0 {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidRate, rate)
+	}
+	return r, nil
+}
+
+// InvertRate 计算汇率的倒数，保留 10 位小数（四舍五入）
+func InvertRate(rate string) (string, error) {
+	r, err := ParseRate(rate)
+	if err != nil {
+		return "", err
+	}
+	return r.Inv(r).FloatString(10), nil
+}
+
+// Float64 转换为浮点数，仅用于展示和统计，不应参与金额计算
+func (a Amount) Float64() float64 {
+	return float64(a) / Scale
+}
+
+// String 格式化为两位小数，如 "12.50"、"-0.01"
+func (a Amount) String() string {
+	sign := ""
+	v := int64(a)
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, v/Scale, v%Scale)
+}
+
+// MarshalJSON 序列化为两位小数的 JSON 数字，如 12.50
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON 接受 JSON 数字或字符串，按十进制精确解析
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+
+	if strings.HasPrefix(s, `"`) {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	}
+
+	v, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
@@ -0,0 +1,129 @@
+// Package publicid 对外公开的不透明ID
+//
+// 数据库内部使用自增整数主键，直接暴露会泄露业务量并且可以被遍历。
+// 对外时先将 32 位的整数ID放入 64 位分组的低半部分，经过以密钥为轮函数参数的 Feistel 置换后
+// 编码为固定 11 位的 base62 字符串。置换是可逆的一一映射，解码后高半部分必须为 0，
+// 因此随意构造的字符串几乎不可能解码为合法的ID。更换密钥会使所有已公开的ID失效。
+//
+// 每种实体（kind）使用由密钥和实体名派生的独立置换，订单 5 和用户 5 的公开ID互不相同，
+// 一种实体的ID在另一种实体的接口上几乎不可能解码成功，无法跨资源关联或混用。
+package publicid
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math"
+	"strings"
+	"sync"
+)
+
+const (
+	alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	// Length 编码后的长度，62^11 > 2^64
+	Length = 11
+	rounds = 4
+)
+
+var (
+	// ErrInvalid 无法解码的ID
+	ErrInvalid = errors.New("无效的ID")
+	// ErrOutOfRange 超出 32 位、无法编码的ID
+	ErrOutOfRange = errors.New("ID超出可编码范围")
+)
+
+var (
+	key []byte
+	// kindKeys 各实体的置换密钥缓存
+	kindKeys sync.Map
+)
+
+// Init 设置置换密钥
+func Init(secret string) {
+	key = []byte(secret)
+	kindKeys.Clear()
+}
+
+// Encode 将 kind 实体的整数ID编码为不透明字符串
+func Encode(kind string, id uint32) string {
+	block := permute(kindKey(kind), uint64(id))
+
+	buf := make([]byte, Length)
+	for i := Length - 1; i >= 0; i-- {
+		buf[i] = alphabet[block%62]
+		block /= 62
+	}
+	return string(buf)
+}
+
+// EncodeUint 与 Encode 相同，ID超出 32 位时返回 ErrOutOfRange 而不是截断
+func EncodeUint(kind string, id uint) (string, error) {
+	if uint64(id) > math.MaxUint32 {
+		return "", ErrOutOfRange
+	}
+	return Encode(kind, uint32(id)), nil
+}
+
+// Decode 将 Encode 为同一 kind 生成的字符串还原为整数ID
+func Decode(kind, s string) (uint32, error) {
+	if len(s) != Length {
+		return 0, ErrInvalid
+	}
+
+	var block uint64
+	for i := 0; i < len(s); i++ {
+		d := strings.IndexByte(alphabet, s[i])
+		if d < 0 || block > (math.MaxUint64-uint64(d))/62 {
+			return 0, ErrInvalid
+		}
+		block = block*62 + uint64(d)
+	}
+
+	v := unpermute(kindKey(kind), block)
+	if v>>32 != 0 {
+		return 0, ErrInvalid
+	}
+	return uint32(v), nil
+}
+
+// kindKey 实体的置换密钥 HMAC-SHA256(key, kind)
+func kindKey(kind string) []byte {
+	if k, ok := kindKeys.Load(kind); ok {
+		return k.([]byte)
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(kind))
+	k, _ := kindKeys.LoadOrStore(kind, mac.Sum(nil))
+	return k.([]byte)
+}
+
+// permute 对 64 位分组做 Feistel 置换
+func permute(k []byte, v uint64) uint64 {
+	l, r := uint32(v>>32), uint32(v)
+	for i := 0; i < rounds; i++ {
+		l, r = r, l^round(k, i, r)
+	}
+	return uint64(l)<<32 | uint64(r)
+}
+
+// unpermute permute 的逆运算
+func unpermute(k []byte, v uint64) uint64 {
+	l, r := uint32(v>>32), uint32(v)
+	for i := rounds - 1; i >= 0; i-- {
+		l, r = r^round(k, i, l), l
+	}
+	return uint64(l)<<32 | uint64(r)
+}
+
+// round 轮函数，取 HMAC-SHA256(k, 轮次 || 半分组) 的前 4 字节
+func round(k []byte, i int, half uint32) uint32 {
+	var msg [5]byte
+	msg[0] = byte(i)
+	binary.BigEndian.PutUint32(msg[1:], half)
+
+	mac := hmac.New(sha256.New, k)
+	mac.Write(msg[:])
+	return binary.BigEndian.Uint32(mac.Sum(nil))
+}
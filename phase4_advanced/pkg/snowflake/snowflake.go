@@ -0,0 +1,110 @@
+// Package snowflake 分布式唯一ID生成器
+//
+// 生成的ID为 63 位正整数，由高到低依次为：
+//   - 41 位毫秒时间戳，从 Epoch 开始计算，可使用约 69 年
+//   - 10 位节点编号，多实例部署时每个实例使用不同的编号即可保证全局唯一
+//   - 12 位序列号，同一毫秒内最多生成 4096 个ID，用尽后等待下一毫秒
+//
+// 系统时钟回拨时等待时钟追上上次生成的时间，不会生成重复的ID。
+package snowflake
+
+import (
+	"errors"
+	"hash/fnv"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	nodeBits     = 10
+	sequenceBits = 12
+
+	// MaxNode 节点编号的最大值
+	MaxNode     = 1<<nodeBits - 1
+	maxSequence = 1<<sequenceBits - 1
+)
+
+// Epoch 时间戳的起点，2024-01-01 00:00:00 UTC
+var Epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// ErrInvalidNode 节点编号超出范围
+var ErrInvalidNode = errors.New("节点编号必须在 0-1023 之间")
+
+// Generator ID生成器，可并发使用
+type Generator struct {
+	mu       sync.Mutex
+	node     int64
+	last     int64 // 上次生成ID的时间戳
+	sequence int64
+}
+
+// NewGenerator 创建指定节点编号的生成器
+func NewGenerator(node int64) (*Generator, error) {
+	if node < 0 || node > MaxNode {
+		return nil, ErrInvalidNode
+	}
+	return &Generator{node: node}, nil
+}
+
+// Next 生成下一个ID
+func (g *Generator) Next() int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Since(Epoch).Milliseconds()
+	if now < g.last {
+		time.Sleep(time.Duration(g.last-now) * time.Millisecond)
+		now = g.last
+	}
+
+	if now == g.last {
+		g.sequence = (g.sequence + 1) & maxSequence
+		if g.sequence == 0 {
+			for now <= g.last {
+				time.Sleep(100 * time.Microsecond)
+				now = time.Since(Epoch).Milliseconds()
+			}
+		}
+	} else {
+		g.sequence = 0
+	}
+	g.last = now
+
+	return now<<(nodeBits+sequenceBits) | g.node<<sequenceBits | g.sequence
+}
+
+// Time ID中记录的生成时间
+func Time(id int64) time.Time {
+	return Epoch.Add(time.Duration(id>>(nodeBits+sequenceBits)) * time.Millisecond)
+}
+
+// DefaultNode 根据主机名和进程号计算的节点编号，仅在未配置节点编号时使用，
+// 多实例部署时不能完全避免冲突
+func DefaultNode() int64 {
+	hostname, _ := os.Hostname()
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(hostname + ":" + strconv.Itoa(os.Getpid())))
+	return int64(h.Sum32() % (MaxNode + 1))
+}
+
+var std, _ = NewGenerator(DefaultNode())
+
+// Init 设置默认生成器的节点编号，node 小于 0 时使用 DefaultNode
+func Init(node int64) error {
+	if node < 0 {
+		node = DefaultNode()
+	}
+	g, err := NewGenerator(node)
+	if err != nil {
+		return err
+	}
+	std = g
+	return nil
+}
+
+// Next 使用默认生成器生成下一个ID
+func Next() int64 {
+	return std.Next()
+}
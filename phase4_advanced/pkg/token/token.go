@@ -0,0 +1,134 @@
+package token
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"gin-learn/phase4/pkg/publicid"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TypeAccess Access Token 类型
+const TypeAccess = "access"
+
+// SubjectKind Subject 中用户ID的 publicid 实体名，与 model.User 的对外ID一致
+const SubjectKind = "User"
+
+// ErrInvalidToken Token无效或已过期
+var ErrInvalidToken = errors.New("无效的Token")
+
+// Claims JWT Claims，RegisteredClaims.ID 为 Token 的唯一标识（jti），
+// 用户ID以 publicid 编码后放在 RegisteredClaims.Subject 中，避免在令牌中暴露自增主键
+type Claims struct {
+	UserID   uint   `json:"-"`
+	Username string `json:"username"`
+	Type     string `json:"type"`
+	FamilyID string `json:"fid"`
+	jwt.RegisteredClaims
+}
+
+// Pair 访问令牌与刷新令牌
+type Pair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+var (
+	secret        []byte
+	accessExpire  time.Duration
+	refreshExpire time.Duration
+)
+
+// Init 设置签名密钥和有效期
+func Init(key string, expire, refresh time.Duration) {
+	secret = []byte(key)
+	accessExpire = expire
+	refreshExpire = refresh
+}
+
+// AccessExpire Access Token 有效期
+func AccessExpire() time.Duration {
+	return accessExpire
+}
+
+// RefreshExpire Refresh Token 有效期
+func RefreshExpire() time.Duration {
+	return refreshExpire
+}
+
+// GenerateAccess 生成 Access Token，familyID 关联签发它的刷新令牌族
+func GenerateAccess(userID uint, username, familyID string) (string, error) {
+	subject, err := publicid.EncodeUint(SubjectKind, userID)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := Claims{
+		UserID:   userID,
+		Username: username,
+		Type:     TypeAccess,
+		FamilyID: familyID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        NewID(),
+			Subject:   subject,
+			ExpiresAt: jwt.NewNumericDate(now.Add(accessExpire)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+}
+
+// Parse 解析并校验 Access Token
+func Parse(tokenString string) (*Claims, error) {
+	var claims Claims
+	token, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (interface{}, error) {
+		return secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	if claims.Type != TypeAccess || claims.ID == "" {
+		return nil, ErrInvalidToken
+	}
+
+	userID, err := publicid.Decode(SubjectKind, claims.Subject)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	claims.UserID = uint(userID)
+
+	return &claims, nil
+}
+
+// NewRefresh 生成不透明的 Refresh Token，返回原文及其哈希，数据库中只保存哈希
+func NewRefresh() (raw, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	raw = base64.RawURLEncoding.EncodeToString(b)
+	return raw, Hash(raw), nil
+}
+
+// Hash 计算 Refresh Token 的 SHA-256 哈希
+func Hash(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewID 生成随机ID，用于 jti 和刷新令牌族
+func NewID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}